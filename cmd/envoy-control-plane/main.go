@@ -1,13 +1,23 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/in4it/roxprox/pkg/crypto"
 	envoy "github.com/in4it/roxprox/pkg/envoy"
 	"github.com/in4it/roxprox/pkg/management"
 	storage "github.com/in4it/roxprox/pkg/storage"
+	"github.com/in4it/roxprox/pkg/storage/git"
+	"github.com/in4it/roxprox/pkg/storage/httpsource"
+	"github.com/in4it/roxprox/pkg/storage/kubernetes"
+	"github.com/in4it/roxprox/pkg/storage/local"
+	"github.com/in4it/roxprox/pkg/storage/s3"
+	"github.com/in4it/roxprox/pkg/storage/vault"
+	"github.com/in4it/roxprox/proto/notification"
 	"github.com/juju/loggo"
 )
 
@@ -21,17 +31,107 @@ func main() {
 		storagePath          string
 		storageBucket        string
 		storageNotifications string
+		storagePollInterval  time.Duration
+		storageDebounce      time.Duration
 		awsRegion            string
+		s3Endpoint           string
+		s3ForcePathStyle     bool
+		s3CABundle           string
+		s3AccessKeyID        string
+		s3SecretAccessKey    string
+		sqsEndpoint          string
+		peerDiscovery        string
+		gitRepository        string
+		gitBranch            string
+		gitWorkDir           string
+		kubeconfig           string
+		kubernetesNamespace  string
+		kubernetesStorage    *kubernetes.KubernetesStorage
+		httpURL              string
+		httpStorage          *httpsource.HTTPStorage
+		peerTTL              time.Duration
+		sqsMaxReceiveCount   int
 		acmeContact          string
+		acmeAccount          string
+		acmeDirectory        string
+		acmeRolloverKey      bool
+		acmeUpdateContact    bool
+		acmeDeactivate       bool
+		adminAddress         string
+		grpcTLSCert          string
+		grpcTLSKey           string
+		grpcTLSClientCA      string
+		grpcTLSConfig        *tls.Config
+		allowedNotifications string
+		managementWrites     bool
+		allowedWrites        string
+		peerTLSCert          string
+		peerTLSKey           string
+		peerTLSCA            string
+		peerTLSServerName    string
+		peerTLSConfig        *tls.Config
+		secretStorageType    string
+		secretStoragePath    string
+		secretStorageBucket  string
+		vaultAddress         string
+		vaultMount           string
+		vaultRoleID          string
+		vaultCACert          string
+		pkiKeyProvider       string
+		pkiKey               string
+		keyProvider          crypto.KeyProvider
 		s                    storage.Storage
+		objects              storage.ObjectStore
 	)
 	flag.StringVar(&loglevel, "loglevel", "INFO", "log level")
 	flag.StringVar(&storageType, "storage-type", "local", "storage type")
 	flag.StringVar(&storagePath, "storage-path", "", "storage path")
 	flag.StringVar(&storageBucket, "storage-bucket", "", "s3 storage bucket")
 	flag.StringVar(&storageNotifications, "storage-notifications", "", "s3 storage notifications")
+	flag.DurationVar(&storagePollInterval, "storage-poll-interval", 0, "poll storage for changes (local storage, s3 storage without sqs notifications, or git and http storage where it defaults to 1m), 0 to disable")
+	flag.DurationVar(&storageDebounce, "storage-debounce", 2*time.Second, "wait until a file didn't change for this duration before importing it (local storage)")
 	flag.StringVar(&awsRegion, "aws-region", "", "AWS region")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "", "custom s3 endpoint url (for S3-compatible object stores)")
+	flag.BoolVar(&s3ForcePathStyle, "s3-force-path-style", false, "use path-style addressing for s3 (bucket name in the path)")
+	flag.StringVar(&s3CABundle, "s3-ca-bundle", "", "path to a PEM file with CA certificates to trust for the s3 endpoint")
+	flag.StringVar(&s3AccessKeyID, "s3-access-key-id", "", "static s3 access key id (defaults to the AWS credential chain)")
+	flag.StringVar(&s3SecretAccessKey, "s3-secret-access-key", "", "static s3 secret access key")
+	flag.StringVar(&sqsEndpoint, "sqs-endpoint", "", "custom sqs endpoint url for the notifications queue")
+	flag.StringVar(&peerDiscovery, "peer-discovery", "", "comma separated list of peers to notify: static:host[:port], dns:name[:port], srv:name or file:path (defaults to dns lookups on the storage notifications)")
+	flag.DurationVar(&peerTTL, "peer-ttl", 5*time.Minute, "remove peers that weren't discovered for this duration")
+	flag.IntVar(&sqsMaxReceiveCount, "sqs-max-receive-count", 10, "move notifications that couldn't be relayed to the peers after this many receives to the dead-letter queue")
+	flag.StringVar(&gitRepository, "git-repository", "", "git repository url (git storage)")
+	flag.StringVar(&gitBranch, "git-branch", "", "git branch (defaults to the default branch of the repository)")
+	flag.StringVar(&gitWorkDir, "git-work-dir", "", "directory to clone the git repository in (defaults to a directory in the temp dir)")
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "path to a kubeconfig (kubernetes storage, defaults to the in-cluster config)")
+	flag.StringVar(&kubernetesNamespace, "kubernetes-namespace", "", "namespace to watch for custom resources (kubernetes storage, defaults to all namespaces)")
+	flag.StringVar(&httpURL, "http-url", "", "url of the manifest with the objects or the object urls (http storage)")
+	flag.StringVar(&secretStorageType, "secret-storage-type", "", "storage type for certificates and keys: local, s3 or vault (defaults to the storage type for local, s3 and git storage, local otherwise)")
+	flag.StringVar(&secretStoragePath, "secret-storage-path", "", "storage path for certificates and keys, or the path within the mount for vault (defaults to storage-path)")
+	flag.StringVar(&secretStorageBucket, "secret-storage-bucket", "", "s3 storage bucket for certificates and keys (defaults to storage-bucket)")
+	flag.StringVar(&vaultAddress, "vault-address", os.Getenv("VAULT_ADDR"), "vault address (vault secret storage)")
+	flag.StringVar(&vaultMount, "vault-mount", "secret", "vault KV v2 mount (vault secret storage)")
+	flag.StringVar(&vaultRoleID, "vault-role-id", "", "vault AppRole role id, the secret id is read from the VAULT_SECRET_ID environment variable (defaults to token auth using VAULT_TOKEN)")
+	flag.StringVar(&vaultCACert, "vault-ca-cert", "", "path to a PEM file with CA certificates to trust for the vault address")
+	flag.StringVar(&pkiKeyProvider, "pki-key-provider", "", "encrypt the certificates and keys with a key provider: passphrase (from the PKI_PASSPHRASE environment variable), keyfile or kms (default no encryption)")
+	flag.StringVar(&pkiKey, "pki-key", "", "path to the key file (keyfile key provider) or KMS key id (kms key provider)")
 	flag.StringVar(&acmeContact, "acme-contact", "", "acme contact for TLS certs")
+	flag.StringVar(&acmeAccount, "acme-account", "", "acme account name (accounts are stored in pki/accountkeys/<name>)")
+	flag.StringVar(&acmeDirectory, "acme-directory", "", "acme directory url (defaults to letsencrypt)")
+	flag.BoolVar(&acmeRolloverKey, "acme-rollover-account-key", false, "replace the acme account key with a new key and exit")
+	flag.BoolVar(&acmeUpdateContact, "acme-update-contact", false, "update the contact of the acme account to acme-contact at startup")
+	flag.BoolVar(&acmeDeactivate, "acme-deactivate-account", false, "deactivate the acme account and exit")
+	flag.StringVar(&adminAddress, "admin-address", "127.0.0.1:8082", "listen address of the admin http interface (health checks, config dump), empty to disable. Use :8082 to listen on all interfaces")
+	flag.StringVar(&grpcTLSCert, "grpc-tls-cert", "", "path to the PEM certificate of the xds and management grpc servers (default no TLS)")
+	flag.StringVar(&grpcTLSKey, "grpc-tls-key", "", "path to the PEM key of the grpc-tls-cert")
+	flag.StringVar(&grpcTLSClientCA, "grpc-tls-client-ca", "", "path to a PEM file with CA certificates to verify client certificates with, clients without a valid certificate are rejected")
+	flag.StringVar(&allowedNotifications, "notification-allowed-clients", "", "comma separated list of client certificate identities (dns, ip, email or uri subject alternative names) that can send notifications, requires grpc-tls-client-ca")
+	flag.BoolVar(&managementWrites, "management-writes", false, "enable ApplyObject and DeleteObject on the management interface, to change the objects in local or s3 storage")
+	flag.StringVar(&allowedWrites, "management-write-allowed-clients", "", "comma separated list of client certificate identities that can apply and delete objects and trigger imports and renewals, requires grpc-tls-client-ca")
+	flag.StringVar(&peerTLSCert, "peer-tls-cert", "", "path to the PEM client certificate to send notifications to the peers with (defaults to grpc-tls-cert)")
+	flag.StringVar(&peerTLSKey, "peer-tls-key", "", "path to the PEM key of the peer-tls-cert (defaults to grpc-tls-key)")
+	flag.StringVar(&peerTLSCA, "peer-tls-ca", "", "path to a PEM file with CA certificates to verify the peers with (defaults to grpc-tls-client-ca)")
+	flag.StringVar(&peerTLSServerName, "peer-tls-server-name", "", "server name to verify the peer certificates with (defaults to the peer address)")
 
 	flag.Parse()
 
@@ -43,24 +143,190 @@ func main() {
 		loggo.ConfigureLoggers(`<root>=INFO`)
 	}
 
+	if pkiKeyProvider != "" {
+		if pkiKeyProvider == "passphrase" {
+			pkiKey = os.Getenv("PKI_PASSPHRASE")
+		}
+		keyProvider, err = storage.NewKeyProvider(pkiKeyProvider, pkiKey, awsRegion)
+		if err != nil {
+			logger.Errorf("Couldn't initialize key provider: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	if grpcTLSCert != "" || grpcTLSKey != "" {
+		grpcTLSConfig, err = crypto.NewServerTLSConfig(crypto.TLSFiles{Cert: grpcTLSCert, Key: grpcTLSKey, CA: grpcTLSClientCA})
+		if err != nil {
+			logger.Errorf("Couldn't initialize grpc TLS: %s", err)
+			os.Exit(1)
+		}
+		// the peers run with the same grpc config, so they expect TLS and a client certificate
+		if peerTLSCert == "" && peerTLSKey == "" {
+			peerTLSCert, peerTLSKey = grpcTLSCert, grpcTLSKey
+		}
+		if peerTLSCA == "" {
+			peerTLSCA = grpcTLSClientCA
+		}
+	}
+	if peerTLSCert != "" || peerTLSKey != "" || peerTLSCA != "" {
+		peerTLSConfig, err = crypto.NewClientTLSConfig(crypto.TLSFiles{Cert: peerTLSCert, Key: peerTLSKey, CA: peerTLSCA}, peerTLSServerName)
+		if err != nil {
+			logger.Errorf("Couldn't initialize peer TLS: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	s3Config := s3.Config{
+		Prefix:               storagePath,
+		Bucket:               storageBucket,
+		Region:               awsRegion,
+		StorageNotifications: storageNotifications,
+		Endpoint:             s3Endpoint,
+		ForcePathStyle:       s3ForcePathStyle,
+		CABundle:             s3CABundle,
+		AccessKeyID:          s3AccessKeyID,
+		SecretAccessKey:      s3SecretAccessKey,
+		SQSEndpoint:          sqsEndpoint,
+		PeerDiscovery:        peerDiscovery,
+		PeerTTL:              peerTTL,
+		KeyProvider:          keyProvider,
+		PeerTLSConfig:        peerTLSConfig,
+		MaxReceiveCount:      sqsMaxReceiveCount,
+	}
+
 	if storageType == "local" {
-		s, err = storage.NewLocalStorage(storagePath)
+		s, err = storage.NewLocalStorageWithConfig(local.Config{Path: storagePath, KeyProvider: keyProvider})
 		if err != nil {
 			logger.Errorf("Couldn't inialize storage: %s", err)
 			os.Exit(1)
 		}
 	} else if storageType == "s3" {
-		startNotificationQueue := true
-		s, err = storage.NewS3Storage(storageBucket, storagePath, awsRegion, storageNotifications, startNotificationQueue)
+		startNotificationQueue := storagePollInterval == 0 // poll instead of using the sqs queue
+		s, err = storage.NewS3StorageWithConfig(s3Config, startNotificationQueue)
 		if err != nil {
 			logger.Errorf("Couldn't inialize storage: %s", err)
 			os.Exit(1)
 		}
+	} else if storageType == "git" {
+		s, err = storage.NewGitStorage(git.Config{Repository: gitRepository, Branch: gitBranch, WorkDir: gitWorkDir, Path: storagePath, KeyProvider: keyProvider})
+		if err != nil {
+			logger.Errorf("Couldn't inialize storage: %s", err)
+			os.Exit(1)
+		}
+		if storagePollInterval == 0 {
+			storagePollInterval = time.Minute
+		}
+	} else if storageType == "kubernetes" {
+		kubernetesStorage, err = storage.NewKubernetesStorage(kubernetes.Config{Kubeconfig: kubeconfig, Namespace: kubernetesNamespace})
+		if err != nil {
+			logger.Errorf("Couldn't inialize storage: %s", err)
+			os.Exit(1)
+		}
+		objects = kubernetesStorage
+	} else if storageType == "http" {
+		if storagePollInterval == 0 {
+			storagePollInterval = time.Minute
+		}
+		httpStorage, err = storage.NewHTTPStorage(httpsource.Config{URL: httpURL, Interval: storagePollInterval})
+		if err != nil {
+			logger.Errorf("Couldn't inialize storage: %s", err)
+			os.Exit(1)
+		}
+		objects = httpStorage
 	} else {
 		panic("unknown storage")
 	}
 
-	xds := envoy.NewXDS(s, acmeContact, "8080")
+	// certificates and keys can be stored separately from the objects
+	if s != nil {
+		objects = s
+	} else if secretStorageType == "" {
+		secretStorageType = "local"
+	}
+	if secretStorageType != "" {
+		if secretStoragePath == "" {
+			secretStoragePath = storagePath
+		}
+		var secrets storage.SecretStore
+		switch secretStorageType {
+		case "local":
+			secrets, err = storage.NewSecretStore("local", local.Config{Path: secretStoragePath, KeyProvider: keyProvider})
+		case "s3":
+			secretS3Config := s3Config
+			secretS3Config.Prefix = secretStoragePath
+			if secretStorageBucket != "" {
+				secretS3Config.Bucket = secretStorageBucket
+			}
+			secrets, err = storage.NewSecretStore("s3", secretS3Config)
+		case "vault":
+			secrets, err = storage.NewSecretStore("vault", vault.Config{
+				Address:  vaultAddress,
+				Mount:    vaultMount,
+				Path:     secretStoragePath,
+				Token:    os.Getenv("VAULT_TOKEN"),
+				RoleID:   vaultRoleID,
+				SecretID: os.Getenv("VAULT_SECRET_ID"),
+				CACert:   vaultCACert,
+			})
+		default:
+			secrets, err = storage.NewSecretStore(secretStorageType, nil)
+		}
+		if err != nil {
+			logger.Errorf("Couldn't inialize secret storage: %s", err)
+			os.Exit(1)
+		}
+		s = storage.NewCombinedStorage(objects, secrets)
+	}
+
+	xds := envoy.NewXDSWithConfig(s, envoy.XDSConfig{
+		AcmeAccount: envoy.AcmeAccount{Name: acmeAccount, Contact: acmeContact, DirectoryURL: acmeDirectory},
+		Port:        "8080",
+		TLSConfig:   grpcTLSConfig,
+	})
+
+	if acmeDeactivate {
+		err = xds.DeactivateAcmeAccount()
+		if err != nil {
+			logger.Errorf("Couldn't deactivate acme account: %s", err)
+			os.Exit(1)
+		}
+		logger.Infof("Acme account deactivated")
+		os.Exit(0)
+	}
+	if acmeRolloverKey {
+		err = xds.RolloverAcmeAccountKey()
+		if err != nil {
+			logger.Errorf("Couldn't rollover acme account key: %s", err)
+			os.Exit(1)
+		}
+		logger.Infof("Acme account key rolled over")
+		os.Exit(0)
+	}
+	if acmeUpdateContact {
+		err = xds.UpdateAcmeContact(acmeContact)
+		if err != nil {
+			logger.Errorf("Couldn't update acme contact: %s", err)
+		}
+	}
+
+	// start admin interface (not ready until the objects are imported)
+	if adminAddress != "" {
+		err = management.NewAdminServer(xds, adminAddress)
+		if err != nil {
+			logger.Errorf("Couldn't start admin interface: %s", err)
+			os.Exit(1)
+		}
+	}
+
+	// the s3 poller lists the objects before the import, so the changes during the import aren't missed
+	var s3Poller *s3.Poller
+	if storagePollInterval > 0 && storageType == "s3" {
+		s3Poller, err = storage.NewS3Poller(s3Config, storagePollInterval)
+		if err != nil {
+			logger.Errorf("Couldn't start storage poller: %s", err)
+			os.Exit(1)
+		}
+	}
 
 	logger.Infof("Importing Rules")
 
@@ -69,9 +335,48 @@ func main() {
 		logger.Errorf("Couldn't import rules: %s", err)
 	}
 
+	// custom resources are watched using informers
+	if kubernetesStorage != nil {
+		go receiveNotifications(xds, kubernetesStorage.GetQueue())
+	}
+
+	// poll storage for changes
+	if storagePollInterval > 0 {
+		switch storageType {
+		case "local":
+			watcher, err := storage.NewLocalWatcher(storagePath, storagePollInterval, storageDebounce)
+			if err != nil {
+				logger.Errorf("Couldn't start storage watcher: %s", err)
+				os.Exit(1)
+			}
+			go receiveNotifications(xds, watcher.GetQueue())
+			watcher.Start()
+		case "s3":
+			s3Poller.Start(xds.ReceiveNotification)
+		case "git":
+			watcher, err := storage.NewGitWatcher(objects, storagePollInterval)
+			if err != nil {
+				logger.Errorf("Couldn't start git watcher: %s", err)
+				os.Exit(1)
+			}
+			watcher.Start(xds.ReceiveNotification)
+		case "http":
+			go receiveNotifications(xds, httpStorage.GetQueue())
+			httpStorage.Start()
+		}
+	}
+
 	// start management server
 	notificationReceiver := management.NewNotificationReceiver(xds)
-	err = management.NewServer(notificationReceiver)
+	managementServer := management.NewManagementServerWithConfig(xds, management.ManagementServerConfig{EnableWrites: managementWrites})
+	managementConfig := management.ServerConfig{TLSConfig: grpcTLSConfig}
+	if allowedNotifications != "" {
+		managementConfig.AllowedNotificationClients = strings.Split(allowedNotifications, ",")
+	}
+	if allowedWrites != "" {
+		managementConfig.AllowedWriteClients = strings.Split(allowedWrites, ",")
+	}
+	err = management.NewServerWithConfig(notificationReceiver, managementServer, managementConfig)
 	if err != nil {
 		logger.Errorf("Couldn't start management interface: %s", err)
 		os.Exit(1)
@@ -102,3 +407,12 @@ func main() {
 	select {}
 
 }
+
+func receiveNotifications(xds *envoy.XDS, queue chan []*notification.NotificationRequest_NotificationItem) {
+	for items := range queue {
+		err := xds.ReceiveNotification(items)
+		if err != nil {
+			logger.Errorf("%s", err)
+		}
+	}
+}
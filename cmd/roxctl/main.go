@@ -0,0 +1,454 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	envoy "github.com/in4it/roxprox/pkg/envoy"
+	storage "github.com/in4it/roxprox/pkg/storage"
+	"github.com/juju/loggo"
+)
+
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitUsage   = 2
+	exitPending = 3
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: roxctl <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  validate    validate the objects in a directory\n")
+	fmt.Fprintf(os.Stderr, "  render      print the envoy clusters and listeners generated from the objects in a directory\n")
+	fmt.Fprintf(os.Stderr, "  diff        print the changes of the envoy configuration between two directories or rendered json files: diff [flags] <old> <new>\n")
+	fmt.Fprintf(os.Stderr, "  explain     show which rule, route and cluster handle a request: explain [flags] -host <host> -path <path>\n")
+	fmt.Fprintf(os.Stderr, "  export      print an envoy bootstrap with static resources, to run envoy without control plane\n")
+	fmt.Fprintf(os.Stderr, "  bootstrap   print an envoy bootstrap that connects to the control plane: bootstrap [flags] -xds-address <host>\n\n")
+	fmt.Fprintf(os.Stderr, "Exit codes: %d valid, %d validation errors, %d usage error, %d objects with unresolved dependencies\n", exitValid, exitInvalid, exitUsage, exitPending)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	switch os.Args[1] {
+	case "validate", "render":
+		os.Exit(render(os.Args[1], os.Args[2:]))
+	case "diff":
+		os.Exit(diff(os.Args[2:]))
+	case "explain":
+		os.Exit(explain(os.Args[2:]))
+	case "export":
+		os.Exit(export(os.Args[2:]))
+	case "bootstrap":
+		os.Exit(bootstrap(os.Args[2:]))
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+		usage()
+		os.Exit(exitUsage)
+	}
+}
+
+func render(command string, args []string) int {
+	var (
+		loglevel    string
+		storagePath string
+		output      string
+	)
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&loglevel, "loglevel", "WARNING", "log level")
+	flags.StringVar(&storagePath, "storage-path", ".", "directory with the objects")
+	flags.StringVar(&output, "output", "yaml", "output format of the render command: json or yaml")
+	flags.Parse(args)
+
+	var format pkgApi.Format
+	switch output {
+	case "json":
+		format = pkgApi.FormatJSON
+	case "yaml":
+		format = pkgApi.FormatYAML
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s\n", output)
+		return exitUsage
+	}
+
+	loggo.ConfigureLoggers(`<root>=` + loglevel)
+
+	s, err := storage.NewLocalStorage(storagePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't initialize storage: %s\n", err)
+		return exitInvalid
+	}
+	result, err := envoy.Render(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+
+	if command == "render" {
+		out, err := result.Marshal(format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return exitInvalid
+		}
+		os.Stdout.Write(out)
+	}
+
+	for _, pending := range result.Pending {
+		for _, dependency := range pending.Dependencies {
+			fmt.Fprintf(os.Stderr, "Pending: %s %s is waiting for %s %s\n", pending.Kind, pending.Name, dependency.Type, dependency.Name)
+		}
+	}
+	for _, validationError := range result.Errors {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", validationError)
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		return exitInvalid
+	case len(result.Pending) > 0:
+		return exitPending
+	}
+	if command == "validate" {
+		fmt.Fprintf(os.Stderr, "Configuration is valid (%d clusters, %d listeners)\n", len(result.Clusters), len(result.Listeners))
+	}
+	return exitValid
+}
+
+func diff(args []string) int {
+	var (
+		loglevel string
+		output   string
+	)
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.StringVar(&loglevel, "loglevel", "WARNING", "log level")
+	flags.StringVar(&output, "output", "text", "output format: text or json")
+	flags.Parse(args)
+	if flags.NArg() != 2 || (output != "text" && output != "json") {
+		usage()
+		return exitUsage
+	}
+
+	loggo.ConfigureLoggers(`<root>=` + loglevel)
+
+	var results []*envoy.RenderResult
+	for _, path := range flags.Args() {
+		result, err := load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", path, err)
+			return exitInvalid
+		}
+		results = append(results, result)
+	}
+
+	changes := envoy.Diff(results[0], results[1])
+	if output == "json" {
+		if changes == nil {
+			changes = []envoy.Change{}
+		}
+		out, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return exitInvalid
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+	}
+	return exitValid
+}
+
+// load renders the objects in a directory, or reads a file with the json output of the render command
+func load(path string) (*envoy.RenderResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return envoy.UnmarshalRenderResult(data)
+	}
+	s, err := storage.NewLocalStorage(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't initialize storage: %s", err)
+	}
+	result, err := envoy.Render(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, pending := range result.Pending {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s %s has unresolved dependencies\n", path, pending.Kind, pending.Name)
+	}
+	return result, nil
+}
+
+// headerFlags collects the headers of the repeatable -header flag
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	return fmt.Sprint(map[string]string(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	kv := strings.SplitN(value, ":", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("header must be in the format name: value")
+	}
+	h[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	return nil
+}
+
+func explain(args []string) int {
+	var (
+		loglevel    string
+		storagePath string
+		output      string
+		request     envoy.ExplainRequest
+	)
+	request.Headers = make(headerFlags)
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	flags.StringVar(&loglevel, "loglevel", "WARNING", "log level")
+	flags.StringVar(&storagePath, "storage-path", ".", "directory with the objects, or a file with the json output of the render command")
+	flags.StringVar(&output, "output", "text", "output format: text or json")
+	flags.StringVar(&request.Listener, "listener", "http", "listener: http, tls or the name of an mTLS object")
+	flags.StringVar(&request.Host, "host", "", "host of the request")
+	flags.StringVar(&request.Path, "path", "/", "path of the request")
+	flags.StringVar(&request.Method, "method", "GET", "method of the request")
+	flags.Var(headerFlags(request.Headers), "header", "header of the request (name: value), can be repeated")
+	flags.Parse(args)
+	if output != "text" && output != "json" {
+		usage()
+		return exitUsage
+	}
+
+	loggo.ConfigureLoggers(`<root>=` + loglevel)
+
+	config, err := load(storagePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	result, explainErr := envoy.Explain(config, request)
+
+	if output == "json" {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return exitInvalid
+		}
+		fmt.Println(string(out))
+	} else {
+		printField := func(name, value string) {
+			if value != "" {
+				fmt.Printf("%-16s%s\n", name+":", value)
+			}
+		}
+		printField("Listener", result.Listener)
+		printField("Filter chain", result.FilterChain)
+		printField("Virtual host", result.VirtualHost)
+		printField("Route", result.Route)
+		printField("Rule", result.Rule)
+		printField("Cluster", result.Cluster)
+		if result.DirectResponse != 0 {
+			printField("Direct response", fmt.Sprint(result.DirectResponse))
+		}
+		printField("Host rewrite", result.HostRewrite)
+		printField("Path rewrite", result.PathRewrite)
+		printField("JWT provider", result.JwtProvider)
+		printField("Authz", result.Authz)
+		for _, rateLimit := range result.RateLimits {
+			printField("Rate limit", rateLimit)
+		}
+	}
+	if explainErr != nil {
+		fmt.Fprintf(os.Stderr, "No match: %s\n", explainErr)
+		return exitInvalid
+	}
+	return exitValid
+}
+
+func export(args []string) int {
+	var (
+		loglevel     string
+		storagePath  string
+		output       string
+		clustersPath string
+		adminPort    uint
+		options      envoy.StaticBootstrapOptions
+	)
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.StringVar(&loglevel, "loglevel", "WARNING", "log level")
+	flags.StringVar(&storagePath, "storage-path", ".", "directory with the objects, or a file with the json output of the render command")
+	flags.StringVar(&output, "output", "yaml", "output format: json or yaml")
+	flags.StringVar(&options.NodeID, "node-id", "", "envoy node id")
+	flags.StringVar(&options.NodeCluster, "node-cluster", "", "envoy node cluster")
+	flags.StringVar(&options.AdminAddress, "admin-address", "127.0.0.1", "address of the envoy admin interface")
+	flags.UintVar(&adminPort, "admin-port", 9901, "port of the envoy admin interface")
+	flags.StringVar(&options.CertPath, "cert-path", "", "write the certificates and keys to files in this directory instead of inline in the bootstrap")
+	flags.StringVar(&clustersPath, "clusters", "", "file with a list of envoy clusters to add (json or yaml), e.g. the rate limit service")
+	flags.Parse(args)
+
+	var format pkgApi.Format
+	switch output {
+	case "json":
+		format = pkgApi.FormatJSON
+	case "yaml":
+		format = pkgApi.FormatYAML
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s\n", output)
+		return exitUsage
+	}
+	options.AdminPort = uint32(adminPort)
+
+	loggo.ConfigureLoggers(`<root>=` + loglevel)
+
+	if clustersPath != "" {
+		data, err := ioutil.ReadFile(clustersPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return exitInvalid
+		}
+		options.Clusters, err = envoy.UnmarshalStaticClusters(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", clustersPath, err)
+			return exitInvalid
+		}
+	}
+
+	config, err := load(storagePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	staticBootstrap, err := envoy.NewStaticBootstrap(config.Clusters, config.Listeners, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	out, err := staticBootstrap.Marshal(format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	if err := staticBootstrap.WriteFiles(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	os.Stdout.Write(out)
+	return exitValid
+}
+
+func bootstrap(args []string) int {
+	var (
+		loglevel         string
+		storagePath      string
+		output           string
+		xdsPort          uint
+		adminPort        uint
+		rateLimitAddress string
+		tracingAddress   string
+		options          envoy.BootstrapOptions
+	)
+	flags := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	flags.StringVar(&loglevel, "loglevel", "WARNING", "log level")
+	flags.StringVar(&storagePath, "storage-path", ".", "directory with the objects")
+	flags.StringVar(&output, "output", "yaml", "output format: json or yaml")
+	flags.StringVar(&options.NodeID, "node-id", "", "envoy node id")
+	flags.StringVar(&options.NodeCluster, "node-cluster", "", "envoy node cluster")
+	flags.StringVar(&options.XDSAddress, "xds-address", "", "address of the control plane (required)")
+	flags.UintVar(&xdsPort, "xds-port", 8080, "port of the control plane")
+	flags.BoolVar(&options.ADS, "ads", true, "use one aggregated stream for clusters and listeners")
+	flags.StringVar(&options.AdminAddress, "admin-address", "127.0.0.1", "address of the envoy admin interface")
+	flags.UintVar(&adminPort, "admin-port", 9901, "port of the envoy admin interface")
+	flags.StringVar(&rateLimitAddress, "ratelimit-address", "", "host[:port] of the rate limit service, required when there are rateLimit objects")
+	flags.StringVar(&tracingAddress, "tracing-address", "", "host[:port] of the tracing collector, required when there are tracing objects")
+	flags.BoolVar(&options.XDSTLS, "xds-tls", false, "connect to the control plane with TLS (enabled when one of the other xds-tls flags is set)")
+	flags.StringVar(&options.XDSCACertPath, "xds-tls-ca", "", "path on the envoy node of the CA certificates to verify the control plane with")
+	flags.StringVar(&options.XDSClientCertPath, "xds-tls-cert", "", "path on the envoy node of the client certificate, required when the control plane runs with -grpc-tls-client-ca")
+	flags.StringVar(&options.XDSClientKeyPath, "xds-tls-key", "", "path on the envoy node of the key of the client certificate")
+	flags.StringVar(&options.XDSTLSServerName, "xds-tls-server-name", "", "server name (SNI) of the control plane certificate (defaults to the xds address)")
+	flags.Parse(args)
+
+	var format pkgApi.Format
+	switch output {
+	case "json":
+		format = pkgApi.FormatJSON
+	case "yaml":
+		format = pkgApi.FormatYAML
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s\n", output)
+		return exitUsage
+	}
+	if options.XDSAddress == "" {
+		fmt.Fprintf(os.Stderr, "-xds-address is required\n")
+		return exitUsage
+	}
+	options.XDSPort = uint32(xdsPort)
+	options.AdminPort = uint32(adminPort)
+	var err error
+	if options.RateLimitAddress, options.RateLimitPort, err = splitAddress(rateLimitAddress); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -ratelimit-address: %s\n", err)
+		return exitUsage
+	}
+	if options.TracingAddress, options.TracingPort, err = splitAddress(tracingAddress); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -tracing-address: %s\n", err)
+		return exitUsage
+	}
+
+	loggo.ConfigureLoggers(`<root>=` + loglevel)
+
+	s, err := storage.NewLocalStorage(storagePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't initialize storage: %s\n", err)
+		return exitInvalid
+	}
+	objects, err := s.ListObjects()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	b, err := envoy.NewBootstrap(objects, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	out, err := envoy.MarshalBootstrap(b, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return exitInvalid
+	}
+	os.Stdout.Write(out)
+	return exitValid
+}
+
+// splitAddress splits host[:port], the port is 0 when it's not set
+func splitAddress(address string) (string, uint32, error) {
+	if address == "" || !strings.Contains(address, ":") {
+		return address, 0, nil
+	}
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port: %s", portStr)
+	}
+	return host, uint32(port), nil
+}
@@ -0,0 +1,56 @@
+package acme
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// GetAccount retrieves the account registered with the account key
+func (a *Acme) GetAccount() (*acme.Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	return a.client.GetReg(ctx, "")
+}
+
+// UpdateContact replaces the contact address of the registered account
+func (a *Acme) UpdateContact(contact string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	account, err := a.client.UpdateReg(ctx, &acme.Account{Contact: []string{"mailto: " + contact}})
+	if err != nil {
+		return fmt.Errorf("Couldn't update contact: %s", err)
+	}
+	a.config.Contact = contact
+	logger.Debugf("Contact updated: response: %+v", account)
+
+	return nil
+}
+
+// RolloverKey replaces the account key at the ACME provider (keyChange endpoint).
+// On success the client will sign subsequent requests with the new key
+func (a *Acme) RolloverKey(newKey *rsa.PrivateKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	err := a.client.AccountKeyRollover(ctx, newKey)
+	if err != nil {
+		return fmt.Errorf("Couldn't rollover account key: %s", err)
+	}
+	a.config.AccountKey = newKey
+
+	return nil
+}
+
+// Deactivate permanently disables the registered account
+func (a *Acme) Deactivate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	return a.client.DeactivateReg(ctx)
+}
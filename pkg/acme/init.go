@@ -23,14 +23,15 @@ type Acme struct {
 	challenges map[string]*acme.Challenge
 }
 type Config struct {
-	AccountKey *rsa.PrivateKey
-	Contact    string
+	AccountKey   *rsa.PrivateKey
+	Contact      string
+	DirectoryURL string
 }
 
 func NewAcme(config Config) *Acme {
 	return &Acme{
 		config:     config,
-		client:     &acme.Client{Key: config.AccountKey},
+		client:     &acme.Client{Key: config.AccountKey, DirectoryURL: config.DirectoryURL},
 		challenges: make(map[string]*acme.Challenge),
 	}
 }
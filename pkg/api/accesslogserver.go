@@ -13,3 +13,18 @@ type AccessLogServerSpec struct {
 	AdditionalResponseHeadersToLog []string `json:"additionalResponseHeadersToLog" yaml:"additionalResponseHeadersToLog"`
 	Listener                       Listener `json:"listener"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name: "accessLogServer",
+		New: func() interface{} {
+			return &AccessLogServer{}
+		},
+		Validate: validateAccessLogServer,
+	})
+}
+
+func validateAccessLogServer(data interface{}) error {
+	accessLogServer := data.(AccessLogServer)
+	return validatePort("spec.port", accessLogServer.Spec.Port)
+}
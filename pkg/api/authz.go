@@ -13,3 +13,21 @@ type AuthzFilterSpec struct {
 	Port             int64    `json:"port" yaml:"port"`
 	Listener         Listener `json:"listener"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name: "authzFilter",
+		New: func() interface{} {
+			return &AuthzFilter{}
+		},
+		Validate: validateAuthzFilter,
+	})
+}
+
+func validateAuthzFilter(data interface{}) error {
+	authzFilter := data.(AuthzFilter)
+	return firstError(
+		validateDuration("spec.timeout", authzFilter.Spec.Timeout),
+		validatePort("spec.port", authzFilter.Spec.Port),
+	)
+}
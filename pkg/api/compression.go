@@ -13,3 +13,12 @@ type CompressionSpec struct {
 	DisableOnEtagHeader bool     `json:"disableOnEtagHeader" yaml:"disableOnEtagHeader"`
 	Listener            Listener `json:"listener"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name: "compression",
+		New: func() interface{} {
+			return &Compression{}
+		},
+	})
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Format is the encoding of a document
+type Format int
+
+const (
+	FormatYAML Format = iota
+	FormatJSON
+)
+
+// decode decodes a document into out. Unknown fields are rejected when strict is set
+func (f Format) decode(document []byte, out interface{}, strict bool) error {
+	if f == FormatJSON {
+		decoder := json.NewDecoder(bytes.NewReader(document))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+		return decoder.Decode(out)
+	}
+	if strict {
+		return yaml.UnmarshalStrict(document, out)
+	}
+	return yaml.Unmarshal(document, out)
+}
+
+// encode encodes a decoded document
+func (f Format) encode(value interface{}) ([]byte, error) {
+	if f == FormatJSON {
+		return json.Marshal(value)
+	}
+	return yaml.Marshal(value)
+}
+
+// renameDeprecatedFields moves the deprecated fields of a decoded document to their replacement.
+// A deprecated field is ignored when the replacement is also set. It returns a warning for every deprecated field
+// that was used, the document changed when there are warnings
+func renameDeprecatedFields(value interface{}, deprecatedFields map[string]string, kind, name string) []string {
+	var warnings []string
+	deprecatedNames := make([]string, 0, len(deprecatedFields))
+	for deprecated := range deprecatedFields {
+		deprecatedNames = append(deprecatedNames, deprecated)
+	}
+	sort.Strings(deprecatedNames)
+	for _, deprecated := range deprecatedNames {
+		replacement := deprecatedFields[deprecated]
+		parts := strings.Split(deprecated, ".")
+		entries := value
+		for _, part := range parts[:len(parts)-1] {
+			entries = getMapEntry(entries, part)
+		}
+		deprecatedValue := getMapEntry(entries, parts[len(parts)-1])
+		if deprecatedValue == nil {
+			continue
+		}
+		replacementKey := replacement[strings.LastIndex(replacement, ".")+1:]
+		deleteMapEntry(entries, parts[len(parts)-1])
+		if getMapEntry(entries, replacementKey) != nil {
+			warnings = append(warnings, fmt.Sprintf("%s %s: %s is deprecated and ignored, because %s is set", kind, name, deprecated, replacement))
+			continue
+		}
+		setMapEntry(entries, replacementKey, deprecatedValue)
+		warnings = append(warnings, fmt.Sprintf("%s %s: %s is deprecated, use %s", kind, name, deprecated, replacement))
+	}
+	return warnings
+}
+
+func getMapEntry(value interface{}, key string) interface{} {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m[key]
+	case map[interface{}]interface{}:
+		return m[key]
+	}
+	return nil
+}
+
+func setMapEntry(value interface{}, key string, entry interface{}) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		m[key] = entry
+	case map[interface{}]interface{}:
+		m[key] = entry
+	}
+}
+
+func deleteMapEntry(value interface{}, key string) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		delete(m, key)
+	case map[interface{}]interface{}:
+		delete(m, key)
+	}
+}
+
+// checkUnknownFields compares the keys of a decoded document with the fields of t and returns
+// a FieldError with the path of the first key that has no matching field
+func checkUnknownFields(value interface{}, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" { // unexported
+				continue
+			}
+			if name := fieldName(field); name != "-" {
+				fields[name] = field.Type
+			}
+		}
+		entries := mapEntries(value)
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldValue := entries[key]
+			fieldType, ok := fields[key]
+			if !ok {
+				return &FieldError{Field: joinField(path, key), Message: "unknown field"}
+			}
+			if err := checkUnknownFields(fieldValue, fieldType, joinField(path, key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if values, ok := value.([]interface{}); ok {
+			for i, v := range values {
+				if err := checkUnknownFields(v, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName returns the name of a field in a document: the yaml tag, the json tag or the lowercase field name
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"yaml", "json"} {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+// mapEntries returns the entries of a yaml or json map
+func mapEntries(value interface{}) map[string]interface{} {
+	entries := make(map[string]interface{})
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			entries[fmt.Sprint(k)] = v
+		}
+	}
+	return entries
+}
+
+func joinField(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
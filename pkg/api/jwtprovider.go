@@ -12,3 +12,18 @@ type JwtProviderSpec struct {
 	RemoteJwks string   `json:"remoteJwks" yaml:"remoteJwks"`
 	Listener   Listener `json:"listener"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name: "jwtProvider",
+		New: func() interface{} {
+			return &JwtProvider{}
+		},
+		Validate: validateJwtProvider,
+	})
+}
+
+func validateJwtProvider(data interface{}) error {
+	jwtProvider := data.(JwtProvider)
+	return validateURL("spec.remoteJwks", jwtProvider.Spec.RemoteJwks)
+}
@@ -1,5 +1,7 @@
 package api
 
+import "fmt"
+
 type MTLS struct {
 	API      string   `json:"api" yaml:"api"`
 	Kind     string   `json:"kind" yaml:"kind"`
@@ -16,3 +18,31 @@ type MTLSSpec struct {
 	AllowedIPRanges        []string `json:"allowedIPRanges" yaml:"allowedIPRanges"`
 	StripAnyHostPort       bool     `json:"stripAnyHostPort" yaml:"stripAnyHostPort"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name: "mTLS",
+		New: func() interface{} {
+			return &MTLS{}
+		},
+		Validate: validateMTLS,
+		// the restrictions were documented with a capital letter, and were ignored before the fields were validated
+		DeprecatedFields: map[string]string{
+			"spec.AllowedSubjectAltNames": "spec.allowedSubjectAltNames",
+			"spec.AllowedIPRanges":        "spec.allowedIPRanges",
+		},
+	})
+}
+
+func validateMTLS(data interface{}) error {
+	mTLS := data.(MTLS)
+	if err := validatePort("spec.port", mTLS.Spec.Port); err != nil {
+		return err
+	}
+	for i, ipRange := range mTLS.Spec.AllowedIPRanges {
+		if err := validateCIDR(fmt.Sprintf("spec.allowedIPRanges[%d]", i), ipRange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
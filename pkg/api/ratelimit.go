@@ -18,3 +18,20 @@ type RateLimitDescriptor struct {
 	RemoteAddress      bool   `json:"remoteAddress" yaml:"remoteAddress"`
 	RequestHeader      string `json:"requestHeader" yaml:"requestHeader"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name: "rateLimit",
+		New: func() interface{} {
+			return &RateLimit{}
+		},
+		Validate: validateRateLimit,
+		// Unit was documented with a capital letter, and was ignored before the fields were validated
+		DeprecatedFields: map[string]string{"spec.Unit": "spec.unit"},
+	})
+}
+
+func validateRateLimit(data interface{}) error {
+	rateLimit := data.(RateLimit)
+	return validateOneOf("spec.unit", rateLimit.Spec.Unit, "second", "minute", "hour", "day")
+}
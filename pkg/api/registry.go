@@ -0,0 +1,171 @@
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/juju/loggo"
+)
+
+// Kind describes an object kind. Every kind registers itself with RegisterKind
+type Kind struct {
+	Name string
+	// ImportOrder determines the order of the initial import (lower first, tracing is -1 and rules are 1)
+	ImportOrder int
+	// New returns a pointer to a new object of the kind's type (e.g. &Rule{})
+	New func() interface{}
+	// Validate checks the decoded object (optional)
+	Validate func(data interface{}) error
+	// Dependencies returns the objects that need to be imported first (optional)
+	Dependencies func(data interface{}) []Dependency
+	// DeprecatedFields maps the path of a deprecated field on the path of its replacement, e.g. spec.Unit -> spec.unit.
+	// Deprecated fields are accepted with a warning (optional)
+	DeprecatedFields map[string]string
+}
+
+// Dependency refers to another object by kind and name
+type Dependency struct {
+	Kind string
+	Name string
+}
+
+// FieldError is a validation error of a field. Field is the path within the document, e.g. spec.actions[0].proxy.port
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ObjectError is returned when a document in a file can't be decoded
+type ObjectError struct {
+	Filename string
+	Document int // starts at 1
+	Err      error
+}
+
+func (e *ObjectError) Error() string {
+	if e.Filename == "" {
+		return fmt.Sprintf("document %d: %s", e.Document, e.Err)
+	}
+	return fmt.Sprintf("%s (document %d): %s", e.Filename, e.Document, e.Err)
+}
+
+var (
+	logger = loggo.GetLogger("api")
+
+	kindsMu sync.RWMutex
+	kinds   = make(map[string]Kind)
+)
+
+// RegisterKind adds a kind to the registry. It panics when the kind is registered twice
+func RegisterKind(kind Kind) {
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+	if _, ok := kinds[kind.Name]; ok {
+		panic("kind registered twice: " + kind.Name)
+	}
+	kinds[kind.Name] = kind
+}
+
+func GetKind(name string) (Kind, bool) {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	kind, ok := kinds[name]
+	return kind, ok
+}
+
+// Kinds returns the registered kinds, ordered by import order and name
+func Kinds() []Kind {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	var list []Kind
+	for _, kind := range kinds {
+		list = append(list, kind)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ImportOrder != list[j].ImportOrder {
+			return list[i].ImportOrder < list[j].ImportOrder
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// DecodeObject decodes and validates a document. Unknown kinds and fields are rejected.
+// The use of deprecated fields is logged as a warning
+func DecodeObject(format Format, document []byte) (Object, error) {
+	object, warnings, err := DecodeObjectWithWarnings(format, document)
+	for _, warning := range warnings {
+		logger.Warningf("%s", warning)
+	}
+	return object, err
+}
+
+// DecodeObjectWithWarnings decodes and validates a document like DecodeObject, and returns the warnings about
+// deprecated fields, for the caller to log with the location of the document
+func DecodeObjectWithWarnings(format Format, document []byte) (Object, []string, error) {
+	var object Object
+	err := format.decode(document, &object, false)
+	if err != nil {
+		return object, nil, err
+	}
+	kind, ok := GetKind(object.Kind)
+	if !ok {
+		if object.Kind == "" {
+			return object, nil, fmt.Errorf("kind is missing")
+		}
+		return object, nil, fmt.Errorf("unknown kind %q", object.Kind)
+	}
+	data := kind.New()
+	var raw interface{}
+	err = format.decode(document, &raw, false)
+	if err != nil {
+		return object, nil, err
+	}
+	warnings := renameDeprecatedFields(raw, kind.DeprecatedFields, object.Kind, object.Metadata.Name)
+	if len(warnings) > 0 {
+		document, err = format.encode(raw)
+		if err != nil {
+			return object, warnings, err
+		}
+	}
+	err = checkUnknownFields(raw, reflect.TypeOf(data), "")
+	if err != nil {
+		return object, warnings, err
+	}
+	err = format.decode(document, data, true)
+	if err != nil {
+		return object, warnings, err
+	}
+	object.Data = reflect.ValueOf(data).Elem().Interface()
+	if object.Metadata.Name == "" {
+		return object, warnings, &FieldError{Field: "metadata.name", Message: "is required"}
+	}
+	if kind.Validate != nil {
+		err = kind.Validate(object.Data)
+		if err != nil {
+			return object, warnings, err
+		}
+	}
+	return object, warnings, nil
+}
+
+// GetDependencies returns the dependencies of an object
+func GetDependencies(object Object) []Dependency {
+	kind, ok := GetKind(object.Kind)
+	if !ok || kind.Dependencies == nil {
+		return nil
+	}
+	return kind.Dependencies(object.Data)
+}
+
+// GetImportOrder returns the import order of the object's kind
+func GetImportOrder(object Object) int {
+	kind, _ := GetKind(object.Kind)
+	return kind.ImportOrder
+}
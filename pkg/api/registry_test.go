@@ -0,0 +1,187 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeObject(t *testing.T) {
+	document := `api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  auth:
+    jwtProvider: test-jwt
+  conditions:
+    - hostname: test1.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+`
+	object, err := DecodeObject(FormatYAML, []byte(document))
+	if err != nil {
+		t.Errorf("DecodeObject error: %s", err)
+		return
+	}
+	rule, ok := object.Data.(Rule)
+	if !ok || rule.Spec.Actions[0].Proxy.Port != 443 {
+		t.Errorf("Unexpected object: %+v", object)
+		return
+	}
+	dependencies := GetDependencies(object)
+	if len(dependencies) != 1 || dependencies[0] != (Dependency{Kind: "jwtProvider", Name: "test-jwt"}) {
+		t.Errorf("Unexpected dependencies: %+v", dependencies)
+	}
+
+	_, err = DecodeObject(FormatYAML, []byte("kind: doesnotexist\nmetadata:\n  name: test1\n"))
+	if err == nil || err.Error() != `unknown kind "doesnotexist"` {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	objectErr := &ObjectError{Filename: "rules.yaml", Document: 2, Err: err}
+	if objectErr.Error() != `rules.yaml (document 2): unknown kind "doesnotexist"` {
+		t.Errorf("Unexpected error: %s", objectErr)
+	}
+}
+
+func TestKinds(t *testing.T) {
+	kinds := Kinds()
+	if len(kinds) != 8 {
+		t.Errorf("Expected 8 kinds, got %d", len(kinds))
+		return
+	}
+	if kinds[0].Name != "tracing" || kinds[len(kinds)-1].Name != "rule" {
+		t.Errorf("Unexpected import order: first %s, last %s", kinds[0].Name, kinds[len(kinds)-1].Name)
+	}
+}
+
+func TestDecodeObjectValidation(t *testing.T) {
+	tests := []struct {
+		format   Format
+		document string
+		err      string
+	}{
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: rule\nmetadata:\n  name: test1\nspec:\n  actions:\n    - proxy:\n        hostname: example.com\n        port: 443\n        healtCheck:\n          interval: 3s\n",
+			err:      "spec.actions[0].proxy.healtCheck: unknown field",
+		},
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: rule\nmetadata:\n  name: test1\nspec:\n  conditions:\n    - regex: \"/test[\"\n",
+			err:      "spec.conditions[0].regex: invalid regex: error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: rule\nmetadata:\n  name: test1\nspec:\n  actions:\n    - proxy:\n        hostname: example.com\n        port: 443\n        healthCheck:\n          interval: 3\n",
+			err:      `spec.actions[0].proxy.healthCheck.interval: invalid duration "3" (e.g. 30s, 1m)`,
+		},
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: rule\nmetadata:\n  name: test1\nspec:\n  actions:\n    - proxy:\n        hostname: example.com\n        port: 70000\n",
+			err:      "spec.actions[0].proxy.port: port 70000 out of range (1-65535)",
+		},
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: mTLS\nmetadata:\n  name: test1\nspec:\n  port: 10002\n  allowedIPRanges:\n    - 10.0.0.0/8\n    - 192.168.0.1\n",
+			err:      `spec.allowedIPRanges[1]: invalid ip range "192.168.0.1" (e.g. 10.0.0.0/8)`,
+		},
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: jwtProvider\nspec:\n  issuer: https://example.com\n",
+			err:      "metadata.name: is required",
+		},
+		{
+			format:   FormatJSON,
+			document: `{"api": "proxy.in4it.io/v1", "kind": "rateLimit", "metadata": {"name": "test1"}, "spec": {"unit": "week"}}`,
+			err:      `spec.unit: invalid value "week" (allowed: [second minute hour day])`,
+		},
+		{
+			format:   FormatJSON,
+			document: `{"api": "proxy.in4it.io/v1", "kind": "rateLimit", "metadata": {"name": "test1"}, "spec": {"unit": "hour", "Descriptors": []}}`,
+			err:      "spec.Descriptors: unknown field",
+		},
+		{
+			format:   FormatJSON,
+			document: `{"api": "proxy.in4it.io/v1", "kind": "rateLimit", "metadata": {"name": "test1"}, "spec": {"Unit": "week"}}`,
+			err:      `spec.unit: invalid value "week" (allowed: [second minute hour day])`,
+		},
+		{
+			format:   FormatJSON,
+			document: `{"api": "proxy.in4it.io/v1", "kind": "rateLimit", "metadata": {"name": "test1"}, "spec": {"unit": "hour", "descriptors": [{"remoteAddress": true}]}}`,
+		},
+	}
+	for i, test := range tests {
+		_, err := DecodeObject(test.format, []byte(test.document))
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("Test %d: unexpected error: %s", i, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.err {
+			t.Errorf("Test %d: expected error %q, got: %v", i, test.err, err)
+		}
+	}
+}
+
+func TestDecodeObjectDeprecatedFields(t *testing.T) {
+	tests := []struct {
+		format   Format
+		document string
+		unit     string
+	}{
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: rateLimit\nmetadata:\n  name: test1\nspec:\n  descriptors:\n    - remoteAddress: true\n  requestPerUnit: 1\n  Unit: hour\n",
+			unit:     "hour",
+		},
+		{
+			format:   FormatJSON,
+			document: `{"api": "proxy.in4it.io/v1", "kind": "rateLimit", "metadata": {"name": "test1"}, "spec": {"Unit": "minute"}}`,
+			unit:     "minute",
+		},
+		{
+			format:   FormatYAML,
+			document: "api: proxy.in4it.io/v1\nkind: rateLimit\nmetadata:\n  name: test1\nspec:\n  unit: day\n  Unit: hour\n",
+			unit:     "day",
+		},
+	}
+	for i, test := range tests {
+		object, err := DecodeObject(test.format, []byte(test.document))
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %s", i, err)
+			continue
+		}
+		if unit := object.Data.(RateLimit).Spec.Unit; unit != test.unit {
+			t.Errorf("Test %d: expected unit %s, got: %s", i, test.unit, unit)
+		}
+	}
+
+	object, err := DecodeObject(FormatYAML, []byte("api: proxy.in4it.io/v1\nkind: mTLS\nmetadata:\n  name: test1\nspec:\n  port: 10002\n  AllowedSubjectAltNames: [\"client1.example.com\"]\n  AllowedIPRanges: [\"1.2.3.4/16\"]\n"))
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+		return
+	}
+	mTLS := object.Data.(MTLS)
+	if len(mTLS.Spec.AllowedSubjectAltNames) != 1 || len(mTLS.Spec.AllowedIPRanges) != 1 || mTLS.Spec.AllowedIPRanges[0] != "1.2.3.4/16" {
+		t.Errorf("Unexpected mTLS spec: %+v", mTLS.Spec)
+	}
+}
+
+func TestDecodeObjectWithWarnings(t *testing.T) {
+	_, warnings, err := DecodeObjectWithWarnings(FormatYAML, []byte("api: proxy.in4it.io/v1\nkind: mTLS\nmetadata:\n  name: test1\nspec:\n  port: 10002\n  allowedSubjectAltNames: [\"client1.example.com\"]\n  AllowedIPRanges: [\"1.2.3.4/16\"]\n"))
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+		return
+	}
+	expected := []string{"mTLS test1: spec.AllowedIPRanges is deprecated, use spec.allowedIPRanges"}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+	_, warnings, err = DecodeObjectWithWarnings(FormatYAML, []byte("api: proxy.in4it.io/v1\nkind: mTLS\nmetadata:\n  name: test1\nspec:\n  port: 10002\n"))
+	if err != nil || len(warnings) > 0 {
+		t.Errorf("Unexpected warnings: %v (error: %v)", warnings, err)
+	}
+}
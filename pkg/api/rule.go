@@ -1,5 +1,7 @@
 package api
 
+import "fmt"
+
 type Metadata struct {
 	Name string `json:"name"`
 }
@@ -62,3 +64,61 @@ type RuleActionsDirectResponse struct {
 type Listener struct {
 	MTLS string `json:"mTLS" yaml:"mTLS"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "rule",
+		ImportOrder: 1,
+		New: func() interface{} {
+			return &Rule{}
+		},
+		Validate: validateRule,
+		Dependencies: func(data interface{}) []Dependency {
+			rule := data.(Rule)
+			var dependencies []Dependency
+			if rule.Spec.Auth.JwtProvider != "" {
+				dependencies = append(dependencies, Dependency{Kind: "jwtProvider", Name: rule.Spec.Auth.JwtProvider})
+			}
+			if rule.Spec.Listener.MTLS != "" {
+				dependencies = append(dependencies, Dependency{Kind: "mTLS", Name: rule.Spec.Listener.MTLS})
+			}
+			return dependencies
+		},
+	})
+}
+
+func validateRule(data interface{}) error {
+	rule := data.(Rule)
+	for i, condition := range rule.Spec.Conditions {
+		field := fmt.Sprintf("spec.conditions[%d]", i)
+		if err := validateRegex(field+".regex", condition.Regex); err != nil {
+			return err
+		}
+		for j, method := range condition.Methods {
+			if err := validateOneOf(fmt.Sprintf("%s.methods[%d]", field, j), method, "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE"); err != nil {
+				return err
+			}
+		}
+	}
+	for i, action := range rule.Spec.Actions {
+		field := fmt.Sprintf("spec.actions[%d]", i)
+		healthCheck := action.Proxy.HealthCheck
+		err := firstError(
+			validatePort(field+".proxy.port", action.Proxy.Port),
+			validateDuration(field+".proxy.healthCheck.timeout", healthCheck.Timeout),
+			validateDuration(field+".proxy.healthCheck.interval", healthCheck.Interval),
+			validateDuration(field+".proxy.healthCheck.unhealthyInterval", healthCheck.UnhealthyInterval),
+			validateRegex(field+".proxy.regexRewrite.regex", action.Proxy.RegexRewrite.Regex),
+		)
+		if err != nil {
+			return err
+		}
+		if action.Proxy.Hostname != "" && action.Proxy.Port == 0 {
+			return &FieldError{Field: field + ".proxy.port", Message: "is required"}
+		}
+		if status := action.DirectResponse.Status; status != 0 && (status < 100 || status > 599) {
+			return &FieldError{Field: field + ".directResponse.status", Message: fmt.Sprintf("invalid status %d", status)}
+		}
+	}
+	return nil
+}
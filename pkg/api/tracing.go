@@ -15,3 +15,23 @@ type TracingSpec struct {
 	ProviderName     string   `json:"providerName" yaml:"providerName"`
 	CollectorCluster string   `json:"collectorCluster" yaml:"collectorCluster"`
 }
+
+func init() {
+	RegisterKind(Kind{
+		Name:        "tracing",
+		ImportOrder: -1,
+		New: func() interface{} {
+			return &Tracing{}
+		},
+		Validate: validateTracing,
+	})
+}
+
+func validateTracing(data interface{}) error {
+	tracing := data.(Tracing)
+	return firstError(
+		validateRange("spec.clientSampling", tracing.Spec.ClientSampling, 0, 100),
+		validateRange("spec.randomSampling", tracing.Spec.RandomSampling, 0, 100),
+		validateRange("spec.overallSampling", tracing.Spec.OverallSampling, 0, 100),
+	)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// validatePort checks whether the port is in range. 0 means no port
+func validatePort(field string, port int64) error {
+	if port < 0 || port > 65535 {
+		return &FieldError{Field: field, Message: fmt.Sprintf("port %d out of range (1-65535)", port)}
+	}
+	return nil
+}
+
+// validateDuration checks whether the value is a valid duration (e.g. 30s). An empty value is allowed
+func validateDuration(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		return &FieldError{Field: field, Message: fmt.Sprintf("invalid duration %q (e.g. 30s, 1m)", value)}
+	}
+	return nil
+}
+
+// validateRegex checks whether the regular expression compiles. An empty value is allowed
+func validateRegex(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(value); err != nil {
+		return &FieldError{Field: field, Message: fmt.Sprintf("invalid regex: %s", err)}
+	}
+	return nil
+}
+
+func validateCIDR(field, value string) error {
+	if _, _, err := net.ParseCIDR(value); err != nil {
+		return &FieldError{Field: field, Message: fmt.Sprintf("invalid ip range %q (e.g. 10.0.0.0/8)", value)}
+	}
+	return nil
+}
+
+// validateURL checks whether the value is an absolute url. An empty value is allowed
+func validateURL(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return &FieldError{Field: field, Message: fmt.Sprintf("invalid url %q", value)}
+	}
+	return nil
+}
+
+func validateRange(field string, value, min, max float64) error {
+	if value < min || value > max {
+		return &FieldError{Field: field, Message: fmt.Sprintf("%v out of range (%v-%v)", value, min, max)}
+	}
+	return nil
+}
+
+func validateOneOf(field, value string, allowed ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, v := range allowed {
+		if value == v {
+			return nil
+		}
+	}
+	return &FieldError{Field: field, Message: fmt.Sprintf("invalid value %q (allowed: %v)", value, allowed)}
+}
+
+// firstError returns the first error that is not nil
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	encryptedPEMType = "ROXPROX ENCRYPTED DATA"
+	dataKeySize      = 32
+)
+
+// KeyProvider wraps and unwraps the data keys of the envelope encryption
+type KeyProvider interface {
+	Name() string
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// Envelope encrypts data with a random data key, which is wrapped by the key provider and stored next to the data
+type Envelope struct {
+	provider KeyProvider
+}
+
+func NewEnvelope(provider KeyProvider) *Envelope {
+	return &Envelope{provider: provider}
+}
+
+// IsEncrypted returns true when the data was encrypted by an Envelope
+func IsEncrypted(data []byte) bool {
+	block, _ := pem.Decode(data)
+	return block != nil && block.Type == encryptedPEMType
+}
+
+func (e *Envelope) Encrypt(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := e.provider.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't wrap data key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type: encryptedPEMType,
+		Headers: map[string]string{
+			"Key-Provider": e.provider.Name(),
+			"Wrapped-Key":  base64.StdEncoding.EncodeToString(wrappedKey),
+			"Nonce":        base64.StdEncoding.EncodeToString(nonce),
+		},
+		Bytes: ciphertext,
+	}), nil
+}
+
+func (e *Envelope) Decrypt(data []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != encryptedPEMType {
+		return nil, errors.New("Data is not encrypted")
+	}
+	if block.Headers["Key-Provider"] != e.provider.Name() {
+		return nil, fmt.Errorf("Data is encrypted with key provider %s, not %s", block.Headers["Key-Provider"], e.provider.Name())
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(block.Headers["Wrapped-Key"])
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decode wrapped key: %s", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decode nonce: %s", err)
+	}
+	dataKey, err := e.provider.UnwrapKey(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't unwrap data key: %s", err)
+	}
+	return open(dataKey, nonce, block.Bytes)
+}
+
+// seal encrypts the plaintext with AES-GCM and returns the nonce and the ciphertext
+func seal(key, plaintext []byte) ([]byte, []byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, nil), nil
+}
+
+func open(key, nonce, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("Nonce has wrong size")
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decrypt data: %s", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealWithNonce returns the nonce followed by the ciphertext
+func sealWithNonce(key, plaintext []byte) ([]byte, error) {
+	nonce, ciphertext, err := seal(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+func openWithNonce(key, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("Wrapped key too short")
+	}
+	return open(key, data[:aead.NonceSize()], data[aead.NonceSize():])
+}
@@ -0,0 +1,114 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const saltSize = 16
+
+// StaticKeyProvider wraps the data keys with a fixed 256-bit key
+type StaticKeyProvider struct {
+	name string
+	key  []byte
+}
+
+func NewStaticKeyProvider(key []byte) (*StaticKeyProvider, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("Key must be %d bytes, got %d bytes", dataKeySize, len(key))
+	}
+	return &StaticKeyProvider{name: "static", key: key}, nil
+}
+
+// NewKeyFileProvider reads a 256-bit key from a file (raw or base64 encoded, e.g. openssl rand -base64 32)
+func NewKeyFileProvider(filename string) (*StaticKeyProvider, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read key file: %s", err)
+	}
+	key := contents
+	if len(key) != dataKeySize {
+		key, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(contents)))
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't decode key file: %s", err)
+		}
+	}
+	provider, err := NewStaticKeyProvider(key)
+	if err != nil {
+		return nil, err
+	}
+	provider.name = "keyfile"
+	return provider, nil
+}
+
+func (s *StaticKeyProvider) Name() string {
+	return s.name
+}
+func (s *StaticKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	return sealWithNonce(s.key, dataKey)
+}
+func (s *StaticKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return openWithNonce(s.key, wrappedKey)
+}
+
+// PassphraseKeyProvider wraps the data keys with a key derived from a passphrase (scrypt). Every wrapped key has its own salt
+type PassphraseKeyProvider struct {
+	passphrase []byte
+	mu         sync.Mutex
+	keys       map[string][]byte // derived key per salt
+}
+
+func NewPassphraseKeyProvider(passphrase string) (*PassphraseKeyProvider, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("Passphrase is empty")
+	}
+	return &PassphraseKeyProvider{passphrase: []byte(passphrase), keys: make(map[string][]byte)}, nil
+}
+
+func (p *PassphraseKeyProvider) Name() string {
+	return "passphrase"
+}
+func (p *PassphraseKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := p.deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := sealWithNonce(key, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return append(salt, wrappedKey...), nil
+}
+func (p *PassphraseKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	if len(wrappedKey) < saltSize {
+		return nil, fmt.Errorf("Wrapped key too short")
+	}
+	key, err := p.deriveKey(wrappedKey[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return openWithNonce(key, wrappedKey[saltSize:])
+}
+func (p *PassphraseKeyProvider) deriveKey(salt []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key(p.passphrase, salt, 1<<15, 8, 1, dataKeySize)
+	if err != nil {
+		return nil, err
+	}
+	p.keys[string(salt)] = key
+	return key, nil
+}
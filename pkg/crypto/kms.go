@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// KMSKeyProvider wraps the data keys with an AWS KMS key
+type KMSKeyProvider struct {
+	keyID string
+	svc   kmsiface.KMSAPI
+}
+
+func NewKMSKeyProvider(keyID, region string) (*KMSKeyProvider, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't initialize KMS: %s", err)
+	}
+	return NewKMSKeyProviderWithClient(keyID, kms.New(sess)), nil
+}
+
+func NewKMSKeyProviderWithClient(keyID string, svc kmsiface.KMSAPI) *KMSKeyProvider {
+	return &KMSKeyProvider{keyID: keyID, svc: svc}
+}
+
+func (k *KMSKeyProvider) Name() string {
+	return "kms"
+}
+func (k *KMSKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	output, err := k.svc.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(k.keyID),
+		Plaintext: dataKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.CiphertextBlob, nil
+}
+func (k *KMSKeyProvider) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	output, err := k.svc.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(k.keyID),
+		CiphertextBlob: wrappedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
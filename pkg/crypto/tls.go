@@ -0,0 +1,89 @@
+package crypto
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSFiles are the paths of the PEM files for a TLS server or client
+type TLSFiles struct {
+	Cert string // certificate (chain) to present
+	Key  string // private key of the certificate
+	CA   string // CA certificates to verify the other side with
+}
+
+// NewServerTLSConfig returns the TLS config of a server. Client certificates are required and verified when a CA is set
+func NewServerTLSConfig(files TLSFiles) (*tls.Config, error) {
+	if files.Cert == "" || files.Key == "" {
+		return nil, fmt.Errorf("A certificate and a key are required")
+	}
+	cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load certificate: %s", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if files.CA != "" {
+		pool, err := loadCertPool(files.CA)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// NewClientTLSConfig returns the TLS config of a client. The client certificate is optional,
+// the server is verified with the CA when set, or with the system roots otherwise
+func NewClientTLSConfig(files TLSFiles, serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if files.Cert != "" || files.Key != "" {
+		cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if files.CA != "" {
+		pool, err := loadCertPool(files.CA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// GetCertificateIdentities returns the subject alternative names of a certificate: dns names, ip addresses, email addresses and uris
+func GetCertificateIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	identities = append(identities, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		identities = append(identities, ip.String())
+	}
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	return identities
+}
+
+func loadCertPool(fileName string) (*x509.CertPool, error) {
+	caCert, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read CA certificate: %s", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("No CA certificates found in %s", fileName)
+	}
+	return pool, nil
+}
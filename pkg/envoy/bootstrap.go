@@ -0,0 +1,231 @@
+package envoy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	clusterAPI "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes"
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+)
+
+const xdsClusterName = "xds_cluster"
+
+// BootstrapOptions configures the envoy bootstrap of a node that connects to the control plane
+type BootstrapOptions struct {
+	NodeID      string
+	NodeCluster string
+	XDSAddress  string
+	XDSPort     uint32 // defaults to 8080
+	// ADS uses one aggregated stream for clusters and listeners, otherwise clusters and listeners are requested separately
+	ADS          bool
+	AdminAddress string // defaults to 127.0.0.1
+	AdminPort    uint32 // defaults to 9901
+	// RateLimitAddress is the address of the rate limit service, required when there are rateLimit objects
+	RateLimitAddress string
+	RateLimitPort    uint32 // defaults to 8081
+	// TracingAddress is the address of the tracing collector, required when there are tracing objects
+	TracingAddress string
+	TracingPort    uint32 // defaults to 8126 (datadog agent)
+	// XDSTLS connects to the control plane with TLS (control plane started with -grpc-tls-cert). It's enabled
+	// when one of the TLS paths is set. The paths are the paths of the PEM files on the envoy node
+	XDSTLS            bool
+	XDSCACertPath     string // CA certificates to verify the control plane with
+	XDSClientCertPath string // client certificate, required when the control plane is started with -grpc-tls-client-ca
+	XDSClientKeyPath  string // key of the client certificate
+	XDSTLSServerName  string // server name (SNI) of the control plane, defaults to the xds address
+}
+
+// NewBootstrap creates the envoy bootstrap for a node of the control plane. The bootstrap contains the xds cluster,
+// and the clusters that the objects refer to by name: the rate limit service, the tracing collector and the access log servers
+func NewBootstrap(objects []pkgApi.Object, options BootstrapOptions) (*bootstrap.Bootstrap, error) {
+	if options.XDSAddress == "" {
+		return nil, fmt.Errorf("xds address is required")
+	}
+	if options.XDSPort == 0 {
+		options.XDSPort = 8080
+	}
+	if options.AdminAddress == "" {
+		options.AdminAddress = "127.0.0.1"
+	}
+	if options.AdminPort == 0 {
+		options.AdminPort = 9901
+	}
+	if options.RateLimitPort == 0 {
+		options.RateLimitPort = 8081
+	}
+	if options.TracingPort == 0 {
+		options.TracingPort = 8126
+	}
+
+	if (options.XDSClientCertPath == "") != (options.XDSClientKeyPath == "") {
+		return nil, fmt.Errorf("the client certificate and key of the xds connection must be set together")
+	}
+
+	c := newCluster()
+	xdsCluster := c.createCluster(ClusterParams{Name: xdsClusterName, TargetHostname: options.XDSAddress, Port: int64(options.XDSPort), HTTP2: true})
+	if options.XDSTLS || options.XDSCACertPath != "" || options.XDSClientCertPath != "" || options.XDSTLSServerName != "" {
+		transportSocket, err := getBootstrapXDSTransportSocket(options)
+		if err != nil {
+			return nil, err
+		}
+		xdsCluster.TransportSocket = transportSocket
+	}
+	clusters := []*clusterAPI.Cluster{xdsCluster}
+	clusterNames := map[string]bool{xdsClusterName: true}
+	addCluster := func(params ClusterParams) {
+		if !clusterNames[params.Name] {
+			clusterNames[params.Name] = true
+			clusters = append(clusters, c.createCluster(params))
+		}
+	}
+	for _, object := range objects {
+		switch data := object.Data.(type) {
+		case pkgApi.RateLimit:
+			if options.RateLimitAddress == "" {
+				return nil, fmt.Errorf("rateLimit %s needs the address of the rate limit service", object.Metadata.Name)
+			}
+			addCluster(ClusterParams{Name: "ratelimit", TargetHostname: options.RateLimitAddress, Port: int64(options.RateLimitPort), HTTP2: true})
+		case pkgApi.Tracing:
+			if options.TracingAddress == "" {
+				return nil, fmt.Errorf("tracing %s needs the address of the tracing collector", object.Metadata.Name)
+			}
+			addCluster(ClusterParams{Name: getTracingCollectorCluster(data), TargetHostname: options.TracingAddress, Port: int64(options.TracingPort)})
+		case pkgApi.AccessLogServer:
+			addCluster(ClusterParams{Name: object.Metadata.Name, TargetHostname: data.Spec.Address, Port: data.Spec.Port, HTTP2: true})
+		}
+	}
+	// the xds cluster first, then the other clusters in alphabetical order
+	sort.SliceStable(clusters[1:], func(i, j int) bool {
+		return clusters[i+1].Name < clusters[j+1].Name
+	})
+
+	b := &bootstrap.Bootstrap{
+		Node: &core.Node{
+			Id:      options.NodeID,
+			Cluster: options.NodeCluster,
+		},
+		DynamicResources: getBootstrapDynamicResources(options.ADS),
+		StaticResources: &bootstrap.Bootstrap_StaticResources{
+			Clusters: clusters,
+		},
+		Admin: getBootstrapAdmin(options.AdminAddress, options.AdminPort),
+	}
+	if err := validateBootstrap(b); err != nil {
+		return nil, fmt.Errorf("Validation of the bootstrap failed: %s", err)
+	}
+	return b, nil
+}
+
+// getBootstrapXDSTransportSocket returns the TLS transport socket of the xds cluster. The certificates are read by envoy from the paths
+func getBootstrapXDSTransportSocket(options BootstrapOptions) (*core.TransportSocket, error) {
+	serverName := options.XDSTLSServerName
+	if serverName == "" {
+		serverName = options.XDSAddress
+	}
+	commonTlsContext := &tls.CommonTlsContext{}
+	if options.XDSClientCertPath != "" {
+		commonTlsContext.TlsCertificates = []*tls.TlsCertificate{
+			{
+				CertificateChain: &core.DataSource{
+					Specifier: &core.DataSource_Filename{Filename: options.XDSClientCertPath},
+				},
+				PrivateKey: &core.DataSource{
+					Specifier: &core.DataSource_Filename{Filename: options.XDSClientKeyPath},
+				},
+			},
+		}
+	}
+	if options.XDSCACertPath != "" {
+		commonTlsContext.ValidationContextType = &tls.CommonTlsContext_ValidationContext{
+			ValidationContext: &tls.CertificateValidationContext{
+				TrustedCa: &core.DataSource{
+					Specifier: &core.DataSource_Filename{Filename: options.XDSCACertPath},
+				},
+				MatchSubjectAltNames: []*matcher.StringMatcher{
+					{MatchPattern: &matcher.StringMatcher_Exact{Exact: serverName}},
+				},
+			},
+		}
+	}
+	tlsContext, err := ptypes.MarshalAny(&tls.UpstreamTlsContext{
+		Sni:              serverName,
+		CommonTlsContext: commonTlsContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &core.TransportSocket{
+		Name: "envoy.transport_sockets.tls",
+		ConfigType: &core.TransportSocket_TypedConfig{
+			TypedConfig: tlsContext,
+		},
+	}, nil
+}
+
+// getTracingCollectorCluster returns the collector cluster of a tracing object, with the same defaults as importTracing
+func getTracingCollectorCluster(tracing pkgApi.Tracing) string {
+	if tracing.Spec.CollectorCluster == "" && (tracing.Spec.ProviderName == "" || strings.ToLower(tracing.Spec.ProviderName) == "datadog") {
+		return "datadog_agent"
+	}
+	return tracing.Spec.CollectorCluster
+}
+
+func getBootstrapDynamicResources(ads bool) *bootstrap.Bootstrap_DynamicResources {
+	apiConfigSource := &core.ApiConfigSource{
+		ApiType:             core.ApiConfigSource_GRPC,
+		TransportApiVersion: core.ApiVersion_V3,
+		GrpcServices: []*core.GrpcService{
+			{
+				TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &core.GrpcService_EnvoyGrpc{
+						ClusterName: xdsClusterName,
+					},
+				},
+			},
+		},
+	}
+	if ads {
+		adsConfigSource := func() *core.ConfigSource {
+			return &core.ConfigSource{
+				ConfigSourceSpecifier: &core.ConfigSource_Ads{Ads: &core.AggregatedConfigSource{}},
+				ResourceApiVersion:    core.ApiVersion_V3,
+			}
+		}
+		return &bootstrap.Bootstrap_DynamicResources{
+			AdsConfig: apiConfigSource,
+			CdsConfig: adsConfigSource(),
+			LdsConfig: adsConfigSource(),
+		}
+	}
+	configSource := func() *core.ConfigSource {
+		return &core.ConfigSource{
+			ConfigSourceSpecifier: &core.ConfigSource_ApiConfigSource{ApiConfigSource: apiConfigSource},
+			ResourceApiVersion:    core.ApiVersion_V3,
+		}
+	}
+	return &bootstrap.Bootstrap_DynamicResources{
+		CdsConfig: configSource(),
+		LdsConfig: configSource(),
+	}
+}
+
+func getBootstrapAdmin(address string, port uint32) *bootstrap.Admin {
+	return &bootstrap.Admin{
+		Address: &core.Address{
+			Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Address: address,
+					PortSpecifier: &core.SocketAddress_PortValue{
+						PortValue: port,
+					},
+				},
+			},
+		},
+	}
+}
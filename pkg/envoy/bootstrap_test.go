@@ -0,0 +1,109 @@
+package envoy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+)
+
+func TestBootstrap(t *testing.T) {
+	tests := []struct {
+		golden    string
+		filenames []string
+		options   BootstrapOptions
+	}{
+		{
+			golden:    "bootstrap-ads.yaml.golden",
+			filenames: []string{"test1.yaml", "test-ratelimit.yaml", "test-tracing.yaml", "test-accesslogserver.yaml"},
+			options: BootstrapOptions{
+				NodeID:           "ingress-gateway-1",
+				NodeCluster:      "ingress-gateway",
+				XDSAddress:       "roxprox.example.com",
+				ADS:              true,
+				RateLimitAddress: "ratelimit.example.com",
+				TracingAddress:   "datadog.example.com",
+			},
+		},
+		{
+			golden:    "bootstrap-xds.yaml.golden",
+			filenames: []string{"test1.yaml"},
+			options: BootstrapOptions{
+				NodeID:       "ingress-gateway-2",
+				NodeCluster:  "ingress-gateway",
+				XDSAddress:   "10.0.0.1",
+				XDSPort:      18000,
+				AdminAddress: "0.0.0.0",
+			},
+		},
+		{
+			golden:    "bootstrap-xds-tls.yaml.golden",
+			filenames: []string{"test1.yaml"},
+			options: BootstrapOptions{
+				NodeID:            "ingress-gateway-3",
+				NodeCluster:       "ingress-gateway",
+				XDSAddress:        "10.0.0.1",
+				ADS:               true,
+				XDSCACertPath:     "/etc/envoy/xds-ca.crt",
+				XDSClientCertPath: "/etc/envoy/xds-client.crt",
+				XDSClientKeyPath:  "/etc/envoy/xds-client.key",
+				XDSTLSServerName:  "roxprox.example.com",
+			},
+		},
+	}
+	for _, test := range tests {
+		config, err := renderTestdata(test.filenames)
+		if err != nil {
+			t.Errorf("Render error: %s", err)
+			return
+		}
+		b, err := NewBootstrap(config.Objects, test.options)
+		if err != nil {
+			t.Errorf("NewBootstrap error: %s", err)
+			return
+		}
+		out, err := MarshalBootstrap(b, pkgApi.FormatYAML)
+		if err != nil {
+			t.Errorf("MarshalBootstrap error: %s", err)
+			return
+		}
+		if *updateGolden {
+			if err := ioutil.WriteFile("testdata/"+test.golden, out, 0644); err != nil {
+				t.Errorf("Couldn't update golden file: %s", err)
+			}
+			continue
+		}
+		golden, err := ioutil.ReadFile("testdata/" + test.golden)
+		if err != nil {
+			t.Errorf("Couldn't read golden file: %s", err)
+			return
+		}
+		if !bytes.Equal(out, golden) {
+			t.Errorf("Bootstrap doesn't match %s (run go test -update to update the golden file):\n%s", test.golden, out)
+		}
+		if envoyPath, err := exec.LookPath("envoy"); err == nil {
+			if out, err := exec.Command(envoyPath, "--mode", "validate", "-c", "testdata/"+test.golden).CombinedOutput(); err != nil {
+				t.Errorf("Envoy validation of %s failed: %s\n%s", test.golden, err, out)
+			}
+		}
+	}
+
+	// the address of the rate limit service is required when there are rateLimit objects
+	config, err := renderTestdata([]string{"test1.yaml", "test-ratelimit.yaml"})
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+	_, err = NewBootstrap(config.Objects, BootstrapOptions{XDSAddress: "roxprox.example.com"})
+	if err == nil || err.Error() != "rateLimit ratelimit-1 needs the address of the rate limit service" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err = NewBootstrap(nil, BootstrapOptions{}); err == nil {
+		t.Errorf("Expected error without xds address")
+	}
+	if _, err = NewBootstrap(nil, BootstrapOptions{XDSAddress: "roxprox.example.com", XDSClientCertPath: "/etc/envoy/xds-client.crt"}); err == nil {
+		t.Errorf("Expected error with a client certificate without key")
+	}
+}
@@ -2,6 +2,7 @@ package envoy
 
 import (
 	"context"
+	"sync"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -10,6 +11,7 @@ import (
 type Callback struct {
 	waitForEnvoy chan struct{}
 	newNode      chan NewNode
+	mu           sync.Mutex
 	connections  map[int64]*core.Node
 }
 type NewNode struct {
@@ -36,15 +38,16 @@ func (c *Callback) OnDeltaStreamOpen(ctx context.Context, id int64, typ string)
 
 func (c *Callback) OnStreamClosed(id int64) {
 	logger.Tracef("OnStreamClosed %d closed", id)
+	c.removeConnection(id)
 }
 func (c *Callback) OnDeltaStreamClosed(id int64) {
 	logger.Tracef("OnDeltaStreamClosed %d closed", id)
-
+	c.removeConnection(id)
 }
 func (c *Callback) OnStreamRequest(id int64, req *discovery.DiscoveryRequest) error {
 	logger.Tracef("OnStreamRequest: %d %+v", id, req)
-	if _, ok := c.connections[id]; !ok {
-		c.connections[id] = req.Node
+	recordXDSResponse(c.getNodeID(id, req.Node), req.TypeUrl, req.ResponseNonce, req.ErrorDetail != nil)
+	if c.addConnection(id, req.Node) {
 		c.newNode <- NewNode{id: req.Node.Id}
 	}
 	if c.waitForEnvoy != nil {
@@ -55,8 +58,8 @@ func (c *Callback) OnStreamRequest(id int64, req *discovery.DiscoveryRequest) er
 }
 func (c *Callback) OnStreamDeltaRequest(id int64, req *discovery.DeltaDiscoveryRequest) error {
 	logger.Tracef("OnStreamDeltaRequest: %d %+v", id, req)
-	if _, ok := c.connections[id]; !ok {
-		c.connections[id] = req.Node
+	recordXDSResponse(c.getNodeID(id, req.Node), req.TypeUrl, req.ResponseNonce, req.ErrorDetail != nil)
+	if c.addConnection(id, req.Node) {
 		c.newNode <- NewNode{id: req.Node.Id}
 	}
 	if c.waitForEnvoy != nil {
@@ -79,3 +82,51 @@ func (c *Callback) OnStreamDeltaResponse(id int64, req *discovery.DeltaDiscovery
 }
 
 func (c *Callback) OnFetchResponse(*discovery.DiscoveryRequest, *discovery.DiscoveryResponse) {}
+
+// addConnection returns true when the stream is new
+func (c *Callback) addConnection(id int64, node *core.Node) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.connections[id]; ok {
+		return false
+	}
+	c.connections[id] = node
+	c.updateConnectedNodes()
+	return true
+}
+func (c *Callback) removeConnection(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.connections, id)
+	c.updateConnectedNodes()
+}
+
+// updateConnectedNodes sets the connected nodes metric (c.mu must be locked)
+func (c *Callback) updateConnectedNodes() {
+	nodes := make(map[string]bool)
+	for _, node := range c.connections {
+		nodes[node.GetId()] = true
+	}
+	connectedNodesMetric.Set(float64(len(nodes)))
+}
+
+// getNodeID returns the node id of a stream. Envoy only sends the node in the first request of a stream
+func (c *Callback) getNodeID(id int64, node *core.Node) string {
+	if node != nil {
+		return node.Id
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connections[id].GetId()
+}
+
+// getConnections returns the nodes of the open streams
+func (c *Callback) getConnections() []*core.Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	nodes := make([]*core.Node, 0, len(c.connections))
+	for _, node := range c.connections {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
@@ -4,44 +4,148 @@ import (
 	"crypto/rsa"
 	"encoding/json"
 	"encoding/pem"
+	"fmt"
 	"strings"
 
 	acme "github.com/in4it/roxprox/pkg/acme"
+	"github.com/in4it/roxprox/pkg/crypto"
 	storage "github.com/in4it/roxprox/pkg/storage"
+	xacme "golang.org/x/crypto/acme"
 )
 
 type Cert struct {
-	a *acme.Acme
-	s storage.Storage
+	a       *acme.Acme
+	s       storage.Storage
+	account AcmeAccount
 }
 
-func newCert(s storage.Storage, acmeContact string) (*Cert, error) {
-	c := &Cert{s: s}
+// AcmeAccount selects the ACME account used to issue certificates.
+// Named accounts (for example per CA or per tenant) are kept under separate storage paths
+type AcmeAccount struct {
+	Name         string
+	Contact      string
+	DirectoryURL string
+}
+
+func newCert(s storage.Storage, account AcmeAccount) (*Cert, error) {
+	c := &Cert{s: s, account: account}
 	logger.Debugf("Initializing acme")
-	accountKey, err := c.s.GetPrivateAccountkey()
+	if err := validateAcmeAccountName(account.Name); err != nil {
+		return c, err
+	}
+	if err := c.recoverAccountKey(); err != nil {
+		return c, err
+	}
+	accountKey, err := c.s.GetPrivateAccountkey(account.Name)
 	if err == c.s.GetError("errNotExist") {
-		err = c.s.CreateAccountKey()
+		err = c.s.CreateAccountKey(account.Name)
 		if err != nil {
 			return c, err
 		}
-		accountKey, err = c.s.GetPrivateAccountkey()
+		accountKey, err = c.s.GetPrivateAccountkey(account.Name)
 		if err != nil {
 			return c, err
 		}
-		c.a = acme.NewAcme(acme.Config{AccountKey: accountKey, Contact: acmeContact})
+		c.a = c.newAcme(accountKey)
 		err = c.a.Register()
 		if err != nil {
 			return c, err
 		}
 	} else if err == nil {
 		logger.Debugf("Private key found, initializing")
-		c.a = acme.NewAcme(acme.Config{AccountKey: accountKey, Contact: acmeContact})
+		c.a = c.newAcme(accountKey)
 	} else {
 		return c, err
 	}
 	return c, nil
 }
 
+func (c *Cert) newAcme(accountKey *rsa.PrivateKey) *acme.Acme {
+	return acme.NewAcme(acme.Config{AccountKey: accountKey, Contact: c.account.Contact, DirectoryURL: c.account.DirectoryURL})
+}
+
+func validateAcmeAccountName(name string) error {
+	if name == "." || name == ".." || strings.ContainsAny(name, "/\\") || strings.HasSuffix(name, stagingAccountKeySuffix) {
+		return fmt.Errorf("Invalid acme account name: %s", name)
+	}
+	return nil
+}
+
+// stagingAccountKeySuffix is appended to the account name to store the new key during a rollover (pki/accountkeys/<name>.new)
+const stagingAccountKeySuffix = ".new"
+
+func (c *Cert) getStagingAccountName() string {
+	return c.account.Name + stagingAccountKeySuffix
+}
+
+// rolloverAccountKey generates a new account key, replaces it at the ACME provider and writes it to storage.
+// The new key is written to a staging path first, so it isn't lost when the rollover succeeds but the key can't be written
+func (c *Cert) rolloverAccountKey() error {
+	newKey, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	err = c.s.WriteAccountKey(c.getStagingAccountName(), newKey)
+	if err != nil {
+		return fmt.Errorf("Couldn't write new account key to staging path: %s", err)
+	}
+	err = c.a.RolloverKey(newKey)
+	if err != nil {
+		// the staging key is checked at the next startup, in case the ACME provider replaced the key anyway
+		return err
+	}
+	logger.Infof("Account key rolled over, writing new key to storage (account: %s)", c.account.Name)
+	return c.promoteStagingAccountKey(newKey)
+}
+
+// promoteStagingAccountKey replaces the account key with the key of the staging path, and removes the staging key
+func (c *Cert) promoteStagingAccountKey(key *rsa.PrivateKey) error {
+	err := c.s.WriteAccountKey(c.account.Name, key)
+	if err != nil {
+		return fmt.Errorf("Couldn't write new account key (the key is kept in the staging path): %s", err)
+	}
+	return c.s.DeleteAccountKey(c.getStagingAccountName())
+}
+
+// recoverAccountKey finishes or discards an interrupted rollover. The staging key is promoted when the ACME provider
+// knows the account by the staging key, and it's removed when it doesn't
+func (c *Cert) recoverAccountKey() error {
+	stagingKey, err := c.s.GetPrivateAccountkey(c.getStagingAccountName())
+	if err == c.s.GetError("errNotExist") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't read staging account key: %s", err)
+	}
+	accountKey, err := c.s.GetPrivateAccountkey(c.account.Name)
+	if err == nil && accountKey.Equal(stagingKey) {
+		return c.s.DeleteAccountKey(c.getStagingAccountName())
+	}
+	_, err = c.newAcme(stagingKey).GetAccount()
+	if err == xacme.ErrNoAccount {
+		logger.Infof("Removing staging account key of an unfinished rollover (account: %s)", c.account.Name)
+		return c.s.DeleteAccountKey(c.getStagingAccountName())
+	}
+	if err != nil {
+		return fmt.Errorf("Couldn't check staging account key of an unfinished rollover: %s", err)
+	}
+	logger.Infof("Recovering account key of an unfinished rollover (account: %s)", c.account.Name)
+	return c.promoteStagingAccountKey(stagingKey)
+}
+
+func (c *Cert) updateContact(contact string) error {
+	err := c.a.UpdateContact(contact)
+	if err != nil {
+		return err
+	}
+	c.account.Contact = contact
+	return nil
+}
+
+func (c *Cert) deactivateAccount() error {
+	return c.a.Deactivate()
+}
+
 func (c *Cert) verifyDomains(params CreateCertParams) ([]WorkQueueItem, error) {
 	var (
 		err             error
@@ -155,6 +259,7 @@ func (c *Cert) CreateCert(params CreateCertParams) (string, string, error) {
 	if err != nil {
 		return "", "", err
 	}
+	recordCertificateExpiry(name, leaf)
 
 	err = c.s.WriteCertBundle(name, certBundle)
 	if err != nil {
@@ -0,0 +1,213 @@
+package envoy
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/in4it/roxprox/pkg/crypto"
+	"github.com/in4it/roxprox/pkg/storage"
+	localStorage "github.com/in4it/roxprox/pkg/storage/local"
+	"golang.org/x/crypto/acme"
+)
+
+// failingAccountKeyStorage fails to write the key of an account, to interrupt a rollover
+type failingAccountKeyStorage struct {
+	storage.Storage
+	account string
+}
+
+func (f *failingAccountKeyStorage) WriteAccountKey(account string, key *rsa.PrivateKey) error {
+	if account == f.account {
+		return fmt.Errorf("write failed")
+	}
+	return f.Storage.WriteAccountKey(account, key)
+}
+
+func newAcmeTestStorage() (storage.Storage, string, error) {
+	dir, err := ioutil.TempDir(".", "acme")
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+	return s, dir, nil
+}
+
+func TestAcmeAccounts(t *testing.T) {
+	fake := newFakeAcme()
+	defer fake.close()
+	s, dir, err := newAcmeTestStorage()
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"../tenant", "tenant.new"} {
+		if _, err := newCert(s, AcmeAccount{Name: name, Contact: "test@example.com", DirectoryURL: fake.directoryURL()}); err == nil {
+			t.Errorf("Expected error for account name %s", name)
+		}
+	}
+
+	// the default and the named account are separate accounts
+	defaultCert, err := newCert(s, AcmeAccount{Contact: "test@example.com", DirectoryURL: fake.directoryURL()})
+	if err != nil {
+		t.Errorf("newCert error: %s", err)
+		return
+	}
+	tenantCert, err := newCert(s, AcmeAccount{Name: "tenant", Contact: "tenant@example.com", DirectoryURL: fake.directoryURL()})
+	if err != nil {
+		t.Errorf("newCert error: %s", err)
+		return
+	}
+	if _, err := os.Stat(dir + "/pki/accountkeys/tenant/private.pem"); err != nil {
+		t.Errorf("Account key of the named account not found: %s", err)
+	}
+	if fake.countAccounts() != 2 {
+		t.Errorf("Expected 2 accounts, got: %d", fake.countAccounts())
+	}
+	// an existing account key isn't registered again
+	if _, err := newCert(s, AcmeAccount{Name: "tenant", Contact: "tenant@example.com", DirectoryURL: fake.directoryURL()}); err != nil {
+		t.Errorf("newCert error: %s", err)
+		return
+	}
+	if fake.countAccounts() != 2 {
+		t.Errorf("Expected 2 accounts, got: %d", fake.countAccounts())
+	}
+
+	tenantKey, err := s.GetPrivateAccountkey("tenant")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	if err := tenantCert.updateContact("new@example.com"); err != nil {
+		t.Errorf("updateContact error: %s", err)
+		return
+	}
+	account := fake.getAccount(tenantKey)
+	if account == nil || len(account.contact) != 1 || account.contact[0] != "mailto: new@example.com" {
+		t.Errorf("Contact not updated: %+v", account)
+	}
+
+	defaultKey, err := s.GetPrivateAccountkey("")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	if err := defaultCert.deactivateAccount(); err != nil {
+		t.Errorf("deactivateAccount error: %s", err)
+		return
+	}
+	if account := fake.getAccount(defaultKey); account == nil || account.status != acme.StatusDeactivated {
+		t.Errorf("Account not deactivated: %+v", account)
+	}
+	if account := fake.getAccount(tenantKey); account == nil || account.status != acme.StatusValid {
+		t.Errorf("Named account was deactivated: %+v", account)
+	}
+}
+
+func TestAcmeAccountKeyRollover(t *testing.T) {
+	fake := newFakeAcme()
+	defer fake.close()
+	s, dir, err := newAcmeTestStorage()
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	account := AcmeAccount{Name: "tenant", Contact: "test@example.com", DirectoryURL: fake.directoryURL()}
+	stagingDir := dir + "/pki/accountkeys/tenant.new"
+
+	c, err := newCert(s, account)
+	if err != nil {
+		t.Errorf("newCert error: %s", err)
+		return
+	}
+	oldKey, err := s.GetPrivateAccountkey("tenant")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	if err := c.rolloverAccountKey(); err != nil {
+		t.Errorf("rolloverAccountKey error: %s", err)
+		return
+	}
+	newKey, err := s.GetPrivateAccountkey("tenant")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	if newKey.Equal(oldKey) || fake.getAccount(newKey) == nil || fake.getAccount(oldKey) != nil {
+		t.Errorf("Account key not rolled over")
+	}
+	if _, err := os.Stat(stagingDir); !os.IsNotExist(err) {
+		t.Errorf("Staging key not removed (error: %v)", err)
+	}
+
+	// the key can't be written after the rollover, it's recovered from the staging path at the next startup
+	c, err = newCert(&failingAccountKeyStorage{Storage: s, account: "tenant"}, account)
+	if err != nil {
+		t.Errorf("newCert error: %s", err)
+		return
+	}
+	if err := c.rolloverAccountKey(); err == nil {
+		t.Errorf("Expected error when the new key can't be written")
+		return
+	}
+	if key, err := s.GetPrivateAccountkey("tenant"); err != nil || !key.Equal(newKey) {
+		t.Errorf("Account key changed (error: %v)", err)
+	}
+	stagingKey, err := s.GetPrivateAccountkey("tenant.new")
+	if err != nil {
+		t.Errorf("Staging key not found: %s", err)
+		return
+	}
+	if fake.getAccount(stagingKey) == nil {
+		t.Errorf("Account key not rolled over to the staging key")
+	}
+	if _, err := newCert(s, account); err != nil {
+		t.Errorf("newCert error: %s", err)
+		return
+	}
+	if key, err := s.GetPrivateAccountkey("tenant"); err != nil || !key.Equal(stagingKey) {
+		t.Errorf("Staging key not recovered (error: %v)", err)
+	}
+	if _, err := os.Stat(stagingDir); !os.IsNotExist(err) {
+		t.Errorf("Staging key not removed (error: %v)", err)
+	}
+
+	// the rollover failed at the ACME provider, the staging key is removed at the next startup
+	fake.failKeyChange = true
+	if err := c.rolloverAccountKey(); err == nil {
+		t.Errorf("Expected error when the key change fails")
+	}
+	fake.failKeyChange = false
+	unusedKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Errorf("GenerateKey error: %s", err)
+		return
+	}
+	if err := s.WriteAccountKey("tenant.new", unusedKey); err != nil {
+		t.Errorf("WriteAccountKey error: %s", err)
+		return
+	}
+	if _, err := newCert(s, account); err != nil {
+		t.Errorf("newCert error: %s", err)
+		return
+	}
+	if key, err := s.GetPrivateAccountkey("tenant"); err != nil || !key.Equal(stagingKey) {
+		t.Errorf("Account key changed (error: %v)", err)
+	}
+	if _, err := os.Stat(stagingDir); !os.IsNotExist(err) {
+		t.Errorf("Staging key not removed (error: %v)", err)
+	}
+	if fake.countAccounts() != 1 {
+		t.Errorf("Expected 1 account, got: %d", fake.countAccounts())
+	}
+}
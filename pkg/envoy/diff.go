@@ -0,0 +1,288 @@
+package envoy
+
+import (
+	"fmt"
+	"strings"
+
+	clusterAPI "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	api "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	cacheTypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change is a difference between two rendered configurations
+type Change struct {
+	Type string `json:"type"`
+	// Resource is cluster, listener, filterChain, httpFilter, virtualHost or route
+	Resource string `json:"resource"`
+	// Path identifies the resource, e.g. [listener l_http, virtualHost test1, route prefix /]
+	Path []string `json:"path"`
+	// Detail describes the change, e.g. cluster test1 -> cluster test2 (optional)
+	Detail string `json:"detail,omitempty"`
+}
+
+func (c Change) String() string {
+	sign := map[string]string{ChangeAdded: "+", ChangeRemoved: "-", ChangeChanged: "~"}[c.Type]
+	out := fmt.Sprintf("%s %s %s", sign, c.Resource, strings.Join(c.Path, " > "))
+	if c.Detail != "" {
+		out += " (" + c.Detail + ")"
+	}
+	return out
+}
+
+// Diff returns the changes of the clusters and listeners between two rendered configurations
+func Diff(old, new *RenderResult) []Change {
+	var changes []Change
+	changes = append(changes, diffClusters(old.Clusters, new.Clusters)...)
+	changes = append(changes, diffListeners(old.Listeners, new.Listeners)...)
+	return changes
+}
+
+func diffClusters(old, new []cacheTypes.Resource) []Change {
+	var changes []Change
+	oldClusters := make(map[string]*clusterAPI.Cluster)
+	for _, resource := range old {
+		cluster := resource.(*clusterAPI.Cluster)
+		oldClusters[cluster.Name] = cluster
+	}
+	newClusters := make(map[string]bool)
+	for _, resource := range new {
+		cluster := resource.(*clusterAPI.Cluster)
+		newClusters[cluster.Name] = true
+		oldCluster, ok := oldClusters[cluster.Name]
+		if !ok {
+			changes = append(changes, Change{Type: ChangeAdded, Resource: "cluster", Path: []string{cluster.Name}})
+		} else if !proto.Equal(oldCluster, cluster) {
+			changes = append(changes, Change{Type: ChangeChanged, Resource: "cluster", Path: []string{cluster.Name}})
+		}
+	}
+	for _, resource := range old {
+		cluster := resource.(*clusterAPI.Cluster)
+		if !newClusters[cluster.Name] {
+			changes = append(changes, Change{Type: ChangeRemoved, Resource: "cluster", Path: []string{cluster.Name}})
+		}
+	}
+	return changes
+}
+
+func diffListeners(old, new []cacheTypes.Resource) []Change {
+	var changes []Change
+	for _, resource := range new {
+		listener := resource.(*api.Listener)
+		path := []string{"listener " + listener.Name}
+		oldIndex := getListenerIndex(old, listener.Name)
+		if oldIndex == -1 {
+			changes = append(changes, Change{Type: ChangeAdded, Resource: "listener", Path: path})
+			continue
+		}
+		oldListener := old[oldIndex].(*api.Listener)
+		if proto.Equal(oldListener, listener) {
+			continue
+		}
+		listenerChanges := diffFilterChains(path, oldListener.FilterChains, listener.FilterChains)
+		if len(listenerChanges) == 0 {
+			// the difference is in the listener itself (e.g. the address)
+			listenerChanges = append(listenerChanges, Change{Type: ChangeChanged, Resource: "listener", Path: path})
+		}
+		changes = append(changes, listenerChanges...)
+	}
+	for _, resource := range old {
+		listener := resource.(*api.Listener)
+		if getListenerIndex(new, listener.Name) == -1 {
+			changes = append(changes, Change{Type: ChangeRemoved, Resource: "listener", Path: []string{"listener " + listener.Name}})
+		}
+	}
+	return changes
+}
+
+// filterChainName returns the server names of the filter chain, or default when the filter chain matches all server names
+func filterChainName(filterChain *api.FilterChain) string {
+	serverNames := filterChain.GetFilterChainMatch().GetServerNames()
+	if len(serverNames) == 0 {
+		return "default"
+	}
+	return strings.Join(serverNames, ",")
+}
+
+func diffFilterChains(path []string, old, new []*api.FilterChain) []Change {
+	var changes []Change
+	oldFilterChains := make(map[string]*api.FilterChain)
+	for _, filterChain := range old {
+		oldFilterChains[filterChainName(filterChain)] = filterChain
+	}
+	newFilterChains := make(map[string]bool)
+	for _, filterChain := range new {
+		name := filterChainName(filterChain)
+		newFilterChains[name] = true
+		filterChainPath := appendPath(path, "filterChain "+name)
+		oldFilterChain, ok := oldFilterChains[name]
+		if !ok {
+			changes = append(changes, Change{Type: ChangeAdded, Resource: "filterChain", Path: filterChainPath})
+			continue
+		}
+		if proto.Equal(oldFilterChain, filterChain) {
+			continue
+		}
+		var filterChainChanges []Change
+		oldManager, oldErr := getFilterChainHTTPConnectionManager(oldFilterChain)
+		manager, err := getFilterChainHTTPConnectionManager(filterChain)
+		if oldErr == nil && err == nil {
+			filterChainChanges = append(filterChainChanges, diffHTTPFilters(filterChainPath, oldManager.HttpFilters, manager.HttpFilters)...)
+			filterChainChanges = append(filterChainChanges, diffVirtualHosts(filterChainPath, oldManager.GetRouteConfig().GetVirtualHosts(), manager.GetRouteConfig().GetVirtualHosts())...)
+		}
+		if len(filterChainChanges) == 0 || !proto.Equal(oldFilterChain.TransportSocket, filterChain.TransportSocket) {
+			// the difference is in the filter chain itself (e.g. the tls configuration)
+			filterChainChanges = append(filterChainChanges, Change{Type: ChangeChanged, Resource: "filterChain", Path: filterChainPath})
+		}
+		changes = append(changes, filterChainChanges...)
+	}
+	for _, filterChain := range old {
+		name := filterChainName(filterChain)
+		if !newFilterChains[name] {
+			changes = append(changes, Change{Type: ChangeRemoved, Resource: "filterChain", Path: appendPath(path, "filterChain "+name)})
+		}
+	}
+	return changes
+}
+
+func getFilterChainHTTPConnectionManager(filterChain *api.FilterChain) (*hcm.HttpConnectionManager, error) {
+	filterIndex := getFilterIndexByName(filterChain.Filters, Envoy_HTTP_Filter)
+	if filterIndex == -1 {
+		return nil, fmt.Errorf(Error_NoFilterFound)
+	}
+	typedConfig, ok := filterChain.Filters[filterIndex].ConfigType.(*api.Filter_TypedConfig)
+	if !ok {
+		return nil, fmt.Errorf(Error_NoFilterFound)
+	}
+	return getManager(typedConfig)
+}
+
+func diffHTTPFilters(path []string, old, new []*hcm.HttpFilter) []Change {
+	var changes []Change
+	for _, httpFilter := range new {
+		oldIndex := getListenerHTTPFilterIndex(httpFilter.Name, old)
+		if oldIndex == -1 {
+			changes = append(changes, Change{Type: ChangeAdded, Resource: "httpFilter", Path: appendPath(path, httpFilter.Name)})
+		} else if !proto.Equal(old[oldIndex], httpFilter) {
+			changes = append(changes, Change{Type: ChangeChanged, Resource: "httpFilter", Path: appendPath(path, httpFilter.Name)})
+		}
+	}
+	for _, httpFilter := range old {
+		if getListenerHTTPFilterIndex(httpFilter.Name, new) == -1 {
+			changes = append(changes, Change{Type: ChangeRemoved, Resource: "httpFilter", Path: appendPath(path, httpFilter.Name)})
+		}
+	}
+	return changes
+}
+
+func diffVirtualHosts(path []string, old, new []*route.VirtualHost) []Change {
+	var changes []Change
+	oldVirtualHosts := make(map[string]*route.VirtualHost)
+	for _, virtualHost := range old {
+		oldVirtualHosts[virtualHost.Name] = virtualHost
+	}
+	newVirtualHosts := make(map[string]bool)
+	for _, virtualHost := range new {
+		newVirtualHosts[virtualHost.Name] = true
+		virtualHostPath := appendPath(path, "virtualHost "+virtualHost.Name)
+		oldVirtualHost, ok := oldVirtualHosts[virtualHost.Name]
+		if !ok {
+			changes = append(changes, Change{Type: ChangeAdded, Resource: "virtualHost", Path: virtualHostPath, Detail: "domains " + strings.Join(virtualHost.Domains, ",")})
+			continue
+		}
+		if proto.Equal(oldVirtualHost, virtualHost) {
+			continue
+		}
+		virtualHostChanges := diffRoutes(virtualHostPath, oldVirtualHost.Routes, virtualHost.Routes)
+		if len(virtualHostChanges) == 0 {
+			// the difference is in the virtual host itself (e.g. the domains or the route order)
+			virtualHostChanges = append(virtualHostChanges, Change{Type: ChangeChanged, Resource: "virtualHost", Path: virtualHostPath})
+		}
+		changes = append(changes, virtualHostChanges...)
+	}
+	for _, virtualHost := range old {
+		if !newVirtualHosts[virtualHost.Name] {
+			changes = append(changes, Change{Type: ChangeRemoved, Resource: "virtualHost", Path: appendPath(path, "virtualHost "+virtualHost.Name)})
+		}
+	}
+	return changes
+}
+
+// diffRoutes matches the routes of a virtual host on their route match
+func diffRoutes(path []string, old, new []*route.Route) []Change {
+	var changes []Change
+	for _, newRoute := range new {
+		routePath := appendPath(path, describeRouteMatch(newRoute.Match))
+		oldIndex := getRouteIndex(old, newRoute.Match)
+		if oldIndex == -1 {
+			changes = append(changes, Change{Type: ChangeAdded, Resource: "route", Path: routePath, Detail: describeRouteAction(newRoute)})
+			continue
+		}
+		oldRoute := old[oldIndex]
+		if !routeActionEqual(oldRoute, newRoute) {
+			changes = append(changes, Change{Type: ChangeChanged, Resource: "route", Path: routePath, Detail: describeRouteAction(oldRoute) + " -> " + describeRouteAction(newRoute)})
+		} else if !proto.Equal(oldRoute, newRoute) {
+			changes = append(changes, Change{Type: ChangeChanged, Resource: "route", Path: routePath})
+		}
+	}
+	for _, oldRoute := range old {
+		if getRouteIndex(new, oldRoute.Match) == -1 {
+			changes = append(changes, Change{Type: ChangeRemoved, Resource: "route", Path: appendPath(path, describeRouteMatch(oldRoute.Match)), Detail: describeRouteAction(oldRoute)})
+		}
+	}
+	return changes
+}
+
+func getRouteIndex(routes []*route.Route, match *route.RouteMatch) int {
+	for k, r := range routes {
+		// routeMatchEqual only checks whether the headers of the first match are in the second match
+		if routeMatchEqual(r.Match, match) && routeMatchEqual(match, r.Match) {
+			return k
+		}
+	}
+	return -1
+}
+
+func describeRouteMatch(match *route.RouteMatch) string {
+	var out string
+	switch {
+	case match.GetPath() != "":
+		out = "path " + match.GetPath()
+	case match.GetSafeRegex() != nil:
+		out = "regex " + match.GetSafeRegex().GetRegex()
+	default:
+		out = "prefix " + match.GetPrefix()
+	}
+	for _, header := range match.GetHeaders() {
+		if header.GetExactMatch() != "" {
+			out += fmt.Sprintf(" header %s=%s", header.Name, header.GetExactMatch())
+		} else {
+			out += " header " + header.Name
+		}
+	}
+	return out
+}
+
+func describeRouteAction(r *route.Route) string {
+	switch action := r.Action.(type) {
+	case *route.Route_Route:
+		return "cluster " + action.Route.GetCluster()
+	case *route.Route_DirectResponse:
+		return fmt.Sprintf("direct response %d", action.DirectResponse.GetStatus())
+	}
+	return "no action"
+}
+
+func appendPath(path []string, element string) []string {
+	newPath := make([]string, len(path), len(path)+1)
+	copy(newPath, path)
+	return append(newPath, element)
+}
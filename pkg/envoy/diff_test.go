@@ -0,0 +1,156 @@
+package envoy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/storage"
+	localStorage "github.com/in4it/roxprox/pkg/storage/local"
+)
+
+func renderObjects(contents string) (*RenderResult, error) {
+	dir, err := ioutil.TempDir(".", "diff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(dir+"/objects.yaml", []byte(contents), 0644); err != nil {
+		return nil, err
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		return nil, err
+	}
+	return Render(s)
+}
+
+func TestDiff(t *testing.T) {
+	old, err := renderObjects(`api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  conditions:
+    - hostname: test1.example.com
+      prefix: /
+    - hostname: test1.example.com
+      prefix: /api
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+---
+api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test2
+spec:
+  conditions:
+    - hostname: test2.example.com
+  actions:
+    - proxy:
+        hostname: target2-example.com
+        port: 443
+`)
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+	new, err := renderObjects(`api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  conditions:
+    - hostname: test1.example.com
+      prefix: /
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 8443
+---
+api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test3
+spec:
+  conditions:
+    - hostname: test3.example.com
+  actions:
+    - directResponse:
+        status: 200
+`)
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+
+	expected := []string{
+		"~ cluster test1",
+		"- cluster test2",
+		"- route listener l_http > filterChain default > virtualHost v_test1.example.com > prefix /api (cluster test1)",
+		"+ virtualHost listener l_http > filterChain default > virtualHost v_test3.example.com (domains test3.example.com)",
+		"- virtualHost listener l_http > filterChain default > virtualHost v_test2.example.com",
+	}
+	changes := Diff(old, new)
+	if len(changes) != len(expected) {
+		t.Errorf("Expected %d changes, got: %v", len(expected), changes)
+		return
+	}
+	for k, change := range changes {
+		if change.String() != expected[k] {
+			t.Errorf("Unexpected change: %s (expected: %s)", change, expected[k])
+		}
+	}
+
+	if changes := Diff(new, new); len(changes) != 0 {
+		t.Errorf("Expected no changes, got: %v", changes)
+	}
+}
+
+func TestDiffRouteAction(t *testing.T) {
+	rule := `api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  conditions:
+    - hostname: test1.example.com
+      prefix: /health
+  actions:
+    - directResponse:
+        status: %d
+`
+	old, err := renderObjects(fmt.Sprintf(rule, 200))
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+	new, err := renderObjects(fmt.Sprintf(rule, 503))
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+	changes := Diff(old, new)
+	if len(changes) != 1 || changes[0].String() != "~ route listener l_http > filterChain default > virtualHost v_test1.example.com > prefix /health (direct response 200 -> direct response 503)" {
+		t.Errorf("Unexpected changes: %v", changes)
+	}
+
+	// the json output of render can be used as the old configuration
+	out, err := old.Marshal(pkgApi.FormatJSON)
+	if err != nil {
+		t.Errorf("Marshal error: %s", err)
+		return
+	}
+	snapshot, err := UnmarshalRenderResult(out)
+	if err != nil {
+		t.Errorf("UnmarshalRenderResult error: %s", err)
+		return
+	}
+	if changes := Diff(snapshot, new); len(changes) != 1 {
+		t.Errorf("Unexpected changes: %v", changes)
+	}
+}
@@ -0,0 +1,325 @@
+package envoy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	api "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+)
+
+// ExplainRequest is the request to match against the configuration
+type ExplainRequest struct {
+	// Listener is http, tls, the name of an mTLS object or the name of an envoy listener
+	Listener string
+	Host     string
+	Path     string
+	Method   string
+	Headers  map[string]string
+}
+
+// ExplainResult describes how envoy handles a request
+type ExplainResult struct {
+	Listener       string   `json:"listener"`
+	FilterChain    string   `json:"filterChain,omitempty"`
+	VirtualHost    string   `json:"virtualHost,omitempty"`
+	Route          string   `json:"route,omitempty"`
+	Rule           string   `json:"rule,omitempty"`
+	Cluster        string   `json:"cluster,omitempty"`
+	DirectResponse uint32   `json:"directResponse,omitempty"`
+	HostRewrite    string   `json:"hostRewrite,omitempty"`
+	PathRewrite    string   `json:"pathRewrite,omitempty"`
+	JwtProvider    string   `json:"jwtProvider,omitempty"`
+	Authz          string   `json:"authz,omitempty"`
+	RateLimits     []string `json:"rateLimits,omitempty"`
+}
+
+// Explain matches the request against the listeners, virtual hosts and routes in the same order as envoy.
+// When no route matches, the result contains the steps that matched and the error describes the step that didn't
+func Explain(config *RenderResult, request ExplainRequest) (*ExplainResult, error) {
+	result := &ExplainResult{Listener: explainListenerName(request.Listener)}
+	host := normalizeExplainHost(request.Host)
+	path := request.Path
+	if path == "" {
+		path = "/"
+	}
+	headers := map[string]string{":authority": host, ":path": path}
+	if request.Method != "" {
+		headers[":method"] = strings.ToUpper(request.Method)
+	}
+	for k, v := range request.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+
+	listenerIndex := getListenerIndex(config.Listeners, result.Listener)
+	if listenerIndex == -1 {
+		return result, fmt.Errorf("Listener not found: %s", result.Listener)
+	}
+	filterChain := explainFilterChain(config.Listeners[listenerIndex].(*api.Listener).FilterChains, host)
+	if filterChain == nil {
+		return result, fmt.Errorf("No filter chain matches server name %s", host)
+	}
+	result.FilterChain = filterChainName(filterChain)
+	manager, err := getFilterChainHTTPConnectionManager(filterChain)
+	if err != nil {
+		return result, err
+	}
+
+	virtualHost := explainVirtualHost(manager.GetRouteConfig().GetVirtualHosts(), host)
+	if virtualHost == nil {
+		return result, fmt.Errorf("No virtual host matches host %s", host)
+	}
+	result.VirtualHost = virtualHost.Name
+
+	var matchedRoute *route.Route
+	for _, r := range virtualHost.Routes {
+		if explainRouteMatch(r.Match, path, headers) {
+			matchedRoute = r
+			break
+		}
+	}
+	if matchedRoute == nil {
+		return result, fmt.Errorf("No route in virtual host %s matches path %s", virtualHost.Name, path)
+	}
+	result.Route = describeRouteMatch(matchedRoute.Match)
+
+	switch action := matchedRoute.Action.(type) {
+	case *route.Route_Route:
+		result.Cluster = action.Route.GetCluster()
+		result.Rule = result.Cluster
+		result.HostRewrite = action.Route.GetHostRewriteLiteral()
+		result.PathRewrite = explainPathRewrite(matchedRoute.Match, action.Route, path)
+	case *route.Route_DirectResponse:
+		result.DirectResponse = action.DirectResponse.GetStatus()
+		result.Rule = explainDirectResponseRule(config.Objects, virtualHost, matchedRoute.Match)
+	}
+
+	explainHTTPFilters(result, manager, path, headers)
+	for _, rateLimit := range virtualHost.RateLimits {
+		result.RateLimits = append(result.RateLimits, describeRateLimit(rateLimit))
+	}
+
+	return result, nil
+}
+
+// normalizeExplainHost returns the host in lowercase without port
+func normalizeExplainHost(host string) string {
+	host = strings.ToLower(host)
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+	return host
+}
+
+// explainListenerName returns the envoy listener name: http and tls are the default listeners, other names are mTLS objects
+func explainListenerName(name string) string {
+	switch {
+	case name == "" || name == "http":
+		return "l_http"
+	case name == "tls":
+		return "l_tls"
+	case strings.HasPrefix(name, "l_"):
+		return name
+	}
+	return "l_mtls_" + name
+}
+
+// explainFilterChain returns the filter chain with the server name, or the filter chain without server names
+func explainFilterChain(filterChains []*api.FilterChain, host string) *api.FilterChain {
+	var defaultFilterChain *api.FilterChain
+	for _, filterChain := range filterChains {
+		serverNames := filterChain.GetFilterChainMatch().GetServerNames()
+		if len(serverNames) == 0 && defaultFilterChain == nil {
+			defaultFilterChain = filterChain
+		}
+		for _, serverName := range serverNames {
+			if serverName == host {
+				return filterChain
+			}
+		}
+	}
+	return defaultFilterChain
+}
+
+// explainVirtualHost selects the virtual host like envoy: an exact domain first, then the longest
+// suffix wildcard (*.example.com), then the longest prefix wildcard (example.*) and finally *
+func explainVirtualHost(virtualHosts []*route.VirtualHost, host string) *route.VirtualHost {
+	var (
+		suffixMatch, prefixMatch, wildcardMatch *route.VirtualHost
+		suffixLength, prefixLength              int
+	)
+	for _, virtualHost := range virtualHosts {
+		for _, domain := range virtualHost.Domains {
+			domain = strings.ToLower(domain)
+			switch {
+			case domain == host:
+				return virtualHost
+			case domain == "*":
+				if wildcardMatch == nil {
+					wildcardMatch = virtualHost
+				}
+			case strings.HasPrefix(domain, "*") && strings.HasSuffix(host, domain[1:]) && len(domain) > suffixLength:
+				suffixMatch, suffixLength = virtualHost, len(domain)
+			case strings.HasSuffix(domain, "*") && strings.HasPrefix(host, domain[:len(domain)-1]) && len(domain) > prefixLength:
+				prefixMatch, prefixLength = virtualHost, len(domain)
+			}
+		}
+	}
+	switch {
+	case suffixMatch != nil:
+		return suffixMatch
+	case prefixMatch != nil:
+		return prefixMatch
+	}
+	return wildcardMatch
+}
+
+func explainRouteMatch(match *route.RouteMatch, path string, headers map[string]string) bool {
+	// the query string is not part of the path match
+	if i := strings.Index(path, "?"); i != -1 {
+		path = path[:i]
+	}
+	switch {
+	case match.GetSafeRegex() != nil:
+		if !explainRegexFullMatch(match.GetSafeRegex().GetRegex(), path) {
+			return false
+		}
+	case match.GetPath() != "":
+		if match.GetPath() != path {
+			return false
+		}
+	default:
+		if !strings.HasPrefix(path, match.GetPrefix()) {
+			return false
+		}
+	}
+	return explainHeadersMatch(match.GetHeaders(), headers)
+}
+
+func explainHeadersMatch(matchers []*route.HeaderMatcher, headers map[string]string) bool {
+	for _, matcher := range matchers {
+		value, ok := headers[strings.ToLower(matcher.Name)]
+		var matched bool
+		switch specifier := matcher.HeaderMatchSpecifier.(type) {
+		case *route.HeaderMatcher_ExactMatch:
+			matched = ok && value == specifier.ExactMatch
+		case *route.HeaderMatcher_PrefixMatch:
+			matched = ok && strings.HasPrefix(value, specifier.PrefixMatch)
+		case *route.HeaderMatcher_SuffixMatch:
+			matched = ok && strings.HasSuffix(value, specifier.SuffixMatch)
+		case *route.HeaderMatcher_SafeRegexMatch:
+			matched = ok && explainRegexFullMatch(specifier.SafeRegexMatch.GetRegex(), value)
+		case *route.HeaderMatcher_PresentMatch:
+			matched = ok == specifier.PresentMatch
+		default:
+			matched = ok
+		}
+		if matched == matcher.InvertMatch {
+			return false
+		}
+	}
+	return true
+}
+
+// explainRegexFullMatch matches the whole value, like the envoy safe regex matcher
+func explainRegexFullMatch(expr, value string) bool {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(value)
+}
+
+// explainPathRewrite returns the path that is sent to the cluster, or an empty string when the path isn't rewritten
+func explainPathRewrite(match *route.RouteMatch, action *route.RouteAction, path string) string {
+	if action.PrefixRewrite != "" && match.GetPrefix() != "" {
+		return action.PrefixRewrite + strings.TrimPrefix(path, match.GetPrefix())
+	}
+	if action.PrefixRewrite != "" && match.GetPath() != "" {
+		return action.PrefixRewrite
+	}
+	if action.RegexRewrite != nil {
+		re, err := regexp.Compile(action.RegexRewrite.GetPattern().GetRegex())
+		if err != nil {
+			return ""
+		}
+		// envoy uses \1 for capture groups in the substitution
+		substitution := regexp.MustCompile(`\\(\d+)`).ReplaceAllString(action.RegexRewrite.Substitution, "$${$1}")
+		return re.ReplaceAllString(path, substitution)
+	}
+	return ""
+}
+
+// explainDirectResponseRule looks up the rule of a direct response, because the route doesn't refer to a cluster
+func explainDirectResponseRule(objects []pkgApi.Object, virtualHost *route.VirtualHost, match *route.RouteMatch) string {
+	for _, object := range objects {
+		rule, ok := object.Data.(pkgApi.Rule)
+		if !ok {
+			continue
+		}
+		for _, condition := range rule.Spec.Conditions {
+			hostname := condition.Hostname
+			if hostname == "" {
+				hostname = "*"
+			}
+			if len(virtualHost.Domains) == 0 || virtualHost.Domains[0] != hostname {
+				continue
+			}
+			if (match.GetPrefix() != "" && (condition.Prefix == match.GetPrefix() || (condition.Prefix == "" && condition.Path == "" && condition.Regex == "" && match.GetPrefix() == "/"))) ||
+				(match.GetPath() != "" && condition.Path == match.GetPath()) ||
+				(match.GetSafeRegex() != nil && condition.Regex == match.GetSafeRegex().GetRegex()) {
+				return rule.Metadata.Name
+			}
+		}
+	}
+	return ""
+}
+
+func explainHTTPFilters(result *ExplainResult, manager *hcm.HttpConnectionManager, path string, headers map[string]string) {
+	if jwtConfig, err := getListenerHTTPFilterJwtAuth(manager.HttpFilters); err == nil {
+		for _, rule := range jwtConfig.Rules {
+			if explainRouteMatch(rule.Match, path, headers) {
+				result.JwtProvider = rule.GetRequires().GetProviderName()
+				break
+			}
+		}
+	}
+	if authzConfig, err := getListenerHTTPFilterAuthz(manager.HttpFilters); err == nil {
+		grpcService := authzConfig.GetGrpcService()
+		result.Authz = fmt.Sprintf("cluster %s", grpcService.GetEnvoyGrpc().GetClusterName())
+		if grpcService.GetTimeout() != nil {
+			result.Authz += fmt.Sprintf(", timeout %s", grpcService.GetTimeout().AsDuration())
+		}
+		if authzConfig.FailureModeAllow {
+			result.Authz += ", failure mode allow"
+		}
+	}
+}
+
+func describeRateLimit(rateLimit *route.RateLimit) string {
+	var descriptors []string
+	for _, action := range rateLimit.Actions {
+		switch specifier := action.ActionSpecifier.(type) {
+		case *route.RateLimit_Action_RequestHeaders_:
+			descriptors = append(descriptors, fmt.Sprintf("%s (header %s)", specifier.RequestHeaders.DescriptorKey, specifier.RequestHeaders.HeaderName))
+		case *route.RateLimit_Action_RemoteAddress_:
+			descriptors = append(descriptors, "remote_address")
+		case *route.RateLimit_Action_SourceCluster_:
+			descriptors = append(descriptors, "source_cluster")
+		case *route.RateLimit_Action_DestinationCluster_:
+			descriptors = append(descriptors, "destination_cluster")
+		case *route.RateLimit_Action_GenericKey_:
+			descriptorKey := specifier.GenericKey.DescriptorKey
+			if descriptorKey == "" {
+				descriptorKey = "generic_key"
+			}
+			descriptors = append(descriptors, fmt.Sprintf("%s=%s", descriptorKey, specifier.GenericKey.DescriptorValue))
+		default:
+			descriptors = append(descriptors, "other")
+		}
+	}
+	return "descriptors: " + strings.Join(descriptors, ", ")
+}
@@ -0,0 +1,133 @@
+package envoy
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+)
+
+func TestExplain(t *testing.T) {
+	config, err := renderObjects(`api: proxy.in4it.io/v1
+kind: jwtProvider
+metadata:
+  name: test-jwt
+spec:
+  remoteJwks: https://example.com/.well-known/jwks.json
+  issuer: https://example.com
+---
+api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  auth:
+    jwtProvider: test-jwt
+  conditions:
+    - hostname: test1.example.com
+      prefix: /api
+      methods: [POST]
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+        regexRewrite:
+          regex: "^/api/(.*)$"
+          substitution: /v2/\1
+---
+api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test2
+spec:
+  conditions:
+    - hostname: test1.example.com
+      prefix: /static
+  actions:
+    - proxy:
+        hostname: static-example.com
+        port: 443
+        prefixRewrite: /assets
+---
+api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: healthcheck
+spec:
+  conditions:
+    - path: /.roxprox/health
+  actions:
+    - directResponse:
+        status: 200
+`)
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+
+	tests := []struct {
+		request  ExplainRequest
+		expected ExplainResult
+		noMatch  bool
+	}{
+		{
+			request:  ExplainRequest{Host: "test1.example.com:443", Path: "/api/users", Method: "post"},
+			expected: ExplainResult{Listener: "l_http", FilterChain: "default", VirtualHost: "v_test1.example.com", Route: "prefix /api header :method=POST", Rule: "test1", Cluster: "test1", HostRewrite: "target-example.com", PathRewrite: "/v2/users", JwtProvider: "test-jwt"},
+		},
+		{
+			request:  ExplainRequest{Host: "test1.example.com", Path: "/static/logo.png?v=1"},
+			expected: ExplainResult{Listener: "l_http", FilterChain: "default", VirtualHost: "v_test1.example.com", Route: "prefix /static", Rule: "test2", Cluster: "test2", HostRewrite: "static-example.com", PathRewrite: "/assets/logo.png?v=1"},
+		},
+		{
+			request:  ExplainRequest{Host: "other.example.com", Path: "/.roxprox/health"},
+			expected: ExplainResult{Listener: "l_http", FilterChain: "default", VirtualHost: "v_nodomain", Route: "path /.roxprox/health", Rule: "healthcheck", DirectResponse: 200},
+		},
+		{
+			// the method doesn't match
+			request:  ExplainRequest{Host: "test1.example.com", Path: "/api/users", Method: "GET"},
+			expected: ExplainResult{Listener: "l_http", FilterChain: "default", VirtualHost: "v_test1.example.com"},
+			noMatch:  true,
+		},
+		{
+			request:  ExplainRequest{Listener: "test-mtls", Host: "test1.example.com"},
+			expected: ExplainResult{Listener: "l_mtls_test-mtls"},
+			noMatch:  true,
+		},
+	}
+	for _, test := range tests {
+		result, err := Explain(config, test.request)
+		if test.noMatch != (err != nil) {
+			t.Errorf("Unexpected error for %+v: %v", test.request, err)
+			continue
+		}
+		if result.Listener != test.expected.Listener || result.FilterChain != test.expected.FilterChain || result.VirtualHost != test.expected.VirtualHost ||
+			result.Route != test.expected.Route || result.Rule != test.expected.Rule || result.Cluster != test.expected.Cluster ||
+			result.DirectResponse != test.expected.DirectResponse || result.HostRewrite != test.expected.HostRewrite ||
+			result.PathRewrite != test.expected.PathRewrite || result.JwtProvider != test.expected.JwtProvider {
+			t.Errorf("Unexpected result for %+v: %+v", test.request, result)
+		}
+	}
+}
+
+func TestExplainVirtualHost(t *testing.T) {
+	virtualHosts := []*route.VirtualHost{
+		{Name: "wildcard", Domains: []string{"*"}},
+		{Name: "prefix", Domains: []string{"api.*"}},
+		{Name: "suffix", Domains: []string{"*.example.com"}},
+		{Name: "longer-suffix", Domains: []string{"*.api.example.com"}},
+		{Name: "exact", Domains: []string{"api.example.com"}},
+	}
+	tests := map[string]string{
+		"api.example.com":      "exact",
+		"v1.api.example.com":   "longer-suffix",
+		"www.example.com":      "suffix",
+		"api.example.org":      "prefix",
+		"www.example.org":      "wildcard",
+		"API.EXAMPLE.COM:8080": "exact",
+	}
+	for host, expected := range tests {
+		virtualHost := explainVirtualHost(virtualHosts, normalizeExplainHost(host))
+		if virtualHost == nil || virtualHost.Name != expected {
+			t.Errorf("Unexpected virtual host for %s: %+v (expected: %s)", host, virtualHost, expected)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package envoy
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+)
+
+// fakeAcme is an in-process ACME server for the account tests. It handles the account endpoints
+// (newAccount, account updates and keyChange), and identifies the accounts by their key. Signatures aren't verified
+type fakeAcme struct {
+	mu             sync.Mutex
+	accounts       map[string]*fakeAcmeAccount // by account url
+	failKeyChange  bool
+	server         *httptest.Server
+	accountCounter int
+}
+
+type fakeAcmeAccount struct {
+	jwk     string
+	contact []string
+	status  string
+}
+
+type fakeAcmeJWS struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+}
+
+type fakeAcmeHeader struct {
+	JWK json.RawMessage `json:"jwk"`
+	KID string          `json:"kid"`
+}
+
+func newFakeAcme() *fakeAcme {
+	f := &fakeAcme{accounts: make(map[string]*fakeAcmeAccount)}
+	f.server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeAcme) directoryURL() string {
+	return f.server.URL + "/directory"
+}
+
+func (f *fakeAcme) close() {
+	f.server.Close()
+}
+
+// getAccount returns the account registered with the key
+func (f *fakeAcme) getAccount(key *rsa.PrivateKey) *fakeAcmeAccount {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, account := f.findAccount(getFakeAcmeJWK(key))
+	return account
+}
+
+func (f *fakeAcme) countAccounts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.accounts)
+}
+
+func (f *fakeAcme) findAccount(jwk string) (string, *fakeAcmeAccount) {
+	for url, account := range f.accounts {
+		if account.jwk == jwk {
+			return url, account
+		}
+	}
+	return "", nil
+}
+
+func (f *fakeAcme) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Replay-Nonce", "nonce")
+	if r.URL.Path == "/directory" {
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   f.server.URL + "/new-nonce",
+			"newAccount": f.server.URL + "/new-account",
+			"newOrder":   f.server.URL + "/new-order",
+			"keyChange":  f.server.URL + "/key-change",
+		})
+		return
+	}
+	if r.URL.Path == "/new-nonce" {
+		return
+	}
+	header, payload, err := decodeFakeAcmeJWS(r)
+	if err != nil {
+		f.writeError(w, http.StatusBadRequest, "malformed", err.Error())
+		return
+	}
+	switch {
+	case r.URL.Path == "/new-account":
+		var request struct {
+			OnlyReturnExisting bool     `json:"onlyReturnExisting"`
+			Contact            []string `json:"contact"`
+		}
+		json.Unmarshal(payload, &request)
+		url, account := f.findAccount(string(header.JWK))
+		if account != nil {
+			f.writeAccount(w, http.StatusOK, url, account)
+			return
+		}
+		if request.OnlyReturnExisting {
+			f.writeError(w, http.StatusBadRequest, "accountDoesNotExist", "no account for this key")
+			return
+		}
+		f.accountCounter++
+		url = fmt.Sprintf("%s/account/%d", f.server.URL, f.accountCounter)
+		f.accounts[url] = &fakeAcmeAccount{jwk: string(header.JWK), contact: request.Contact, status: acme.StatusValid}
+		f.writeAccount(w, http.StatusCreated, url, f.accounts[url])
+	case strings.HasPrefix(r.URL.Path, "/account/"):
+		account, ok := f.accounts[header.KID]
+		if !ok || account.status != acme.StatusValid {
+			f.writeError(w, http.StatusUnauthorized, "unauthorized", "account is not valid")
+			return
+		}
+		var request struct {
+			Contact []string `json:"contact"`
+			Status  string   `json:"status"`
+		}
+		json.Unmarshal(payload, &request)
+		if request.Contact != nil {
+			account.contact = request.Contact
+		}
+		if request.Status == acme.StatusDeactivated {
+			account.status = acme.StatusDeactivated
+		}
+		f.writeAccount(w, http.StatusOK, header.KID, account)
+	case r.URL.Path == "/key-change":
+		account, ok := f.accounts[header.KID]
+		if !ok || account.status != acme.StatusValid {
+			f.writeError(w, http.StatusUnauthorized, "unauthorized", "account is not valid")
+			return
+		}
+		if f.failKeyChange {
+			f.writeError(w, http.StatusBadRequest, "malformed", "key change failed")
+			return
+		}
+		var innerJWS fakeAcmeJWS
+		if err := json.Unmarshal(payload, &innerJWS); err != nil {
+			f.writeError(w, http.StatusBadRequest, "malformed", err.Error())
+			return
+		}
+		innerHeader, err := decodeFakeAcmeHeader(innerJWS.Protected)
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest, "malformed", err.Error())
+			return
+		}
+		account.jwk = string(innerHeader.JWK)
+		f.writeAccount(w, http.StatusOK, header.KID, account)
+	default:
+		f.writeError(w, http.StatusNotFound, "malformed", "unsupported path")
+	}
+}
+
+func (f *fakeAcme) writeAccount(w http.ResponseWriter, status int, url string, account *fakeAcmeAccount) {
+	w.Header().Set("Location", url)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": account.status, "contact": account.contact})
+}
+
+func (f *fakeAcme) writeError(w http.ResponseWriter, status int, problemType, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"type": "urn:ietf:params:acme:error:" + problemType, "detail": detail})
+}
+
+func decodeFakeAcmeJWS(r *http.Request) (fakeAcmeHeader, []byte, error) {
+	var jws fakeAcmeJWS
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return fakeAcmeHeader{}, nil, err
+	}
+	header, err := decodeFakeAcmeHeader(jws.Protected)
+	if err != nil {
+		return header, nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	return header, payload, err
+}
+
+func decodeFakeAcmeHeader(protected string) (fakeAcmeHeader, error) {
+	var header fakeAcmeHeader
+	contents, err := base64.RawURLEncoding.DecodeString(protected)
+	if err != nil {
+		return header, err
+	}
+	err = json.Unmarshal(contents, &header)
+	return header, err
+}
+
+// getFakeAcmeJWK returns the jwk of the key, in the same format as the acme client
+func getFakeAcmeJWK(key *rsa.PrivateKey) string {
+	return fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+	)
+}
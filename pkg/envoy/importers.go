@@ -0,0 +1,35 @@
+package envoy
+
+import (
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+)
+
+type importer func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error)
+
+// importers converts the objects into work queue items. Every kind registered in pkg/api needs an importer
+var importers = map[string]importer{
+	"rule": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.ImportRule(object.Data.(pkgApi.Rule))
+	},
+	"jwtProvider": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.importJwtProvider(object.Data.(pkgApi.JwtProvider))
+	},
+	"authzFilter": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.importAuthzFilter(object.Data.(pkgApi.AuthzFilter))
+	},
+	"tracing": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.importTracing(object.Data.(pkgApi.Tracing))
+	},
+	"compression": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.importCompression(object.Data.(pkgApi.Compression))
+	},
+	"accessLogServer": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.importAccessLogServer(object.Data.(pkgApi.AccessLogServer))
+	},
+	"rateLimit": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.importRateLimit(object.Data.(pkgApi.RateLimit))
+	},
+	"mTLS": func(x *XDS, object pkgApi.Object) ([]WorkQueueItem, error) {
+		return x.importMTLS(object.Data.(pkgApi.MTLS))
+	},
+}
@@ -0,0 +1,15 @@
+package envoy
+
+import (
+	"testing"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+)
+
+func TestImporters(t *testing.T) {
+	for _, kind := range pkgApi.Kinds() {
+		if _, ok := importers[kind.Name]; !ok {
+			t.Errorf("No importer for kind %s", kind.Name)
+		}
+	}
+}
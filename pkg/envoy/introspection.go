@@ -0,0 +1,246 @@
+package envoy
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	api "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+)
+
+const redacted = "[redacted]"
+
+// NodeInfo describes an envoy node that is connected to the control plane
+type NodeInfo struct {
+	ID              string
+	Cluster         string
+	Streams         int
+	SnapshotVersion string
+}
+
+// CertificateInfo describes a certificate in the storage
+type CertificateInfo struct {
+	Name       string
+	Domains    []string
+	NotBefore  time.Time
+	NotAfter   time.Time
+	RenewAfter time.Time
+	// Error is set when the certificate couldn't be parsed
+	Error string
+}
+
+// Ready returns an error when the objects are not imported yet, or no snapshot was built yet
+func (x *XDS) Ready() error {
+	if atomic.LoadInt32(&x.imported) == 0 {
+		return fmt.Errorf("Objects are not imported yet")
+	}
+	if x.workQueue.getLatestSnapshotVersion() == "" {
+		return fmt.Errorf("No snapshot available")
+	}
+	return nil
+}
+
+// ListObjects returns the objects of the kinds (all kinds when kinds is empty), including the pending objects
+func (x *XDS) ListObjects(kinds []string) []pkgApi.Object {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	objects := []pkgApi.Object{}
+	for _, object := range x.objects {
+		if len(kinds) == 0 {
+			objects = append(objects, object)
+		} else if ret, _ := InArray(kinds, object.Kind); ret {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// GetObject returns an object, including pending objects
+func (x *XDS) GetObject(kind, name string) (pkgApi.Object, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.getObject(kind, name)
+}
+
+// ListPendingObjects returns the objects that are waiting for their dependencies
+func (x *XDS) ListPendingObjects() []PendingObject {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	pendingObjects := []PendingObject{}
+	for _, object := range x.objectsPending {
+		pendingObjects = append(pendingObjects, PendingObject{
+			Kind:         object.Kind,
+			Name:         object.Metadata.Name,
+			Dependencies: x.getObjectUnresolvedDependencies(object),
+		})
+	}
+	return pendingObjects
+}
+
+// GetPendingObject returns an object that is waiting for its dependencies
+func (x *XDS) GetPendingObject(kind, name string) (pkgApi.Object, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for _, object := range x.objectsPending {
+		if object.Kind == kind && object.Metadata.Name == name {
+			return object, nil
+		}
+	}
+	return pkgApi.Object{}, fmt.Errorf("object %s/%s not found", kind, name)
+}
+
+// GetSnapshot returns the version and the clusters and listeners of the latest snapshot
+func (x *XDS) GetSnapshot() (string, *RenderResult, error) {
+	version, clusters, listeners, err := x.workQueue.getLatestSnapshot()
+	if err != nil {
+		return "", nil, err
+	}
+	return version, &RenderResult{Clusters: clusters, Listeners: listeners}, nil
+}
+
+// ListNodes returns the envoy nodes that have an open xds stream
+func (x *XDS) ListNodes() []NodeInfo {
+	nodes := []NodeInfo{}
+	if x.workQueue.callback == nil {
+		return nodes
+	}
+	streams := make(map[string]int)
+	clusters := make(map[string]string)
+	for _, node := range x.workQueue.callback.getConnections() {
+		if node == nil {
+			continue
+		}
+		streams[node.Id]++
+		clusters[node.Id] = node.Cluster
+	}
+	for id, count := range streams {
+		node := NodeInfo{ID: id, Cluster: clusters[id], Streams: count}
+		if snapshot, err := x.workQueue.cache.snapshotCache.GetSnapshot(id); err == nil {
+			node.SnapshotVersion = snapshot.GetVersion(resource.ListenerType)
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// ListCertificates returns the certificates in the storage
+func (x *XDS) ListCertificates() ([]CertificateInfo, error) {
+	certificates := []CertificateInfo{}
+	certs, err := x.s.ListCerts()
+	if err != nil {
+		if os.IsNotExist(err) {
+			// the certificate directory of the local storage is created with the first certificate
+			return certificates, nil
+		}
+		return nil, fmt.Errorf("Couldn't list certificates: %s", err)
+	}
+	for certName, certPEM := range certs {
+		certificate := CertificateInfo{Name: certName}
+		cert, err := parseCertificate(certName, certPEM)
+		if err != nil {
+			certificate.Error = err.Error()
+		} else {
+			certificate.Domains = getCertificateDomains(cert)
+			certificate.NotBefore = cert.NotBefore
+			certificate.NotAfter = cert.NotAfter
+			certificate.RenewAfter = getCertificateRenewalDate(cert)
+		}
+		certificates = append(certificates, certificate)
+	}
+	sort.Slice(certificates, func(i, j int) bool {
+		return certificates[i].Name < certificates[j].Name
+	})
+	return certificates, nil
+}
+
+// TriggerRenewal checks the certificates for renewal without waiting for the renewal queue
+func (x *XDS) TriggerRenewal() error {
+	if x.renewalQueue == nil {
+		return fmt.Errorf("Renewal queue is not running")
+	}
+	return x.renewalQueue.CheckRenewals()
+}
+
+// TriggerImport imports the objects in storage again
+func (x *XDS) TriggerImport() error {
+	return x.Resync()
+}
+
+func parseCertificate(name, certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse certificate PEM for %s", name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate for (%s): %s", name, err.Error())
+	}
+	return cert, nil
+}
+
+// getCertificateDomains returns the common name and the other dns names of a certificate
+func getCertificateDomains(cert *x509.Certificate) []string {
+	domains := []string{cert.Subject.CommonName}
+	for _, v := range cert.DNSNames {
+		if v != domains[0] {
+			domains = append(domains, v)
+		}
+	}
+	return domains
+}
+
+// getCertificateRenewalDate returns the date from which a certificate is renewed (30 days before it expires)
+func getCertificateRenewalDate(cert *x509.Certificate) time.Time {
+	return cert.NotAfter.AddDate(0, 0, -30)
+}
+
+// RedactPrivateKeys replaces the inline private keys in the tls contexts of the listeners. The listeners are copied,
+// the listeners in the cache keep their keys
+func (r *RenderResult) RedactPrivateKeys() error {
+	for k, ll := range r.Listeners {
+		listener := proto.Clone(ll).(*api.Listener)
+		for _, filterChain := range listener.FilterChains {
+			typedConfig, ok := filterChain.GetTransportSocket().GetConfigType().(*core.TransportSocket_TypedConfig)
+			if !ok {
+				continue
+			}
+			tlsContext, err := getTransportSocketDownStreamTlsSocket(typedConfig)
+			if err != nil {
+				return fmt.Errorf("Couldn't read tls context of listener %s: %s", listener.Name, err)
+			}
+			for _, certificate := range tlsContext.GetCommonTlsContext().GetTlsCertificates() {
+				if certificate.GetPrivateKey().GetInlineString() != "" || len(certificate.GetPrivateKey().GetInlineBytes()) != 0 {
+					certificate.PrivateKey.Specifier = &core.DataSource_InlineString{InlineString: redacted}
+				}
+			}
+			pbst, err := ptypes.MarshalAny(tlsContext)
+			if err != nil {
+				return err
+			}
+			typedConfig.TypedConfig = pbst
+		}
+		r.Listeners[k] = listener
+	}
+	return nil
+}
+
+// RedactObject returns a copy of the object without the private keys
+func RedactObject(object pkgApi.Object) pkgApi.Object {
+	if mTLS, ok := object.Data.(pkgApi.MTLS); ok && mTLS.Spec.PrivateKey != "" {
+		mTLS.Spec.PrivateKey = redacted
+		object.Data = mTLS
+	}
+	return object
+}
@@ -0,0 +1,69 @@
+package envoy
+
+import (
+	"crypto/x509"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	snapshotVersionMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "roxprox_snapshot_version",
+		Help: "Version number of the latest snapshot",
+	})
+	snapshotBuildDurationMetric = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "roxprox_snapshot_build_duration_seconds",
+		Help:    "Time to process the work queue items and build the snapshot",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10},
+	})
+	workQueueItemsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "roxprox_workqueue_items_total",
+		Help: "Processed work queue items by action and state",
+	}, []string{"action", "state"})
+	validateCacheFailuresMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "roxprox_validate_cache_failures_total",
+		Help: "Snapshots that weren't built because the cache is invalid",
+	})
+	connectedNodesMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "roxprox_connected_nodes",
+		Help: "Envoy nodes with an open xds stream",
+	})
+	xdsResponsesMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "roxprox_xds_responses_total",
+		Help: "Responses accepted (ACK) or rejected (NACK) by envoy",
+	}, []string{"node", "type", "result"})
+	certificateExpiryMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "roxprox_certificate_expiry_timestamp_seconds",
+		Help: "Expiry of the certificates (unix time)",
+	}, []string{"name"})
+	acmeOrdersMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "roxprox_acme_orders_total",
+		Help: "ACME certificate orders by result",
+	}, []string{"result"})
+)
+
+// ACME order results
+const (
+	acmeOrderIssued           = "issued"
+	acmeOrderFailed           = "failed"
+	acmeOrderValidationFailed = "validation_failed"
+)
+
+func recordCertificateExpiry(name string, cert *x509.Certificate) {
+	certificateExpiryMetric.WithLabelValues(name).Set(float64(cert.NotAfter.Unix()))
+}
+
+// recordXDSResponse records whether envoy accepted the previous response of a stream. The first request of a stream
+// has no response nonce and is not a response to anything
+func recordXDSResponse(node, typeURL, responseNonce string, rejected bool) {
+	if responseNonce == "" {
+		return
+	}
+	result := "ack"
+	if rejected {
+		result = "nack"
+	}
+	xdsResponsesMetric.WithLabelValues(node, typeURL[strings.LastIndex(typeURL, ".")+1:], result).Inc()
+}
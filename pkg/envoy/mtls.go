@@ -2,7 +2,7 @@ package envoy
 
 import (
 	"fmt"
-	"strconv"
+	"net"
 	"strings"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -56,11 +56,15 @@ func (l *MTLS) updateMTLSListener(cache *WorkQueueCache, params ListenerParams,
 	}
 	// set AllowedIPRanges
 	if len(mTLSParams.AllowedIPRanges) > 0 {
+		rbacTypedConfig, err := getRBACConfig(mTLSParams)
+		if err != nil {
+			return fmt.Errorf("Cannot create/update mTLS listener: %s", err)
+		}
 		rbacFilter := []*api.Filter{
 			{
 				Name: "envoy.filters.network.rbac",
 				ConfigType: &api.Filter_TypedConfig{
-					TypedConfig: getRBACConfig(mTLSParams),
+					TypedConfig: rbacTypedConfig,
 				},
 			},
 		}
@@ -121,26 +125,25 @@ func (l *MTLS) updateMTLSListener(cache *WorkQueueCache, params ListenerParams,
 
 	return nil
 }
-func getRBACConfig(mTLSParams MTLSParams) *anypb.Any {
+func getRBACConfig(mTLSParams MTLSParams) (*anypb.Any, error) {
 	principals := []*rbacConfig.Principal{}
 	for _, ipRange := range mTLSParams.AllowedIPRanges {
-		ipRangeSplit := strings.Split(ipRange, "/")
-		prefixLen, err := strconv.ParseUint(ipRangeSplit[1], 10, 32)
-		if len(ipRangeSplit) != 2 || err != nil {
-			logger.Warningf("Invalid IP address range: %s in listener: l_mtls_%s", ipRange, mTLSParams.Name)
-		} else {
-			principals = append(principals, &rbacConfig.Principal{
-
-				Identifier: &rbacConfig.Principal_DirectRemoteIp{
-					DirectRemoteIp: &core.CidrRange{
-						AddressPrefix: ipRangeSplit[0],
-						PrefixLen: &wrappers.UInt32Value{
-							Value: uint32(prefixLen),
-						},
+		// the ranges are validated when the mTLS object is decoded, see api.validateCIDR
+		_, ipNet, err := net.ParseCIDR(ipRange)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid IP address range: %s in listener: l_mtls_%s", ipRange, mTLSParams.Name)
+		}
+		prefixLen, _ := ipNet.Mask.Size()
+		principals = append(principals, &rbacConfig.Principal{
+			Identifier: &rbacConfig.Principal_DirectRemoteIp{
+				DirectRemoteIp: &core.CidrRange{
+					AddressPrefix: strings.Split(ipRange, "/")[0],
+					PrefixLen: &wrappers.UInt32Value{
+						Value: uint32(prefixLen),
 					},
 				},
-			})
-		}
+			},
+		})
 	}
 	r := &rbac.RBAC{
 		StatPrefix: "rbac_" + mTLSParams.Name,
@@ -165,5 +168,5 @@ func getRBACConfig(mTLSParams MTLSParams) *anypb.Any {
 		panic(err)
 	}
 
-	return pbst
+	return pbst, nil
 }
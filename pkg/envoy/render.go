@@ -0,0 +1,149 @@
+package envoy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	clusterAPI "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	api "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	cacheTypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/golang/protobuf/proto"
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	storage "github.com/in4it/roxprox/pkg/storage"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v2"
+)
+
+// RenderResult is the envoy configuration generated from the objects in a storage
+type RenderResult struct {
+	Clusters  []cacheTypes.Resource
+	Listeners []cacheTypes.Resource
+	// Objects contains the objects that were read from the storage (not set when the result is read with UnmarshalRenderResult)
+	Objects []pkgApi.Object
+	// Pending contains the objects that weren't imported because of unresolved dependencies
+	Pending []PendingObject
+	// Errors contains the validation errors of the generated configuration
+	Errors []string
+}
+
+type PendingObject struct {
+	Kind         string
+	Name         string
+	Dependencies []ObjectDependency
+}
+
+// Render imports the objects from the storage and generates the envoy configuration, without starting
+// a grpc server or waiting for envoy. Import errors are returned as error, validation errors are part of the result
+func Render(s storage.Storage) (*RenderResult, error) {
+	x := NewXDS(s, "", "")
+	defer x.workQueue.stop()
+	x.workQueue.metrics = false
+	err := x.ImportObjects()
+	if err != nil {
+		return nil, err
+	}
+	result := &RenderResult{
+		Clusters:  x.workQueue.cache.clusters,
+		Listeners: x.workQueue.cache.listeners,
+		Objects:   x.objects,
+	}
+	for _, object := range x.objectsPending {
+		result.Pending = append(result.Pending, PendingObject{
+			Kind:         object.Kind,
+			Name:         object.Metadata.Name,
+			Dependencies: x.getObjectUnresolvedDependencies(object),
+		})
+	}
+	validated, err := x.workQueue.validateCache()
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else if !validated {
+		result.Errors = append(result.Errors, "Validation of the listeners failed")
+	}
+	return result, nil
+}
+
+// Valid returns true when the configuration has no validation errors and no pending objects
+func (r *RenderResult) Valid() bool {
+	return len(r.Errors) == 0 && len(r.Pending) == 0
+}
+
+// Marshal returns the clusters and listeners in the envoy json or yaml format
+func (r *RenderResult) Marshal(format pkgApi.Format) ([]byte, error) {
+	var (
+		config struct {
+			Clusters  []json.RawMessage `json:"clusters"`
+			Listeners []json.RawMessage `json:"listeners"`
+		}
+		err error
+	)
+	config.Clusters, err = marshalResources(r.Clusters)
+	if err != nil {
+		return nil, err
+	}
+	config.Listeners, err = marshalResources(r.Listeners)
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if format == pkgApi.FormatJSON {
+		return append(out, '\n'), nil
+	}
+	return jsonToYAML(out)
+}
+
+// UnmarshalRenderResult reads the clusters and listeners from the json output of Marshal
+func UnmarshalRenderResult(data []byte) (*RenderResult, error) {
+	var config struct {
+		Clusters  []json.RawMessage `json:"clusters"`
+		Listeners []json.RawMessage `json:"listeners"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Couldn't unmarshal configuration: %s", err)
+	}
+	result := &RenderResult{}
+	for _, data := range config.Clusters {
+		cluster := &clusterAPI.Cluster{}
+		if err := protojson.Unmarshal(data, cluster); err != nil {
+			return nil, fmt.Errorf("Couldn't unmarshal cluster: %s", err)
+		}
+		result.Clusters = append(result.Clusters, cluster)
+	}
+	for _, data := range config.Listeners {
+		listener := &api.Listener{}
+		if err := protojson.Unmarshal(data, listener); err != nil {
+			return nil, fmt.Errorf("Couldn't unmarshal listener: %s", err)
+		}
+		result.Listeners = append(result.Listeners, listener)
+	}
+	return result, nil
+}
+
+// marshalResources returns the resources in the envoy json format. The output of protojson is compacted, because its whitespace is not stable
+func marshalResources(resources []cacheTypes.Resource) ([]json.RawMessage, error) {
+	out := []json.RawMessage{}
+	for _, resource := range resources {
+		data, err := protojson.Marshal(proto.MessageV2(resource))
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't marshal resource: %s", err)
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, data); err != nil {
+			return nil, fmt.Errorf("Couldn't marshal resource: %s", err)
+		}
+		out = append(out, compacted.Bytes())
+	}
+	return out, nil
+}
+
+func jsonToYAML(data []byte) ([]byte, error) {
+	var value yaml.MapSlice
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("Couldn't convert to yaml: %s", err)
+	}
+	return yaml.Marshal(value)
+}
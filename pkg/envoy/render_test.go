@@ -0,0 +1,85 @@
+package envoy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/storage"
+	localStorage "github.com/in4it/roxprox/pkg/storage/local"
+)
+
+func TestRender(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "render")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	for _, filename := range []string{"test1.yaml", "test-mtls-rule.yaml"} {
+		contents, err := ioutil.ReadFile("testdata/" + filename)
+		if err != nil {
+			t.Errorf("ReadFile error: %s", err)
+			return
+		}
+		if err := ioutil.WriteFile(dir+"/"+filename, contents, 0644); err != nil {
+			t.Errorf("WriteFile error: %s", err)
+			return
+		}
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+
+	result, err := Render(s)
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+	if len(result.Clusters) != 1 || len(result.Listeners) != 1 {
+		t.Errorf("Expected 1 cluster and 1 listener, got %d clusters and %d listeners", len(result.Clusters), len(result.Listeners))
+		return
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Unexpected validation errors: %v", result.Errors)
+	}
+	if len(result.Pending) != 1 || result.Pending[0].Name != "mtls-testrule" || result.Pending[0].Dependencies[0].Name != "test-mtls" {
+		t.Errorf("Unexpected pending objects: %+v", result.Pending)
+	}
+	if result.Valid() {
+		t.Errorf("Expected result with pending objects to be invalid")
+	}
+
+	out, err := result.Marshal(pkgApi.FormatJSON)
+	if err != nil {
+		t.Errorf("Marshal error: %s", err)
+		return
+	}
+	var config struct {
+		Clusters []struct {
+			Name string `json:"name"`
+		} `json:"clusters"`
+		Listeners []json.RawMessage `json:"listeners"`
+	}
+	if err := json.Unmarshal(out, &config); err != nil {
+		t.Errorf("Couldn't unmarshal json output: %s", err)
+		return
+	}
+	if len(config.Clusters) != 1 || config.Clusters[0].Name != "test1" || len(config.Listeners) != 1 {
+		t.Errorf("Unexpected json output: %s", out)
+	}
+
+	out, err = result.Marshal(pkgApi.FormatYAML)
+	if err != nil {
+		t.Errorf("Marshal error: %s", err)
+		return
+	}
+	if !strings.HasPrefix(string(out), "clusters:\n- name: test1\n") {
+		t.Errorf("Unexpected yaml output: %s", out)
+	}
+}
@@ -1,9 +1,6 @@
 package envoy
 
 import (
-	"crypto/x509"
-	"encoding/pem"
-	"fmt"
 	"time"
 
 	"github.com/in4it/roxprox/pkg/storage"
@@ -16,11 +13,16 @@ type RenewalQueue struct {
 	workQueue *WorkQueue
 }
 
-func NewRenewalQueue(s storage.Storage, acmeContact string, workQueue *WorkQueue) (*RenewalQueue, error) {
+func NewRenewalQueue(s storage.Storage, acmeAccount AcmeAccount, workQueue *WorkQueue) (*RenewalQueue, error) {
 	c := make(chan struct{})
-	cert, err := newCert(s, acmeContact)
-	if err != nil {
-		return nil, err
+	// reuse the account of the workqueue, the account key might have been rolled over
+	cert := workQueue.cert
+	if cert == nil {
+		var err error
+		cert, err = newCert(s, acmeAccount)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	r := &RenewalQueue{c: c, s: s, cert: cert, workQueue: workQueue}
@@ -52,24 +54,16 @@ func (r *RenewalQueue) CheckRenewals() error {
 	}
 
 	for certName, certPEM := range certs {
-		block, _ := pem.Decode([]byte(certPEM))
-		if block == nil {
-			return fmt.Errorf("failed to parse certificate PEM for %s", certName)
-		}
-		cert, err := x509.ParseCertificate(block.Bytes)
+		cert, err := parseCertificate(certName, certPEM)
 		if err != nil {
-			return fmt.Errorf("failed to parse certificate for (%s): %s", certName, err.Error())
+			return err
 		}
+		recordCertificateExpiry(certName, cert)
 
-		renewalDate := cert.NotAfter.AddDate(0, 0, -30)
+		renewalDate := getCertificateRenewalDate(cert)
 		if time.Now().After(renewalDate) {
 			logger.Debugf("Certificate %s needs to be renewed", certName)
-			domains := []string{cert.Subject.CommonName}
-			for _, v := range cert.DNSNames {
-				if v != domains[0] {
-					domains = append(domains, v)
-				}
-			}
+			domains := getCertificateDomains(cert)
 			workQueueItems = append(workQueueItems, WorkQueueItem{
 				Action: "verifyDomains",
 				CreateCertParams: CreateCertParams{
@@ -0,0 +1,334 @@
+package envoy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	clusterAPI "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	api "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	cacheTypes "github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	"google.golang.org/protobuf/encoding/protojson"
+	protoV2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"gopkg.in/yaml.v2"
+)
+
+// StaticBootstrapOptions configures the static envoy bootstrap
+type StaticBootstrapOptions struct {
+	NodeID       string
+	NodeCluster  string
+	AdminAddress string // defaults to 127.0.0.1
+	AdminPort    uint32 // defaults to 9901
+	// CertPath moves the inline certificates and keys of the listeners to files in this directory (default inline)
+	CertPath string
+	// Clusters are added to the static resources, for clusters that are not managed by roxprox (e.g. the rate limit service)
+	Clusters []*clusterAPI.Cluster
+}
+
+// StaticBootstrap is an envoy bootstrap with the listeners and clusters as static resources, to run envoy without control plane
+type StaticBootstrap struct {
+	Bootstrap *bootstrap.Bootstrap
+	// Files contains the certificates and keys the bootstrap refers to when CertPath is set (path: contents)
+	Files map[string]string
+}
+
+// ExportStaticBootstrap returns a static bootstrap with the resources that are sent to envoy
+func (x *XDS) ExportStaticBootstrap(options StaticBootstrapOptions) (*StaticBootstrap, error) {
+	return x.workQueue.ExportStaticBootstrap(options)
+}
+
+// ExportStaticBootstrap returns a static bootstrap with the resources of the latest snapshot
+func (w *WorkQueue) ExportStaticBootstrap(options StaticBootstrapOptions) (*StaticBootstrap, error) {
+	_, clusters, listeners, err := w.getLatestSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return NewStaticBootstrap(clusters, listeners, options)
+}
+
+// getLatestSnapshot returns the version and the sorted clusters and listeners of the latest snapshot
+func (w *WorkQueue) getLatestSnapshot() (string, []cacheTypes.Resource, []cacheTypes.Resource, error) {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	version := w.latestSnapshot.GetVersion(resource.ListenerType)
+	if version == "" {
+		return "", nil, nil, fmt.Errorf("No snapshot available")
+	}
+	return version,
+		sortedResources(w.latestSnapshot.GetResources(resource.ClusterType)),
+		sortedResources(w.latestSnapshot.GetResources(resource.ListenerType)),
+		nil
+}
+
+// getLatestSnapshotVersion returns the version of the latest snapshot, or an empty string when there's no snapshot yet
+func (w *WorkQueue) getLatestSnapshotVersion() string {
+	w.snapshotMu.RLock()
+	defer w.snapshotMu.RUnlock()
+	return w.latestSnapshot.GetVersion(resource.ListenerType)
+}
+
+// cloneResources returns deep copies of the resources
+func cloneResources(resources []cacheTypes.Resource) []cacheTypes.Resource {
+	cloned := make([]cacheTypes.Resource, len(resources))
+	for k, r := range resources {
+		cloned[k] = proto.Clone(r).(cacheTypes.Resource)
+	}
+	return cloned
+}
+
+func sortedResources(resources map[string]cacheTypes.Resource) []cacheTypes.Resource {
+	var names []string
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sorted []cacheTypes.Resource
+	for _, name := range names {
+		sorted = append(sorted, resources[name])
+	}
+	return sorted
+}
+
+// NewStaticBootstrap creates a bootstrap with the clusters and listeners as static resources. The bootstrap is validated
+// with the envoy validation rules, including the typed configs of the filters and transport sockets
+func NewStaticBootstrap(clusters, listeners []cacheTypes.Resource, options StaticBootstrapOptions) (*StaticBootstrap, error) {
+	if options.AdminAddress == "" {
+		options.AdminAddress = "127.0.0.1"
+	}
+	if options.AdminPort == 0 {
+		options.AdminPort = 9901
+	}
+	staticBootstrap := &StaticBootstrap{
+		Bootstrap: &bootstrap.Bootstrap{
+			Admin:           getBootstrapAdmin(options.AdminAddress, options.AdminPort),
+			StaticResources: &bootstrap.Bootstrap_StaticResources{},
+		},
+		Files: make(map[string]string),
+	}
+	if options.NodeID != "" || options.NodeCluster != "" {
+		staticBootstrap.Bootstrap.Node = &core.Node{Id: options.NodeID, Cluster: options.NodeCluster}
+	}
+	for _, cluster := range clusters {
+		staticBootstrap.Bootstrap.StaticResources.Clusters = append(staticBootstrap.Bootstrap.StaticResources.Clusters, proto.Clone(cluster).(*clusterAPI.Cluster))
+	}
+	staticBootstrap.Bootstrap.StaticResources.Clusters = append(staticBootstrap.Bootstrap.StaticResources.Clusters, options.Clusters...)
+	for _, ll := range listeners {
+		// the listeners are copied, because the certificates are replaced by files
+		listener := proto.Clone(ll).(*api.Listener)
+		if options.CertPath != "" {
+			if err := staticBootstrap.moveCertsToFiles(listener, options.CertPath); err != nil {
+				return nil, err
+			}
+		}
+		staticBootstrap.Bootstrap.StaticResources.Listeners = append(staticBootstrap.Bootstrap.StaticResources.Listeners, listener)
+	}
+	if err := validateBootstrap(staticBootstrap.Bootstrap); err != nil {
+		return nil, fmt.Errorf("Validation of the bootstrap failed: %s", err)
+	}
+	return staticBootstrap, nil
+}
+
+// moveCertsToFiles replaces the inline certificates and keys in the tls contexts of the listener with filenames
+func (s *StaticBootstrap) moveCertsToFiles(listener *api.Listener, certPath string) error {
+	for filterChainID, filterChain := range listener.FilterChains {
+		typedConfig, ok := filterChain.GetTransportSocket().GetConfigType().(*core.TransportSocket_TypedConfig)
+		if !ok {
+			continue
+		}
+		tlsContext, err := getTransportSocketDownStreamTlsSocket(typedConfig)
+		if err != nil {
+			return fmt.Errorf("Couldn't read tls context of listener %s: %s", listener.Name, err)
+		}
+		prefix := filepath.Join(certPath, fmt.Sprintf("%s-%d", listener.Name, filterChainID))
+		for k, certificate := range tlsContext.GetCommonTlsContext().GetTlsCertificates() {
+			suffix := ""
+			if k > 0 {
+				suffix = fmt.Sprintf("-%d", k)
+			}
+			s.moveToFile(certificate.CertificateChain, prefix+"-cert"+suffix+".pem")
+			s.moveToFile(certificate.PrivateKey, prefix+"-key"+suffix+".pem")
+		}
+		s.moveToFile(tlsContext.GetCommonTlsContext().GetValidationContext().GetTrustedCa(), prefix+"-ca.pem")
+		pbst, err := ptypes.MarshalAny(tlsContext)
+		if err != nil {
+			return err
+		}
+		typedConfig.TypedConfig = pbst
+	}
+	return nil
+}
+
+func (s *StaticBootstrap) moveToFile(dataSource *core.DataSource, filename string) {
+	if dataSource.GetInlineString() == "" {
+		return
+	}
+	s.Files[filename] = dataSource.GetInlineString()
+	dataSource.Specifier = &core.DataSource_Filename{Filename: filename}
+}
+
+// walkMessage calls fn for the message and all messages within, including the messages in typed configs
+func walkMessage(message protoreflect.ProtoMessage, fn func(message protoreflect.ProtoMessage) error) error {
+	if err := fn(message); err != nil {
+		return err
+	}
+	var err error
+	message.ProtoReflect().Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if field.Kind() != protoreflect.MessageKind {
+			return true
+		}
+		var messages []protoreflect.Message
+		switch {
+		case field.IsList():
+			for i := 0; i < value.List().Len(); i++ {
+				messages = append(messages, value.List().Get(i).Message())
+			}
+		case field.IsMap():
+			if field.MapValue().Kind() == protoreflect.MessageKind {
+				value.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+					messages = append(messages, v.Message())
+					return true
+				})
+			}
+		default:
+			messages = append(messages, value.Message())
+		}
+		for _, m := range messages {
+			if anyMessage, ok := m.Interface().(*anypb.Any); ok {
+				var typed protoV2.Message
+				typed, err = anyMessage.UnmarshalNew()
+				if err != nil {
+					err = fmt.Errorf("%s: %s", field.Name(), err)
+					return false
+				}
+				if err = walkMessage(typed, fn); err != nil {
+					err = fmt.Errorf("%s (%s): %s", field.Name(), anyMessage.TypeUrl, err)
+					return false
+				}
+			} else if err = walkMessage(m.Interface(), fn); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// validateBootstrap runs the envoy validation rules on the bootstrap and the typed configs, and checks
+// whether the clusters that are referenced by routes, grpc services and http uris are static resources
+func validateBootstrap(b *bootstrap.Bootstrap) error {
+	clusterNames := make(map[string]bool)
+	for _, cluster := range b.StaticResources.Clusters {
+		clusterNames[cluster.Name] = true
+	}
+	return walkMessage(proto.MessageV2(b), func(message protoreflect.ProtoMessage) error {
+		if v, ok := message.(interface{ Validate() error }); ok {
+			if err := v.Validate(); err != nil {
+				return err
+			}
+		}
+		var clusterName string
+		switch m := message.(type) {
+		case *route.RouteAction:
+			clusterName = m.GetCluster()
+		case *core.GrpcService_EnvoyGrpc:
+			clusterName = m.GetClusterName()
+		case *core.HttpUri:
+			clusterName = m.GetCluster()
+		}
+		if clusterName != "" && !clusterNames[clusterName] {
+			return fmt.Errorf("Cluster %s is referenced, but is not a static resource", clusterName)
+		}
+		return nil
+	})
+}
+
+// UnmarshalStaticClusters reads a list of envoy clusters in the json or yaml format
+func UnmarshalStaticClusters(data []byte) ([]*clusterAPI.Cluster, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("Couldn't unmarshal clusters: %s", err)
+	}
+	list, ok := yamlToJSONValue(value).([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Couldn't unmarshal clusters: expected a list of clusters")
+	}
+	var clusters []*clusterAPI.Cluster
+	for k, item := range list {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		cluster := &clusterAPI.Cluster{}
+		if err := protojson.Unmarshal(data, cluster); err != nil {
+			return nil, fmt.Errorf("Couldn't unmarshal cluster %d: %s", k+1, err)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// yamlToJSONValue converts the maps of a decoded yaml document to maps that can be encoded as json
+func yamlToJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for key, item := range v {
+			m[fmt.Sprint(key)] = yamlToJSONValue(item)
+		}
+		return m
+	case []interface{}:
+		for k, item := range v {
+			v[k] = yamlToJSONValue(item)
+		}
+	}
+	return value
+}
+
+// Marshal returns the bootstrap in the envoy json or yaml format
+func (s *StaticBootstrap) Marshal(format pkgApi.Format) ([]byte, error) {
+	return MarshalBootstrap(s.Bootstrap, format)
+}
+
+// MarshalBootstrap returns the bootstrap in the envoy json or yaml format, with the field names of the envoy documentation
+func MarshalBootstrap(b *bootstrap.Bootstrap, format pkgApi.Format) ([]byte, error) {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(proto.MessageV2(b))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't marshal bootstrap: %s", err)
+	}
+	// the output of protojson is indented again, because its whitespace is not stable
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return nil, fmt.Errorf("Couldn't marshal bootstrap: %s", err)
+	}
+	if format == pkgApi.FormatJSON {
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+	}
+	return jsonToYAML(out.Bytes())
+}
+
+// WriteFiles writes the certificates and keys the bootstrap refers to
+func (s *StaticBootstrap) WriteFiles() error {
+	for filename, contents := range s.Files {
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			return fmt.Errorf("Couldn't create directory for %s: %s", filename, err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(contents), 0600); err != nil {
+			return fmt.Errorf("Couldn't write %s: %s", filename, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package envoy
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/storage"
+	localStorage "github.com/in4it/roxprox/pkg/storage/local"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func renderTestdata(filenames []string) (*RenderResult, error) {
+	var contents []byte
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile("testdata/" + filename)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, []byte("\n---\n")...)
+		contents = append(contents, data...)
+	}
+	return renderObjects(string(contents))
+}
+
+func TestStaticBootstrap(t *testing.T) {
+	rateLimitClusters, err := UnmarshalStaticClusters([]byte(`
+- name: ratelimit
+  type: STRICT_DNS
+  connect_timeout: 1s
+  http2_protocol_options: {}
+  load_assignment:
+    cluster_name: ratelimit
+    endpoints:
+    - lb_endpoints:
+      - endpoint:
+          address:
+            socket_address:
+              address: ratelimit
+              port_value: 8081
+`))
+	if err != nil {
+		t.Errorf("UnmarshalStaticClusters error: %s", err)
+		return
+	}
+	tests := []struct {
+		golden    string
+		filenames []string
+		options   StaticBootstrapOptions
+		files     int
+	}{
+		{
+			golden:    "static-bootstrap-http.yaml.golden",
+			filenames: []string{"test1.yaml", "test-directresponse.yaml", "test-jwtprovider.yaml", "test-authz.yaml", "test-ratelimit.yaml"},
+			options:   StaticBootstrapOptions{NodeID: "ingress-gateway-1", NodeCluster: "ingress-gateway", Clusters: rateLimitClusters},
+		},
+		{
+			golden:    "static-bootstrap-mtls.yaml.golden",
+			filenames: []string{"test-mtls.yaml", "test-mtls-rule.yaml"},
+			options:   StaticBootstrapOptions{AdminAddress: "0.0.0.0", AdminPort: 9000, CertPath: "/etc/envoy/certs"},
+			files:     3,
+		},
+	}
+	for _, test := range tests {
+		config, err := renderTestdata(test.filenames)
+		if err != nil {
+			t.Errorf("Render error: %s", err)
+			return
+		}
+		if !config.Valid() {
+			t.Errorf("Configuration is not valid: %v %+v", config.Errors, config.Pending)
+			return
+		}
+		staticBootstrap, err := NewStaticBootstrap(config.Clusters, config.Listeners, test.options)
+		if err != nil {
+			t.Errorf("NewStaticBootstrap error: %s", err)
+			return
+		}
+		if len(staticBootstrap.Files) != test.files {
+			t.Errorf("Expected %d files, got: %+v", test.files, staticBootstrap.Files)
+		}
+		out, err := staticBootstrap.Marshal(pkgApi.FormatYAML)
+		if err != nil {
+			t.Errorf("Marshal error: %s", err)
+			return
+		}
+		if *updateGolden {
+			if err := ioutil.WriteFile("testdata/"+test.golden, out, 0644); err != nil {
+				t.Errorf("Couldn't update golden file: %s", err)
+			}
+			continue
+		}
+		golden, err := ioutil.ReadFile("testdata/" + test.golden)
+		if err != nil {
+			t.Errorf("Couldn't read golden file: %s", err)
+			return
+		}
+		if !bytes.Equal(out, golden) {
+			t.Errorf("Bootstrap doesn't match %s (run go test -update to update the golden file):\n%s", test.golden, out)
+		}
+		// validate with envoy when it is installed (the certificate files of the golden files don't exist)
+		if envoyPath, err := exec.LookPath("envoy"); err == nil && test.files == 0 {
+			if out, err := exec.Command(envoyPath, "--mode", "validate", "-c", "testdata/"+test.golden).CombinedOutput(); err != nil {
+				t.Errorf("Envoy validation of %s failed: %s\n%s", test.golden, err, out)
+			}
+		}
+	}
+
+	// envoy doesn't start when the rate limit cluster is missing
+	config, err := renderTestdata([]string{"test1.yaml", "test-ratelimit.yaml"})
+	if err != nil {
+		t.Errorf("Render error: %s", err)
+		return
+	}
+	_, err = NewStaticBootstrap(config.Clusters, config.Listeners, StaticBootstrapOptions{})
+	if err == nil || !strings.HasSuffix(err.Error(), "Cluster ratelimit is referenced, but is not a static resource") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestExportStaticBootstrap(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "export")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	for _, filename := range []string{"test-mtls.yaml", "test-mtls-rule.yaml"} {
+		data, err := ioutil.ReadFile("testdata/" + filename)
+		if err != nil {
+			t.Errorf("ReadFile error: %s", err)
+			return
+		}
+		if err := ioutil.WriteFile(dir+"/"+filename, data, 0644); err != nil {
+			t.Errorf("WriteFile error: %s", err)
+			return
+		}
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	x := NewXDS(s, "", "")
+	if _, err := x.workQueue.ExportStaticBootstrap(StaticBootstrapOptions{}); err == nil {
+		t.Errorf("Expected error when there is no snapshot")
+		return
+	}
+	if err := x.ImportObjects(); err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+	staticBootstrap, err := x.workQueue.ExportStaticBootstrap(StaticBootstrapOptions{CertPath: dir + "/certs"})
+	if err != nil {
+		t.Errorf("ExportStaticBootstrap error: %s", err)
+		return
+	}
+	if len(staticBootstrap.Bootstrap.StaticResources.Clusters) != 1 || len(staticBootstrap.Bootstrap.StaticResources.Listeners) != 1 {
+		t.Errorf("Unexpected static resources: %+v", staticBootstrap.Bootstrap.StaticResources)
+		return
+	}
+	if err := staticBootstrap.WriteFiles(); err != nil {
+		t.Errorf("WriteFiles error: %s", err)
+		return
+	}
+	key, err := ioutil.ReadFile(dir + "/certs/l_mtls_test-mtls-0-key.pem")
+	if err != nil || string(key) != "replaceme" {
+		t.Errorf("Unexpected key file: %s (error: %v)", key, err)
+	}
+	// the listeners in the cache still have the inline certificates
+	if bootstrapWithInlineCerts, err := x.workQueue.ExportStaticBootstrap(StaticBootstrapOptions{}); err != nil || len(bootstrapWithInlineCerts.Files) != 0 {
+		t.Errorf("Unexpected files: %+v (error: %v)", bootstrapWithInlineCerts, err)
+	}
+}
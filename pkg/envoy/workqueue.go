@@ -3,6 +3,7 @@ package envoy
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,18 +28,20 @@ type WorkQueue struct {
 	rateLimit       *RateLimit
 	mTLS            *MTLS
 	cluster         *Cluster
-	acmeContact     string
 	latestSnapshot  cache.Snapshot
+	snapshotMu      sync.RWMutex // protects latestSnapshot
+	configVersion   func() string
+	metrics         bool // false for the work queue of Render, which shouldn't change the metrics of the server
 }
 
-func NewWorkQueue(s storage.Storage, acmeContact string) (*WorkQueue, error) {
+func NewWorkQueue(s storage.Storage, acmeAccount AcmeAccount) (*WorkQueue, error) {
 	var cert *Cert
 	var err error
 	cs := make(chan WorkQueueSubmissionState)
 	c := make(chan WorkQueueItem)
 
-	if acmeContact != "" {
-		cert, err = newCert(s, acmeContact)
+	if acmeAccount.Contact != "" {
+		cert, err = newCert(s, acmeAccount)
 		if err != nil {
 			return nil, err
 		}
@@ -57,6 +60,12 @@ func NewWorkQueue(s storage.Storage, acmeContact string) (*WorkQueue, error) {
 		accessLogServer: newAccessLogServer(),
 		rateLimit:       newRateLimit(),
 		mTLS:            newMTLS(),
+		metrics:         true,
+	}
+
+	// the snapshot version includes the version of the configuration (for example the git commit)
+	if versionedStorage, ok := s.(storage.VersionedStorage); ok {
+		w.configVersion = versionedStorage.GetConfigVersion
 	}
 
 	// run queue to resolve dependencies
@@ -85,6 +94,7 @@ func removeResource(slice []cacheTypes.Resource, s int) []cacheTypes.Resource {
 func (w *WorkQueue) Submit(items []WorkQueueItem) (string, error) {
 	id := uuid.New().String()
 	var updateXds bool
+	start := time.Now()
 
 	for k, item := range items {
 		itemID := uuid.New().String()
@@ -323,8 +333,10 @@ func (w *WorkQueue) Submit(items []WorkQueueItem) (string, error) {
 				certBundle, privateKeyPem, err := w.cert.CreateCert(item.CreateCertParams)
 				if err != nil {
 					logger.Errorf("error while creating cert: %s", err)
+					acmeOrdersMetric.WithLabelValues(acmeOrderFailed).Inc()
 					item.state = "error"
 				} else {
+					acmeOrdersMetric.WithLabelValues(acmeOrderIssued).Inc()
 					var newItems []WorkQueueItem
 					for _, domain := range item.CreateCertParams.Domains {
 						newItems = append(newItems, WorkQueueItem{
@@ -356,12 +368,22 @@ func (w *WorkQueue) Submit(items []WorkQueueItem) (string, error) {
 		default:
 			logger.Errorf("Wrong action submitted to workingqueue")
 		}
+		state := item.state
+		if state == "" {
+			state = "none"
+		}
+		if w.metrics {
+			workQueueItemsMetric.WithLabelValues(item.Action, state).Inc()
+		}
 	}
 
 	if updateXds {
 		validated, err := w.validateCache()
 		if err != nil || !validated {
 			logger.Errorf("Cache is not valid, not updating snapshot until cache is fixed (error: %s)", err)
+			if w.metrics {
+				validateCacheFailuresMetric.Inc()
+			}
 			return id, err
 		}
 
@@ -372,6 +394,9 @@ func (w *WorkQueue) Submit(items []WorkQueueItem) (string, error) {
 		logger.Debugf("ListenerNames: %s", strings.Join(listenerNames, ","))
 
 		w.updateXds()
+		if w.metrics {
+			snapshotBuildDurationMetric.Observe(time.Since(start).Seconds())
+		}
 	}
 
 	return id, nil
@@ -387,9 +412,23 @@ func InArray(a []string, v string) (ret bool, i int) {
 func (w *WorkQueue) updateXds() {
 	now := time.Now().UnixNano()
 	atomic.AddInt64(&w.cache.version, 1)
-	w.latestSnapshot = cache.NewSnapshot(fmt.Sprint(now)+"-"+fmt.Sprint(w.cache.version), nil, w.cache.clusters, nil, w.cache.listeners, nil, nil)
+	version := fmt.Sprint(now) + "-" + fmt.Sprint(w.cache.version)
+	if w.configVersion != nil {
+		if configVersion := w.configVersion(); configVersion != "" {
+			version += "-" + configVersion
+		}
+	}
+	logger.Debugf("New snapshot version: %s", version)
+	// the snapshot gets copies of the resources, the resources in the cache are changed by the next submit
+	snapshot := cache.NewSnapshot(version, nil, cloneResources(w.cache.clusters), nil, cloneResources(w.cache.listeners), nil, nil)
+	w.snapshotMu.Lock()
+	w.latestSnapshot = snapshot
+	w.snapshotMu.Unlock()
+	if w.metrics {
+		snapshotVersionMetric.Set(float64(w.cache.version))
+	}
 	var nodeUpdated []string
-	for _, v := range w.callback.connections {
+	for _, v := range w.callback.getConnections() {
 		if ret, _ := InArray(nodeUpdated, v.Id); !ret {
 			nodeUpdated = append(nodeUpdated, v.Id)
 			w.updateXdsForNode(v.Id)
@@ -399,14 +438,20 @@ func (w *WorkQueue) updateXds() {
 func (w *WorkQueue) updateXdsForNode(node string) {
 	if w.cache.version > 0 {
 		logger.Debugf("Updating snapshot for: %s to version %d", node, w.cache.version)
-		w.cache.snapshotCache.SetSnapshot(node, w.latestSnapshot)
+		w.snapshotMu.RLock()
+		snapshot := w.latestSnapshot
+		w.snapshotMu.RUnlock()
+		w.cache.snapshotCache.SetSnapshot(node, snapshot)
 	} else {
 		logger.Debugf("Still at version 0, waiting for init")
 	}
 }
 func (w *WorkQueue) runUpdateXDSForNewNodes() {
 	for {
-		newNode := <-w.callback.newNode
+		newNode, ok := <-w.callback.newNode
+		if !ok {
+			return
+		}
 		logger.Debugf("Discovered new node: %s", newNode.id)
 		w.updateXdsForNode(newNode.id)
 	}
@@ -419,7 +464,10 @@ func (w *WorkQueue) removeElementFromState(slice []WorkQueueSubmissionState, s i
 func (w *WorkQueue) resolveDependsOn() {
 	var stateQueue []WorkQueueSubmissionState
 	for {
-		item := <-w.c
+		item, ok := <-w.c
+		if !ok {
+			return
+		}
 		var finished []bool
 		logger.Debugf("Starting to resolv dependencies for: %+v", item)
 		for _, dependsOnItemID := range item.DependsOnItemIDs {
@@ -453,6 +501,14 @@ func (w *WorkQueue) resolveDependsOn() {
 	}
 }
 
+// stop stops the goroutines of a workqueue that doesn't serve envoys, e.g. the workqueue of a render
+func (w *WorkQueue) stop() {
+	close(w.c)
+	if w.callback != nil {
+		close(w.callback.newNode)
+	}
+}
+
 func (w *WorkQueue) waitForValidation(id, itemID string, params ChallengeParams) {
 	if w.cert == nil {
 		logger.Errorf("Cert feature is disabled")
@@ -462,6 +518,7 @@ func (w *WorkQueue) waitForValidation(id, itemID string, params ChallengeParams)
 	result, err := w.cert.a.WaitForAuthz(params.Domain, params.AuthzURI)
 	if err != nil {
 		logger.Debugf("Error while waiting for validation: %s", err)
+		acmeOrdersMetric.WithLabelValues(acmeOrderValidationFailed).Inc()
 		// set status to error
 		logger.Debugf("Submitting id: %s, state: error, itemID: %s", id, itemID)
 		w.cs <- WorkQueueSubmissionState{id: id, state: "error", itemID: itemID}
@@ -0,0 +1,319 @@
+package envoy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	storage "github.com/in4it/roxprox/pkg/storage"
+	"github.com/in4it/roxprox/pkg/storage/util"
+	"github.com/in4it/roxprox/proto/notification"
+)
+
+var invalidFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// WriteErrorReason tells why ApplyObject or DeleteObject didn't write to the storage
+type WriteErrorReason int
+
+const (
+	// WriteErrorInvalid is returned when the object, or the configuration with the change, is invalid
+	WriteErrorInvalid WriteErrorReason = iota
+	// WriteErrorNotFound is returned when the object to delete doesn't exist
+	WriteErrorNotFound
+	// WriteErrorConflict is returned when the file changed since the resource version
+	WriteErrorConflict
+	// WriteErrorPrecondition is returned when the storage can't be written to, when the file of the object
+	// has other objects, or when other objects depend on the object to delete
+	WriteErrorPrecondition
+)
+
+// WriteError is returned by ApplyObject and DeleteObject when the change is refused
+type WriteError struct {
+	Reason  WriteErrorReason
+	Message string
+}
+
+func (e *WriteError) Error() string {
+	return e.Message
+}
+
+// ObjectLocation is the file of an object in the storage, and the version of the file
+type ObjectLocation struct {
+	Filename        string
+	ResourceVersion string
+}
+
+// ApplyResult is the result of ApplyObject
+type ApplyResult struct {
+	Object pkgApi.Object
+	// ObjectLocation has the version of the file after the write, or the current version for a dry run
+	ObjectLocation
+	Created bool
+	// Pending contains the missing dependencies of the object, it's imported when they exist
+	Pending []ObjectDependency
+}
+
+// GetObjectLocation returns the file of an object and the version of the file. The version is empty when the storage doesn't have versions
+func (x *XDS) GetObjectLocation(kind, name string) (ObjectLocation, error) {
+	filename, _ := x.findObjectFile(kind, name)
+	if filename == "" {
+		return ObjectLocation{}, fmt.Errorf("object %s/%s not found", kind, name)
+	}
+	location := ObjectLocation{Filename: filename}
+	if w, ok := storage.GetWritableStorage(x.s); ok {
+		version, err := w.GetObjectVersion(filename)
+		if err != nil {
+			return location, fmt.Errorf("Couldn't get version of %s: %s", filename, err)
+		}
+		location.ResourceVersion = version
+	}
+	return location, nil
+}
+
+// ApplyObject validates an object and writes it to the storage, in the file of the object, or in a new file
+// when the object doesn't exist yet. The written file is imported like a storage notification. The write
+// fails when the resource version is set and the file changed
+func (x *XDS) ApplyObject(format pkgApi.Format, document []byte, resourceVersion string, dryRun bool) (ApplyResult, error) {
+	x.writeMu.Lock()
+	defer x.writeMu.Unlock()
+
+	w, ok := storage.GetWritableStorage(x.s)
+	if !ok {
+		return ApplyResult{}, &WriteError{Reason: WriteErrorPrecondition, Message: "The storage can't be written to"}
+	}
+	extension := ".yaml"
+	if format == pkgApi.FormatJSON {
+		extension = ".json"
+	}
+	objects, err := util.ParseObjects("object"+extension, document)
+	if err != nil {
+		return ApplyResult{}, &WriteError{Reason: WriteErrorInvalid, Message: err.Error()}
+	}
+	if len(objects) != 1 {
+		return ApplyResult{}, &WriteError{Reason: WriteErrorInvalid, Message: fmt.Sprintf("Expected 1 object, got %d", len(objects))}
+	}
+	result := ApplyResult{Object: objects[0]}
+
+	filename, otherObjects := x.findObjectFile(result.Object.Kind, result.Object.Metadata.Name)
+	switch {
+	case filename == "":
+		filename = w.ObjectFilename(getObjectBasename(result.Object) + extension)
+		result.Created = true
+	case otherObjects > 0:
+		return result, &WriteError{Reason: WriteErrorPrecondition, Message: fmt.Sprintf("%s %s is in %s together with other objects", result.Object.Kind, result.Object.Metadata.Name, filename)}
+	case strings.HasSuffix(filename, ".json") && format != pkgApi.FormatJSON:
+		return result, &WriteError{Reason: WriteErrorInvalid, Message: fmt.Sprintf("%s %s is in %s, the object must be in json format", result.Object.Kind, result.Object.Metadata.Name, filename)}
+	}
+	result.Filename = filename
+
+	version, err := w.GetObjectVersion(filename)
+	if err != nil {
+		return result, fmt.Errorf("Couldn't get version of %s: %s", filename, err)
+	}
+	if result.Created && version != "" {
+		// the file wasn't imported, e.g. because it's invalid
+		return result, &WriteError{Reason: WriteErrorPrecondition, Message: fmt.Sprintf("%s already exists", filename)}
+	}
+	if resourceVersion != "" && resourceVersion != version {
+		return result, &WriteError{Reason: WriteErrorConflict, Message: fmt.Sprintf("%s has version %s, not %s", filename, version, resourceVersion)}
+	}
+
+	files := x.getObjectFiles()
+	files[filename] = objects
+	render, err := x.validateObjectFiles(files)
+	if err != nil {
+		return result, err
+	}
+	for _, pending := range render.Pending {
+		if pending.Kind == result.Object.Kind && pending.Name == result.Object.Metadata.Name {
+			result.Pending = pending.Dependencies
+		}
+	}
+
+	if dryRun {
+		result.ResourceVersion = version
+		return result, nil
+	}
+	result.ResourceVersion, err = w.PutObject(filename, document, version)
+	if err != nil {
+		if err == storage.ErrVersionConflict {
+			return result, &WriteError{Reason: WriteErrorConflict, Message: err.Error()}
+		}
+		return result, fmt.Errorf("Couldn't write %s: %s", filename, err)
+	}
+	logger.Infof("Applied %s %s (file: %s, version: %s)", result.Object.Kind, result.Object.Metadata.Name, filename, result.ResourceVersion)
+
+	err = x.ReceiveNotification([]*notification.NotificationRequest_NotificationItem{
+		{Filename: filename, EventName: util.EventObjectCreated},
+	})
+	if err != nil {
+		return result, fmt.Errorf("Written %s, but couldn't import it: %s", filename, err)
+	}
+	return result, nil
+}
+
+// DeleteObject deletes the file of an object from the storage, when no other objects depend on the object.
+// The deleted file is removed like a storage notification. The delete fails when the resource version is set and the file changed
+func (x *XDS) DeleteObject(kind, name, resourceVersion string, dryRun bool) (ObjectLocation, error) {
+	x.writeMu.Lock()
+	defer x.writeMu.Unlock()
+
+	w, ok := storage.GetWritableStorage(x.s)
+	if !ok {
+		return ObjectLocation{}, &WriteError{Reason: WriteErrorPrecondition, Message: "The storage can't be written to"}
+	}
+	filename, otherObjects := x.findObjectFile(kind, name)
+	if filename == "" {
+		return ObjectLocation{}, &WriteError{Reason: WriteErrorNotFound, Message: fmt.Sprintf("object %s/%s not found", kind, name)}
+	}
+	location := ObjectLocation{Filename: filename}
+	if otherObjects > 0 {
+		return location, &WriteError{Reason: WriteErrorPrecondition, Message: fmt.Sprintf("%s %s is in %s together with other objects", kind, name, filename)}
+	}
+	if dependent := x.getDependentObject(kind, name); dependent != nil {
+		return location, &WriteError{Reason: WriteErrorPrecondition, Message: fmt.Sprintf("%s %s depends on %s %s", dependent.Kind, dependent.Metadata.Name, kind, name)}
+	}
+
+	version, err := w.GetObjectVersion(filename)
+	if err != nil {
+		return location, fmt.Errorf("Couldn't get version of %s: %s", filename, err)
+	}
+	location.ResourceVersion = version
+	if resourceVersion != "" && resourceVersion != version {
+		return location, &WriteError{Reason: WriteErrorConflict, Message: fmt.Sprintf("%s has version %s, not %s", filename, version, resourceVersion)}
+	}
+
+	files := x.getObjectFiles()
+	delete(files, filename)
+	if _, err := x.validateObjectFiles(files); err != nil {
+		return location, err
+	}
+
+	if dryRun {
+		return location, nil
+	}
+	err = w.DeleteObject(filename, version)
+	if err != nil {
+		if err == storage.ErrVersionConflict {
+			return location, &WriteError{Reason: WriteErrorConflict, Message: err.Error()}
+		}
+		return location, fmt.Errorf("Couldn't delete %s: %s", filename, err)
+	}
+	logger.Infof("Deleted %s %s (file: %s)", kind, name, filename)
+
+	err = x.ReceiveNotification([]*notification.NotificationRequest_NotificationItem{
+		{Filename: filename, EventName: util.EventObjectRemoved},
+	})
+	if err != nil {
+		return location, fmt.Errorf("Deleted %s, but couldn't remove the objects: %s", filename, err)
+	}
+	return location, nil
+}
+
+// getDependentObject returns an object that depends on the object, or nil when no object depends on it
+func (x *XDS) getDependentObject(kind, name string) *pkgApi.Object {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for _, object := range x.objects {
+		for _, dependency := range pkgApi.GetDependencies(object) {
+			if dependency.Kind == kind && dependency.Name == name {
+				return &object
+			}
+		}
+	}
+	return nil
+}
+
+// findObjectFile returns the file of an object, and the number of other objects in the file
+func (x *XDS) findObjectFile(kind, name string) (string, int) {
+	// the storage cache is changed by ReceiveNotification
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for _, filename := range x.s.ListCachedObjectFilenames() {
+		objects, err := x.s.GetCachedObjectName(filename)
+		if err != nil {
+			continue
+		}
+		for _, object := range objects {
+			if object.Kind == kind && object.Metadata.Name == name {
+				return filename, len(objects) - 1
+			}
+		}
+	}
+	return "", 0
+}
+
+// getObjectFiles returns the objects per file, as they were imported from the storage
+func (x *XDS) getObjectFiles() map[string][]pkgApi.Object {
+	files := make(map[string][]pkgApi.Object)
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for _, filename := range x.s.ListCachedObjectFilenames() {
+		objects, err := x.s.GetCachedObjectName(filename)
+		if err != nil {
+			continue
+		}
+		files[filename] = x.objectToValue(objects)
+	}
+	return files
+}
+
+// validateObjectFiles renders the configuration of the files, with the secrets of the storage
+func (x *XDS) validateObjectFiles(files map[string][]pkgApi.Object) (*RenderResult, error) {
+	render, err := Render(storage.NewCombinedStorage(newMemoryObjectStore(files), x.s))
+	if err != nil {
+		return nil, &WriteError{Reason: WriteErrorInvalid, Message: fmt.Sprintf("Couldn't import the objects: %s", err)}
+	}
+	if len(render.Errors) > 0 {
+		return render, &WriteError{Reason: WriteErrorInvalid, Message: strings.Join(render.Errors, ", ")}
+	}
+	return render, nil
+}
+
+// getObjectBasename returns the filename for a new object, without extension
+func getObjectBasename(object pkgApi.Object) string {
+	return invalidFilenameChars.ReplaceAllString(strings.ToLower(object.Kind)+"-"+object.Metadata.Name, "-")
+}
+
+// memoryObjectStore keeps the object files in memory, to render a configuration before it's written to the storage
+type memoryObjectStore struct {
+	*util.ObjectCache
+	files map[string][]pkgApi.Object
+}
+
+func newMemoryObjectStore(files map[string][]pkgApi.Object) *memoryObjectStore {
+	return &memoryObjectStore{ObjectCache: util.NewObjectCache(), files: files}
+}
+
+func (m *memoryObjectStore) ListObjects() ([]pkgApi.Object, error) {
+	var objects []pkgApi.Object
+	filenames, _ := m.ListObjectFilenames()
+	for _, filename := range filenames {
+		fileObjects, err := m.GetObject(filename)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, fileObjects...)
+	}
+	return objects, nil
+}
+
+func (m *memoryObjectStore) ListObjectFilenames() ([]string, error) {
+	filenames := make([]string, 0, len(m.files))
+	for filename := range m.files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+func (m *memoryObjectStore) GetObject(filename string) ([]pkgApi.Object, error) {
+	objects, ok := m.files[filename]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", filename)
+	}
+	m.SetCachedObjects(filename, objects)
+	return objects, nil
+}
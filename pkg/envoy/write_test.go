@@ -0,0 +1,224 @@
+package envoy
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/storage"
+	localStorage "github.com/in4it/roxprox/pkg/storage/local"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const writeTestRule = `api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: write-rule
+spec:
+  conditions:
+    - hostname: write.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+`
+
+const writeTestJwtRule = `api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: write-jwt-rule
+spec:
+  auth:
+    jwtProvider: write-jwt
+  conditions:
+    - hostname: write-jwt.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+`
+
+const writeTestJwtProvider = `{"api": "proxy.in4it.io/v1", "kind": "jwtProvider", "metadata": {"name": "write-jwt"},
+ "spec": {"remoteJwks": "https://example.com/.well-known/jwks.json", "issuer": "https://example.com"}}`
+
+func getWriteErrorReason(err error) (WriteErrorReason, bool) {
+	writeErr, ok := err.(*WriteError)
+	if !ok {
+		return 0, false
+	}
+	return writeErr.Reason, true
+}
+
+func TestApplyObjectDryRunMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "write")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	x := NewXDS(s, "", "")
+	if err := x.ImportObjects(); err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+	// the snapshot version of the server is ahead of the version of the work queue used to validate the dry run
+	for i := 0; i < 3; i++ {
+		x.workQueue.updateXds()
+	}
+	version := testutil.ToFloat64(snapshotVersionMetric)
+	finished := testutil.ToFloat64(workQueueItemsMetric.WithLabelValues("createCluster", "finished"))
+
+	if _, err := x.ApplyObject(pkgApi.FormatYAML, []byte(writeTestRule), "", true); err != nil {
+		t.Errorf("ApplyObject error: %s", err)
+		return
+	}
+	if got := testutil.ToFloat64(snapshotVersionMetric); got != version {
+		t.Errorf("Dry run changed the snapshot version metric: %v, expected %v", got, version)
+	}
+	if got := testutil.ToFloat64(workQueueItemsMetric.WithLabelValues("createCluster", "finished")); got != finished {
+		t.Errorf("Dry run changed the work queue items metric: %v, expected %v", got, finished)
+	}
+}
+
+func TestApplyAndDeleteObject(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "write")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	contents, err := ioutil.ReadFile("testdata/test-multiplerules.yaml")
+	if err != nil {
+		t.Errorf("ReadFile error: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(dir+"/test-multiplerules.yaml", contents, 0644); err != nil {
+		t.Errorf("WriteFile error: %s", err)
+		return
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	x := NewXDS(s, "", "")
+	if err := x.ImportObjects(); err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+
+	// dry run
+	result, err := x.ApplyObject(pkgApi.FormatYAML, []byte(writeTestRule), "", true)
+	if err != nil {
+		t.Errorf("ApplyObject error: %s", err)
+		return
+	}
+	if !result.Created || result.Filename != "rule-write-rule.yaml" || result.ResourceVersion != "" {
+		t.Errorf("Unexpected dry run result: %+v", result)
+	}
+	if _, err := os.Stat(dir + "/rule-write-rule.yaml"); !os.IsNotExist(err) {
+		t.Errorf("Dry run wrote the file (error: %v)", err)
+	}
+
+	// create
+	result, err = x.ApplyObject(pkgApi.FormatYAML, []byte(writeTestRule), "", false)
+	if err != nil {
+		t.Errorf("ApplyObject error: %s", err)
+		return
+	}
+	if !result.Created || result.ResourceVersion == "" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if _, err := x.GetObject("rule", "write-rule"); err != nil {
+		t.Errorf("Applied object isn't imported: %s", err)
+	}
+	location, err := x.GetObjectLocation("rule", "write-rule")
+	if err != nil || location != result.ObjectLocation {
+		t.Errorf("Unexpected location: %+v (error: %v)", location, err)
+	}
+
+	// update with a stale version
+	if _, err := x.ApplyObject(pkgApi.FormatYAML, []byte(writeTestRule), "stale", false); err == nil {
+		t.Errorf("Expected version conflict")
+	} else if reason, _ := getWriteErrorReason(err); reason != WriteErrorConflict {
+		t.Errorf("Expected version conflict, got: %s", err)
+	}
+	// update with the current version
+	updated, err := x.ApplyObject(pkgApi.FormatYAML, []byte(writeTestRule+"# updated\n"), result.ResourceVersion, false)
+	if err != nil {
+		t.Errorf("ApplyObject error: %s", err)
+		return
+	}
+	if updated.Created || updated.ResourceVersion == result.ResourceVersion {
+		t.Errorf("Unexpected update result: %+v", updated)
+	}
+
+	// invalid objects
+	for _, document := range []string{"kind: unknown", writeTestRule + "---\n" + writeTestRule} {
+		if _, err := x.ApplyObject(pkgApi.FormatYAML, []byte(document), "", false); err == nil {
+			t.Errorf("Expected error for invalid document: %s", document)
+		} else if reason, ok := getWriteErrorReason(err); !ok || reason != WriteErrorInvalid {
+			t.Errorf("Expected invalid object error, got: %s", err)
+		}
+	}
+	// the object is in a file with other objects
+	if _, err := x.ApplyObject(pkgApi.FormatYAML, []byte(strings.Split(string(contents), "\n---\n")[1]), "", false); err == nil {
+		t.Errorf("Expected error for a file with multiple objects")
+	} else if reason, _ := getWriteErrorReason(err); reason != WriteErrorPrecondition {
+		t.Errorf("Expected precondition error, got: %s", err)
+	}
+
+	// an object with a missing dependency is written and pending
+	result, err = x.ApplyObject(pkgApi.FormatYAML, []byte(writeTestJwtRule), "", false)
+	if err != nil {
+		t.Errorf("ApplyObject error: %s", err)
+		return
+	}
+	if len(result.Pending) != 1 || result.Pending[0].Type != "jwtProvider" || result.Pending[0].Name != "write-jwt" {
+		t.Errorf("Unexpected pending dependencies: %+v", result.Pending)
+	}
+	result, err = x.ApplyObject(pkgApi.FormatJSON, []byte(writeTestJwtProvider), "", false)
+	if err != nil {
+		t.Errorf("ApplyObject error: %s", err)
+		return
+	}
+	if result.Filename != "jwtprovider-write-jwt.json" || len(x.ListPendingObjects()) != 0 {
+		t.Errorf("Unexpected result: %+v (pending: %+v)", result, x.ListPendingObjects())
+	}
+
+	// delete
+	if _, err := x.DeleteObject("jwtProvider", "write-jwt", "", false); err == nil {
+		t.Errorf("Expected error for object with dependents")
+	} else if reason, _ := getWriteErrorReason(err); reason != WriteErrorPrecondition {
+		t.Errorf("Expected precondition error, got: %s", err)
+	}
+	if _, err := x.DeleteObject("rule", "doesnotexist", "", false); err == nil {
+		t.Errorf("Expected not found error")
+	} else if reason, _ := getWriteErrorReason(err); reason != WriteErrorNotFound {
+		t.Errorf("Expected not found error, got: %s", err)
+	}
+	if _, err := x.DeleteObject("rule", "write-rule", "stale", false); err == nil {
+		t.Errorf("Expected version conflict")
+	}
+	deleted, err := x.DeleteObject("rule", "write-rule", updated.ResourceVersion, false)
+	if err != nil {
+		t.Errorf("DeleteObject error: %s", err)
+		return
+	}
+	if deleted.Filename != "rule-write-rule.yaml" {
+		t.Errorf("Unexpected deleted file: %+v", deleted)
+	}
+	if _, err := os.Stat(dir + "/rule-write-rule.yaml"); !os.IsNotExist(err) {
+		t.Errorf("File wasn't deleted (error: %v)", err)
+	}
+	if _, err := x.GetObject("rule", "write-rule"); err == nil {
+		t.Errorf("Deleted object is still imported")
+	}
+}
@@ -2,13 +2,15 @@ package envoy
 
 import (
 	"context"
-	"crypto/x509"
-	"encoding/pem"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	clusterservice "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -19,9 +21,11 @@ import (
 	"github.com/google/go-cmp/cmp"
 	pkgApi "github.com/in4it/roxprox/pkg/api"
 	storage "github.com/in4it/roxprox/pkg/storage"
+	"github.com/in4it/roxprox/pkg/storage/util"
 	"github.com/in4it/roxprox/proto/notification"
 	"github.com/juju/loggo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 var logger = loggo.GetLogger("xds")
@@ -30,12 +34,32 @@ type XDS struct {
 	s              storage.Storage
 	objects        []pkgApi.Object
 	objectsPending []pkgApi.Object
+	objectsStatus  []pkgApi.Object // imported objects of which the status is reported when the work queue items are submitted
+	mu             sync.RWMutex    // protects objects, objectsPending and objectsStatus
 	workQueue      *WorkQueue
-	acmeContact    string
+	renewalQueue   *RenewalQueue
+	acmeAccount    AcmeAccount
+	imported       int32
+	writeMu        sync.Mutex // serializes the writes of ApplyObject and DeleteObject
 }
 
 func NewXDS(s storage.Storage, acmeContact, port string) *XDS {
-	workQueue, err := NewWorkQueue(s, acmeContact)
+	return NewXDSWithAcmeAccount(s, AcmeAccount{Contact: acmeContact}, port)
+}
+
+func NewXDSWithAcmeAccount(s storage.Storage, acmeAccount AcmeAccount, port string) *XDS {
+	return NewXDSWithConfig(s, XDSConfig{AcmeAccount: acmeAccount, Port: port})
+}
+
+// XDSConfig configures the control plane and the xds grpc server
+type XDSConfig struct {
+	AcmeAccount AcmeAccount
+	Port        string      // port of the xds grpc server, no server is started when empty
+	TLSConfig   *tls.Config // serve with TLS when set, client certificates are verified when the config has client CAs
+}
+
+func NewXDSWithConfig(s storage.Storage, config XDSConfig) *XDS {
+	workQueue, err := NewWorkQueue(s, config.AcmeAccount)
 	if err != nil {
 		logger.Debugf("Couldn't initialize workqueue")
 		panic(err)
@@ -43,13 +67,17 @@ func NewXDS(s storage.Storage, acmeContact, port string) *XDS {
 	x := &XDS{
 		s:           s,
 		workQueue:   workQueue,
-		acmeContact: acmeContact,
+		acmeAccount: config.AcmeAccount,
 	}
 
 	server := xds.NewServer(context.Background(), x.workQueue.InitCache(), x.workQueue.InitCallback())
-	if port != "" {
-		grpcServer := grpc.NewServer()
-		lis, _ := net.Listen("tcp", ":"+port)
+	if config.Port != "" {
+		var options []grpc.ServerOption
+		if config.TLSConfig != nil {
+			options = append(options, grpc.Creds(credentials.NewTLS(config.TLSConfig)))
+		}
+		grpcServer := grpc.NewServer(options...)
+		lis, _ := net.Listen("tcp", ":"+config.Port)
 
 		discovery.RegisterAggregatedDiscoveryServiceServer(grpcServer, server)
 		endpointservice.RegisterEndpointDiscoveryServiceServer(grpcServer, server)
@@ -71,11 +99,12 @@ func (x *XDS) WaitForFirstEnvoy() {
 }
 func (x *XDS) StartRenewalQueue() error {
 	// run renewals
-	renewals, err := NewRenewalQueue(x.s, x.acmeContact, x.workQueue)
+	renewals, err := NewRenewalQueue(x.s, x.acmeAccount, x.workQueue)
 	if err != nil {
 		return err
 	}
 	renewals.StartQueue() // run queue once every hour for renewals
+	x.renewalQueue = renewals
 
 	err = renewals.CheckRenewals() // check immediately for renewals
 	if err != nil {
@@ -84,7 +113,39 @@ func (x *XDS) StartRenewalQueue() error {
 	return nil
 }
 
+// RolloverAcmeAccountKey replaces the key of the ACME account with a newly generated key
+func (x *XDS) RolloverAcmeAccountKey() error {
+	if x.workQueue.cert == nil {
+		return fmt.Errorf("Cert feature is disabled")
+	}
+	return x.workQueue.cert.rolloverAccountKey()
+}
+
+// UpdateAcmeContact updates the contact address of the ACME account
+func (x *XDS) UpdateAcmeContact(contact string) error {
+	if x.workQueue.cert == nil {
+		return fmt.Errorf("Cert feature is disabled")
+	}
+	err := x.workQueue.cert.updateContact(contact)
+	if err != nil {
+		return err
+	}
+	x.acmeAccount.Contact = contact
+	return nil
+}
+
+// DeactivateAcmeAccount permanently deactivates the ACME account
+func (x *XDS) DeactivateAcmeAccount() error {
+	if x.workQueue.cert == nil {
+		return fmt.Errorf("Cert feature is disabled")
+	}
+	return x.workQueue.cert.deactivateAccount()
+}
+
 func (x *XDS) ImportObjects() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
 	var (
 		workQueueItems []WorkQueueItem
 		err            error
@@ -94,48 +155,34 @@ func (x *XDS) ImportObjects() error {
 	if err != nil {
 		return err
 	}
+	x.objectsStatus = nil
 
-	// first read tracing objects
-	for _, object := range objects {
-		if object.Kind == "tracing" {
-			x.objects = append(x.objects, object)
-			newitems, err := x.ImportObject(object)
-			if err != nil {
-				return err
-			}
-			workQueueItems = append(workQueueItems, newitems...)
-		}
-	}
-
-	// then read config objects
+	// import the objects in the import order of their kind (tracing first, rules last)
+	sort.SliceStable(objects, func(i, j int) bool {
+		return pkgApi.GetImportOrder(objects[i]) < pkgApi.GetImportOrder(objects[j])
+	})
 	for _, object := range objects {
-		if object.Kind != "rule" && object.Kind != "tracing" {
-			x.objects = append(x.objects, object)
-			newitems, err := x.ImportObject(object)
-			if err != nil {
-				return err
-			}
-			workQueueItems = append(workQueueItems, newitems...)
+		x.objects = append(x.objects, object)
+		unresolvedDependencies := x.getObjectUnresolvedDependencies(object)
+		if len(unresolvedDependencies) != 0 {
+			logger.Debugf("Unresolved dependency for %s (moving to pending queue)", object.Metadata.Name)
+			x.objectsPending = append(x.objectsPending, object)
+			x.setObjectStatus(object, storage.ObjectStatePendingDependency, fmt.Sprintf("waiting for %s %s", unresolvedDependencies[0].Type, unresolvedDependencies[0].Name))
+			continue
 		}
-	}
-
-	// read rules
-	for _, object := range objects {
-		if object.Kind == "rule" {
-			x.objects = append(x.objects, object)
-			newitems, err := x.ImportObject(object)
-			if err != nil {
-				return err
-			}
-			workQueueItems = append(workQueueItems, newitems...)
+		newitems, err := x.importObjectWithStatus(object)
+		if err != nil {
+			return err
 		}
+		workQueueItems = append(workQueueItems, newitems...)
 	}
 
-	_, err = x.workQueue.Submit(workQueueItems)
+	err = x.submitWithStatus(workQueueItems)
 	if err != nil {
 		return err
 	}
 
+	atomic.StoreInt32(&x.imported, 1)
 	return nil
 }
 
@@ -202,67 +249,50 @@ func (x *XDS) RemoveRule(rule pkgApi.Rule, ruleStillPresent bool) ([]WorkQueueIt
 	return workQueueItems, nil
 }
 func (x *XDS) ImportObject(object pkgApi.Object) ([]WorkQueueItem, error) {
-	switch object.Kind {
-	case "rule":
-		rule := object.Data.(pkgApi.Rule)
-		// add new rules
-		items, err := x.ImportRule(rule)
-		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
-		}
-		return items, nil
-	case "jwtProvider":
-		jwtProvider := object.Data.(pkgApi.JwtProvider)
-		items, err := x.importJwtProvider(jwtProvider)
-		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
-		}
-		return items, nil
-	case "authzFilter":
-		authzFilter := object.Data.(pkgApi.AuthzFilter)
-		items, err := x.importAuthzFilter(authzFilter)
-		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
-		}
-		return items, nil
-	case "tracing":
-		tracing := object.Data.(pkgApi.Tracing)
-		items, err := x.importTracing(tracing)
-		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
-		}
-		return items, nil
-	case "compression":
-		compression := object.Data.(pkgApi.Compression)
-		items, err := x.importCompression(compression)
-		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
-		}
-		return items, nil
-	case "accessLogServer":
-		accessLogServer := object.Data.(pkgApi.AccessLogServer)
-		items, err := x.importAccessLogServer(accessLogServer)
-		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
-		}
-		return items, nil
-	case "rateLimit":
-		rateLimit := object.Data.(pkgApi.RateLimit)
-		items, err := x.importRateLimit(rateLimit)
-		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
-		}
-		return items, nil
-	case "mTLS":
-		mTLS := object.Data.(pkgApi.MTLS)
-		items, err := x.importMTLS(mTLS)
+	importer, ok := importers[object.Kind]
+	if !ok {
+		return []WorkQueueItem{}, fmt.Errorf("Couldn't import %s: unknown kind %s", object.Metadata.Name, object.Kind)
+	}
+	items, err := importer(x, object)
+	if err != nil {
+		return []WorkQueueItem{}, fmt.Errorf("Couldn't import %s %s: %s", object.Kind, object.Metadata.Name, err)
+	}
+	return items, nil
+}
+
+// importObjectWithStatus imports the object and reports an import error to the storage. The imported state
+// is reported by submitWithStatus, once the work queue items of the object are submitted
+func (x *XDS) importObjectWithStatus(object pkgApi.Object) ([]WorkQueueItem, error) {
+	items, err := x.ImportObject(object)
+	if err != nil {
+		x.setObjectStatus(object, storage.ObjectStateError, err.Error())
+		return items, err
+	}
+	x.objectsStatus = append(x.objectsStatus, object)
+	return items, nil
+}
+
+// submitWithStatus submits the work queue items and reports the result to the storage for the imported objects
+func (x *XDS) submitWithStatus(workQueueItems []WorkQueueItem) error {
+	var err error
+	if len(workQueueItems) > 0 {
+		_, err = x.workQueue.Submit(workQueueItems)
+	}
+	for _, object := range x.objectsStatus {
 		if err != nil {
-			return []WorkQueueItem{}, fmt.Errorf("Couldn't import new rule: %s", err)
+			x.setObjectStatus(object, storage.ObjectStateError, err.Error())
+		} else {
+			x.setObjectStatus(object, storage.ObjectStateImported, "")
 		}
-		return items, nil
 	}
+	x.objectsStatus = nil
+	return err
+}
 
-	return []WorkQueueItem{}, nil
+func (x *XDS) setObjectStatus(object pkgApi.Object, state, message string) {
+	if statusStorage, ok := x.s.(storage.StatusStorage); ok {
+		statusStorage.SetObjectStatus(object.Kind, object.Metadata.Name, state, message)
+	}
 }
 
 func (x *XDS) importAuthzFilter(authzFilter pkgApi.AuthzFilter) ([]WorkQueueItem, error) {
@@ -716,7 +746,10 @@ func (x *XDS) CreateCertsForRules() error {
 	var workQueueItems []WorkQueueItem
 	// tls certs
 
-	for _, object := range x.objects {
+	x.mu.RLock()
+	objects := append([]pkgApi.Object{}, x.objects...)
+	x.mu.RUnlock()
+	for _, object := range objects {
 		if object.Kind == "rule" {
 			rule := object.Data.(pkgApi.Rule)
 			logger.Debugf("Looking for cert for %s (cert=%s)", rule.Metadata.Name, rule.Spec.Certificate)
@@ -749,14 +782,11 @@ func (x *XDS) CreateCertsForRules() error {
 }
 
 func (x *XDS) verifyCert(name, certPEM string, domains []string) error {
-	block, _ := pem.Decode([]byte(certPEM))
-	if block == nil {
-		return fmt.Errorf("failed to parse certificate PEM for %s", name)
-	}
-	cert, err := x509.ParseCertificate(block.Bytes)
+	cert, err := parseCertificate(name, certPEM)
 	if err != nil {
-		return fmt.Errorf("failed to parse certificate for (%s): %s", name, err.Error())
+		return err
 	}
+	recordCertificateExpiry(name, cert)
 
 	for _, domain := range domains {
 		if err := cert.VerifyHostname(domain); err != nil {
@@ -791,18 +821,27 @@ func (x *XDS) launchCreateCert(name string, domains []string) WorkQueueItem {
 
 //ReceiveNotification receives notification items and will process them
 func (x *XDS) ReceiveNotification(notifications []*notification.NotificationRequest_NotificationItem) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
 	var (
 		workQueueItems []WorkQueueItem
 	)
 
+	x.objectsStatus = nil
 	for _, v := range notifications {
-		if v.EventName == "ObjectCreated:Put" {
+		if v.EventName == util.EventObjectCreated {
 			newItems, err := x.putObject(v.Filename)
 			if err != nil {
 				return err
 			}
 			workQueueItems = append(workQueueItems, newItems...)
-		} else if v.EventName == "ObjectRemoved:Delete" {
+		} else if v.EventName == util.EventObjectRemoved {
+			if _, err := x.s.GetCachedObjectName(v.Filename); err != nil {
+				// already removed, e.g. when a batch of notifications is received again
+				logger.Debugf("Object %s not found in storage cache, nothing to delete", v.Filename)
+				continue
+			}
 			newItems, err := x.deleteObject(v.Filename)
 			if err != nil {
 				return err
@@ -811,16 +850,72 @@ func (x *XDS) ReceiveNotification(notifications []*notification.NotificationRequ
 		}
 	}
 
-	if len(workQueueItems) > 0 {
-		_, err := x.workQueue.Submit(workQueueItems)
+	err := x.submitWithStatus(workQueueItems)
+	if err != nil {
+		return fmt.Errorf("ReceiveFromQueue Error while Submitting WorkQueue: %s", err)
+	}
+	return nil
+}
+
+// Resync imports the objects in storage again and removes the objects of files that are no longer in storage.
+// It's used when notifications were missed. The files are imported one by one, a file that can't be imported
+// doesn't stop the import of the other files, and the snapshot is updated with the files that were imported
+func (x *XDS) Resync() error {
+	filenames, err := x.s.ListObjectFilenames()
+	if err != nil {
+		return fmt.Errorf("Couldn't list objects: %s", err)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var (
+		workQueueItems []WorkQueueItem
+		errors         []string
+	)
+
+	x.objectsStatus = nil
+	present := make(map[string]bool)
+	for _, filename := range filenames {
+		present[filename] = true
+	}
+	// removals first, the objects of a removed file can be in one of the other files now
+	for _, filename := range x.s.ListCachedObjectFilenames() {
+		if present[filename] {
+			continue
+		}
+		logger.Debugf("Resync: %s is not in storage anymore", filename)
+		newItems, err := x.deleteObject(filename)
+		if err != nil {
+			logger.Errorf("Resync: couldn't remove %s: %s", filename, err)
+			errors = append(errors, fmt.Sprintf("%s: %s", filename, err))
+			continue
+		}
+		workQueueItems = append(workQueueItems, newItems...)
+	}
+
+	logger.Infof("Resync: importing %d files", len(filenames))
+	for _, filename := range filenames {
+		newItems, err := x.putObject(filename)
 		if err != nil {
-			return fmt.Errorf("ReceiveFromQueue Error while Submitting WorkQueue: %s", err)
+			logger.Errorf("Resync: couldn't import %s: %s", filename, err)
+			errors = append(errors, fmt.Sprintf("%s: %s", filename, err))
+			continue
 		}
+		workQueueItems = append(workQueueItems, newItems...)
+	}
 
+	err = x.submitWithStatus(workQueueItems)
+	if err != nil {
+		errors = append(errors, fmt.Sprintf("Couldn't submit to the work queue: %s", err))
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf("Resync error: %s", strings.Join(errors, ", "))
 	}
 	return nil
 }
 
+// putObject imports the objects of a file. The caller holds the write lock (see ReceiveNotification)
 func (x *XDS) putObject(filename string) ([]WorkQueueItem, error) {
 	var workQueueItems []WorkQueueItem
 
@@ -849,7 +944,7 @@ func (x *XDS) putObject(filename string) ([]WorkQueueItem, error) {
 		unresolvedDependencies := x.getObjectUnresolvedDependencies(object)
 		if len(unresolvedDependencies) == 0 {
 			logger.Debugf("Dependency is now resolved for: %s", object.Metadata.Name)
-			newItems, err := x.ImportObject(object)
+			newItems, err := x.importObjectWithStatus(object)
 			if err != nil {
 				return workQueueItems, fmt.Errorf("Couldn't import new object: %s", err)
 			}
@@ -869,8 +964,9 @@ func (x *XDS) putObject(filename string) ([]WorkQueueItem, error) {
 		if len(unresolvedDependencies) != 0 {
 			logger.Debugf("Unresolved dependency for %s (moving to pending queue)", object.Metadata.Name)
 			x.objectsPending = append(x.objectsPending, object)
+			x.setObjectStatus(object, storage.ObjectStatePendingDependency, fmt.Sprintf("waiting for %s %s", unresolvedDependencies[0].Type, unresolvedDependencies[0].Name))
 		} else {
-			newItems, err := x.ImportObject(object)
+			newItems, err := x.importObjectWithStatus(object)
 			if err != nil {
 				return workQueueItems, fmt.Errorf("Couldn't import new object: %s", err)
 			}
@@ -879,6 +975,8 @@ func (x *XDS) putObject(filename string) ([]WorkQueueItem, error) {
 	}
 	return workQueueItems, nil
 }
+
+// deleteObject removes the objects of a file. The caller holds the write lock (see ReceiveNotification)
 func (x *XDS) deleteObject(filename string) ([]WorkQueueItem, error) {
 	objects, err := x.s.GetCachedObjectName(filename)
 	if err != nil {
@@ -971,16 +1069,13 @@ func (x *XDS) deleteObjects(objects []pkgApi.Object) {
 }
 func (x *XDS) getObjectUnresolvedDependencies(object pkgApi.Object) []ObjectDependency {
 	var dependencies []ObjectDependency
-	if object.Kind == "rule" {
-		rule := object.Data.(pkgApi.Rule)
-		if rule.Spec.Auth.JwtProvider != "" {
-			_, err := x.getObject("jwtProvider", rule.Spec.Auth.JwtProvider)
-			if err != nil {
-				dependencies = append(dependencies, ObjectDependency{
-					Type: "jwtProvider",
-					Name: rule.Spec.Auth.JwtProvider,
-				})
-			}
+	for _, dependency := range pkgApi.GetDependencies(object) {
+		_, err := x.getObject(dependency.Kind, dependency.Name)
+		if err != nil {
+			dependencies = append(dependencies, ObjectDependency{
+				Type: dependency.Kind,
+				Name: dependency.Name,
+			})
 		}
 	}
 	return dependencies
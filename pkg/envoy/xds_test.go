@@ -2,6 +2,8 @@ package envoy
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -1239,3 +1241,279 @@ func TestTracingObjectWithMTLS(t *testing.T) {
 		}
 	}
 }
+
+func TestResync(t *testing.T) {
+	dir, err := ioutil.TempDir("testdata", "resync")
+	if err != nil {
+		t.Errorf("TempDir error: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	for _, filename := range []string{"test2.yaml", "test3.yaml"} {
+		contents, err := ioutil.ReadFile("testdata/" + filename)
+		if err != nil {
+			t.Errorf("ReadFile error: %s", err)
+			return
+		}
+		err = ioutil.WriteFile(dir+"/"+filename, contents, 0644)
+		if err != nil {
+			t.Errorf("WriteFile error: %s", err)
+			return
+		}
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	x := NewXDS(s, "", "")
+	err = x.ImportObjects()
+	if err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+	if _, err := x.workQueue.cluster.findClusterByName(x.workQueue.cache.clusters, "test1-conflict"); err != nil {
+		t.Errorf("Cluster test1-conflict not found: %s", err)
+		return
+	}
+
+	// a delete notification was missed
+	err = os.Remove(dir + "/test3.yaml")
+	if err != nil {
+		t.Errorf("Remove error: %s", err)
+		return
+	}
+	err = x.Resync()
+	if err != nil {
+		t.Errorf("Resync error: %s", err)
+		return
+	}
+	if _, err := x.workQueue.cluster.findClusterByName(x.workQueue.cache.clusters, "test1-conflict"); err == nil {
+		t.Errorf("Cluster test1-conflict should be removed after resync")
+		return
+	}
+	if _, err := x.workQueue.cluster.findClusterByName(x.workQueue.cache.clusters, "test2"); err != nil {
+		t.Errorf("Cluster test2 not found after resync: %s", err)
+		return
+	}
+	if len(x.s.ListCachedObjectFilenames()) != 1 {
+		t.Errorf("Expected 1 cached file, got: %v", x.s.ListCachedObjectFilenames())
+	}
+}
+
+func TestResyncInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("testdata", "resync")
+	if err != nil {
+		t.Errorf("TempDir error: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	contents, err := ioutil.ReadFile("testdata/test2.yaml")
+	if err != nil {
+		t.Errorf("ReadFile error: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(dir+"/test2.yaml", contents, 0644); err != nil {
+		t.Errorf("WriteFile error: %s", err)
+		return
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	x := NewXDS(s, "", "")
+	if err := x.ImportObjects(); err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+	version := x.workQueue.getLatestSnapshotVersion()
+
+	// notifications were missed for a valid and an invalid file
+	contents, err = ioutil.ReadFile("testdata/test3.yaml")
+	if err != nil {
+		t.Errorf("ReadFile error: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(dir+"/test3.yaml", contents, 0644); err != nil {
+		t.Errorf("WriteFile error: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(dir+"/invalid.yaml", []byte("api: proxy.in4it.io/v1\nkind: rule\nmetadata: [\n"), 0644); err != nil {
+		t.Errorf("WriteFile error: %s", err)
+		return
+	}
+	err = x.Resync()
+	if err == nil || !strings.Contains(err.Error(), "invalid.yaml") {
+		t.Errorf("Expected resync error for invalid.yaml, got: %v", err)
+		return
+	}
+	if _, err := x.workQueue.cluster.findClusterByName(x.workQueue.cache.clusters, "test1-conflict"); err != nil {
+		t.Errorf("Cluster test1-conflict of the valid file not found after resync: %s", err)
+		return
+	}
+	if _, err := x.workQueue.cluster.findClusterByName(x.workQueue.cache.clusters, "test2"); err != nil {
+		t.Errorf("Cluster test2 not found after resync: %s", err)
+		return
+	}
+	if x.workQueue.getLatestSnapshotVersion() == version {
+		t.Errorf("Snapshot wasn't updated after resync")
+	}
+}
+
+// statusStorage records the import states that are reported to the storage
+type statusStorage struct {
+	storage.Storage
+	states map[string]string
+}
+
+func (s *statusStorage) SetObjectStatus(kind, name, state, message string) {
+	s.states[kind+"/"+name] = state
+}
+
+func TestReceiveNotificationStatus(t *testing.T) {
+	s, err := initStorage()
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	statuses := &statusStorage{Storage: s, states: make(map[string]string)}
+	x := NewXDS(statuses, "", "")
+
+	// the imported state is only reported when the work queue items are submitted
+	if _, err := x.putObject("test1.yaml"); err != nil {
+		t.Errorf("PutObject failed: %s", err)
+		return
+	}
+	if len(statuses.states) != 0 {
+		t.Errorf("Expected no status before the work queue items are submitted, got: %+v", statuses.states)
+		return
+	}
+	err = x.ReceiveNotification([]*notification.NotificationRequest_NotificationItem{
+		{Filename: "test1.yaml", EventName: "ObjectCreated:Put"},
+	})
+	if err != nil {
+		t.Errorf("ReceiveNotification error: %s", err)
+		return
+	}
+	if statuses.states["rule/test1"] != storage.ObjectStateImported {
+		t.Errorf("Expected rule test1 to be imported, got: %+v", statuses.states)
+	}
+	if len(x.objectsStatus) != 0 {
+		t.Errorf("Expected the reported objects to be cleared, got: %d", len(x.objectsStatus))
+	}
+}
+
+func TestImportObjectsPendingDependency(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "pending")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	copyFile := func(filename string) error {
+		contents, err := ioutil.ReadFile("testdata/" + filename)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dir+"/"+filename, contents, 0644)
+	}
+	for _, filename := range []string{"test1.yaml", "test-mtls-rule.yaml"} {
+		if err := copyFile(filename); err != nil {
+			t.Errorf("Couldn't copy %s: %s", filename, err)
+			return
+		}
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	statuses := &statusStorage{Storage: s, states: make(map[string]string)}
+	x := NewXDS(statuses, "", "")
+
+	// a rule with a missing mTLS object doesn't fail the startup, it waits for the mTLS object
+	err = x.ImportObjects()
+	if err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+	if len(x.objectsPending) != 1 || x.objectsPending[0].Metadata.Name != "mtls-testrule" {
+		t.Errorf("Unexpected pending objects: %+v", x.objectsPending)
+		return
+	}
+	if statuses.states["rule/mtls-testrule"] != storage.ObjectStatePendingDependency || statuses.states["rule/test1"] != storage.ObjectStateImported {
+		t.Errorf("Unexpected states: %+v", statuses.states)
+		return
+	}
+	if getListenerIndex(x.workQueue.cache.listeners, "l_mtls_test-mtls") != -1 {
+		t.Errorf("Expected no mTLS listener before the mTLS object is imported")
+		return
+	}
+
+	// the pending rule is imported with the mTLS object
+	if err := copyFile("test-mtls.yaml"); err != nil {
+		t.Errorf("Couldn't copy test-mtls.yaml: %s", err)
+		return
+	}
+	err = x.ReceiveNotification([]*notification.NotificationRequest_NotificationItem{
+		{Filename: "test-mtls.yaml", EventName: "ObjectCreated:Put"},
+	})
+	if err != nil {
+		t.Errorf("ReceiveNotification error: %s", err)
+		return
+	}
+	if len(x.objectsPending) != 0 {
+		t.Errorf("Unexpected pending objects: %+v", x.objectsPending)
+		return
+	}
+	if statuses.states["rule/mtls-testrule"] != storage.ObjectStateImported {
+		t.Errorf("Unexpected states: %+v", statuses.states)
+		return
+	}
+	if getListenerIndex(x.workQueue.cache.listeners, "l_mtls_test-mtls") == -1 {
+		t.Errorf("Expected mTLS listener after the mTLS object is imported")
+	}
+}
+
+// TestIntrospectionConcurrentNotifications reads the objects and the snapshot while notifications are imported, run with -race
+func TestIntrospectionConcurrentNotifications(t *testing.T) {
+	s, err := initStorage()
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	x := NewXDS(s, "", "")
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 20; i++ {
+			for _, eventName := range []string{"ObjectCreated:Put", "ObjectRemoved:Delete"} {
+				err := x.ReceiveNotification([]*notification.NotificationRequest_NotificationItem{
+					{Filename: "test1.yaml", EventName: eventName},
+					{Filename: "test-jwtprovider.yaml", EventName: eventName},
+				})
+				if err != nil {
+					done <- err
+					return
+				}
+			}
+		}
+		done <- nil
+	}()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("ReceiveNotification error: %s", err)
+			}
+			return
+		default:
+			x.ListObjects(nil)
+			x.GetObject("rule", "test1")
+			x.ListPendingObjects()
+			x.GetPendingObject("rule", "test1")
+			x.GetSnapshot()
+			x.Ready()
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package management
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	envoy "github.com/in4it/roxprox/pkg/envoy"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewAdminServer starts the admin http server with the health checks and the state of the control plane
+func NewAdminServer(xds *envoy.XDS, address string) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+	logger.Infof("Starting admin interface on %s", address)
+	go func() {
+		if err := http.Serve(lis, newAdminHandler(xds)); err != nil {
+			logger.Errorf("failed to serve: %v", err)
+		}
+	}()
+	return nil
+}
+
+func newAdminHandler(xds *envoy.XDS) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := xds.Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/config_dump", func(w http.ResponseWriter, r *http.Request) {
+		version, config, err := xds.GetSnapshot()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err := config.RedactPrivateKeys(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out, err := config.Marshal(pkgApi.FormatJSON)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var configDump struct {
+			Version   string            `json:"version"`
+			Clusters  []json.RawMessage `json:"clusters"`
+			Listeners []json.RawMessage `json:"listeners"`
+		}
+		if err := json.Unmarshal(out, &configDump); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		configDump.Version = version
+		writeJSON(w, configDump)
+	})
+	mux.HandleFunc("/objects", func(w http.ResponseWriter, r *http.Request) {
+		type object struct {
+			Kind    string          `json:"kind"`
+			Name    string          `json:"name"`
+			Pending bool            `json:"pending"`
+			Data    json.RawMessage `json:"data"`
+		}
+		pending := make(map[string]bool)
+		for _, pendingObject := range xds.ListPendingObjects() {
+			pending[pendingObject.Kind+"/"+pendingObject.Name] = true
+		}
+		objects := []object{}
+		for _, item := range xds.ListObjects(r.URL.Query()["kind"]) {
+			data, err := json.Marshal(envoy.RedactObject(item).Data)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			objects = append(objects, object{
+				Kind:    item.Kind,
+				Name:    item.Metadata.Name,
+				Pending: pending[item.Kind+"/"+item.Metadata.Name],
+				Data:    data,
+			})
+		}
+		writeJSON(w, objects)
+	})
+	mux.HandleFunc("/nodes", func(w http.ResponseWriter, r *http.Request) {
+		type node struct {
+			ID              string `json:"id"`
+			Cluster         string `json:"cluster"`
+			Streams         int    `json:"streams"`
+			SnapshotVersion string `json:"snapshotVersion"`
+		}
+		nodes := []node{}
+		for _, item := range xds.ListNodes() {
+			nodes = append(nodes, node{ID: item.ID, Cluster: item.Cluster, Streams: item.Streams, SnapshotVersion: item.SnapshotVersion})
+		}
+		writeJSON(w, nodes)
+	})
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		type certificate struct {
+			Name       string   `json:"name"`
+			Domains    []string `json:"domains,omitempty"`
+			NotBefore  string   `json:"notBefore,omitempty"`
+			NotAfter   string   `json:"notAfter,omitempty"`
+			RenewAfter string   `json:"renewAfter,omitempty"`
+			Error      string   `json:"error,omitempty"`
+		}
+		items, err := xds.ListCertificates()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		certificates := []certificate{}
+		for _, item := range items {
+			c := certificate{Name: item.Name, Domains: item.Domains, Error: item.Error}
+			if item.Error == "" {
+				c.NotBefore = item.NotBefore.UTC().Format(time.RFC3339)
+				c.NotAfter = item.NotAfter.UTC().Format(time.RFC3339)
+				c.RenewAfter = item.RenewAfter.UTC().Format(time.RFC3339)
+			}
+			certificates = append(certificates, c)
+		}
+		writeJSON(w, certificates)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(out, '\n'))
+}
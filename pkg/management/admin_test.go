@@ -0,0 +1,159 @@
+package management
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	envoy "github.com/in4it/roxprox/pkg/envoy"
+	storage "github.com/in4it/roxprox/pkg/storage"
+	localStorage "github.com/in4it/roxprox/pkg/storage/local"
+)
+
+func TestAdminHandler(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "admin")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(dir+"/objects.yaml", []byte(`api: proxy.in4it.io/v1
+kind: mTLS
+metadata:
+  name: test-mtls
+spec:
+  privateKey: secret-private-key
+  certificate: test-certificate
+  caCertificate: test-ca-certificate
+  port: 10002
+---
+api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: mtls-testrule
+spec:
+  listener:
+    mTLS: test-mtls
+  conditions:
+    - hostname: envoyproxy.example.com
+      prefix: /
+  actions:
+    - proxy:
+        hostname: envoyproxy.com
+        port: 443
+`), 0644)
+	if err != nil {
+		t.Errorf("WriteFile error: %s", err)
+		return
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	xds := envoy.NewXDS(s, "", "")
+	handler := newAdminHandler(xds)
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	if code, _ := get("/healthz"); code != http.StatusOK {
+		t.Errorf("Unexpected healthz status: %d", code)
+	}
+	if code, body := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready before the import, got: %d %s", code, body)
+	}
+	if err := xds.ImportObjects(); err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+	if code, body := get("/readyz"); code != http.StatusOK {
+		t.Errorf("Expected ready after the import, got: %d %s", code, body)
+	}
+
+	code, body := get("/config_dump")
+	if code != http.StatusOK {
+		t.Errorf("Unexpected config_dump status: %d %s", code, body)
+		return
+	}
+	var configDump struct {
+		Version   string            `json:"version"`
+		Clusters  []json.RawMessage `json:"clusters"`
+		Listeners []json.RawMessage `json:"listeners"`
+	}
+	if err := json.Unmarshal([]byte(body), &configDump); err != nil {
+		t.Errorf("Couldn't unmarshal config_dump: %s", err)
+		return
+	}
+	if configDump.Version == "" || len(configDump.Clusters) != 1 || len(configDump.Listeners) != 1 {
+		t.Errorf("Unexpected config_dump: %s", body)
+	}
+	if strings.Contains(body, "secret-private-key") || !strings.Contains(body, "[redacted]") || !strings.Contains(body, "test-certificate") {
+		t.Errorf("Private key is not redacted: %s", body)
+	}
+	// the snapshot that is sent to envoy still has the private key
+	_, config, err := xds.GetSnapshot()
+	if err != nil {
+		t.Errorf("GetSnapshot error: %s", err)
+		return
+	}
+	out, err := config.Marshal(pkgApi.FormatJSON)
+	if err != nil || !strings.Contains(string(out), "secret-private-key") {
+		t.Errorf("Private key is missing in the snapshot (error: %v)", err)
+	}
+
+	code, body = get("/objects?kind=mTLS")
+	if code != http.StatusOK || !strings.Contains(body, `"name": "test-mtls"`) || strings.Contains(body, "mtls-testrule") {
+		t.Errorf("Unexpected objects: %d %s", code, body)
+	}
+	if strings.Contains(body, "secret-private-key") {
+		t.Errorf("Private key is not redacted: %s", body)
+	}
+
+	if code, body := get("/nodes"); code != http.StatusOK || strings.TrimSpace(body) != "[]" {
+		t.Errorf("Unexpected nodes: %d %s", code, body)
+	}
+	code, body = get("/metrics")
+	if code != http.StatusOK || !strings.Contains(body, `roxprox_workqueue_items_total{action="createCluster",state="finished"}`) ||
+		!strings.Contains(body, "# TYPE roxprox_snapshot_build_duration_seconds histogram") {
+		t.Errorf("Unexpected metrics: %d %s", code, body)
+	}
+	// there are no certificates in the storage
+	if code, body := get("/certs"); code != http.StatusOK || strings.TrimSpace(body) != "[]" {
+		t.Errorf("Unexpected certs: %d %s", code, body)
+	}
+
+	// the handlers can be used while notifications are imported (run with -race)
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 10; i++ {
+			if err := xds.Resync(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Resync error: %s", err)
+			}
+			return
+		default:
+			for _, path := range []string{"/readyz", "/config_dump", "/objects"} {
+				if code, body := get(path); code != http.StatusOK {
+					t.Errorf("Unexpected %s status: %d %s", path, code, body)
+				}
+			}
+		}
+	}
+}
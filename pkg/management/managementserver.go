@@ -0,0 +1,230 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+
+	pkgApi "github.com/in4it/roxprox/pkg/api"
+	envoy "github.com/in4it/roxprox/pkg/envoy"
+	management "github.com/in4it/roxprox/proto/management"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ManagementServer answers questions about the state of the control plane: the objects, the snapshot, the nodes and the certificates
+type ManagementServer struct {
+	xds          *envoy.XDS
+	enableWrites bool
+}
+
+// ManagementServerConfig configures the management service
+type ManagementServerConfig struct {
+	// EnableWrites enables ApplyObject and DeleteObject, that change the objects in the storage
+	EnableWrites bool
+}
+
+func NewManagementServer(xds *envoy.XDS) *ManagementServer {
+	return NewManagementServerWithConfig(xds, ManagementServerConfig{})
+}
+
+func NewManagementServerWithConfig(xds *envoy.XDS, config ManagementServerConfig) *ManagementServer {
+	return &ManagementServer{
+		xds:          xds,
+		enableWrites: config.EnableWrites,
+	}
+}
+
+func (m *ManagementServer) ListObjects(ctx context.Context, in *management.ListObjectsRequest) (*management.ListObjectsReply, error) {
+	reply := &management.ListObjectsReply{}
+	for _, object := range m.xds.ListObjects(in.GetKinds()) {
+		item, err := newObject(object)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "%s", err)
+		}
+		reply.Objects = append(reply.Objects, item)
+	}
+	return reply, nil
+}
+
+func (m *ManagementServer) GetObject(ctx context.Context, in *management.GetObjectRequest) (*management.GetObjectReply, error) {
+	object, err := m.xds.GetObject(in.GetKind(), in.GetName())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "%s", err)
+	}
+	item, err := newObject(object)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+	location, err := m.xds.GetObjectLocation(in.GetKind(), in.GetName())
+	if err != nil {
+		logger.Debugf("Couldn't get location of %s %s: %s", in.GetKind(), in.GetName(), err)
+	}
+	item.Filename = location.Filename
+	item.ResourceVersion = location.ResourceVersion
+	return &management.GetObjectReply{Object: item}, nil
+}
+
+func (m *ManagementServer) ListPendingObjects(ctx context.Context, in *management.ListPendingObjectsRequest) (*management.ListPendingObjectsReply, error) {
+	reply := &management.ListPendingObjectsReply{}
+	for _, pendingObject := range m.xds.ListPendingObjects() {
+		object, err := m.xds.GetPendingObject(pendingObject.Kind, pendingObject.Name)
+		if err != nil {
+			// imported in the meantime
+			continue
+		}
+		item, err := newObject(object)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "%s", err)
+		}
+		pending := &management.PendingObject{Object: item}
+		for _, dependency := range pendingObject.Dependencies {
+			pending.MissingDependencies = append(pending.MissingDependencies, &management.PendingObject_Dependency{
+				Kind: dependency.Type,
+				Name: dependency.Name,
+			})
+		}
+		reply.PendingObjects = append(reply.PendingObjects, pending)
+	}
+	return reply, nil
+}
+
+func (m *ManagementServer) GetSnapshot(ctx context.Context, in *management.GetSnapshotRequest) (*management.GetSnapshotReply, error) {
+	version, config, err := m.xds.GetSnapshot()
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "%s", err)
+	}
+	resources, err := config.Marshal(pkgApi.FormatJSON)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Couldn't marshal snapshot: %s", err)
+	}
+	return &management.GetSnapshotReply{Version: version, Resources: resources}, nil
+}
+
+func (m *ManagementServer) ListNodes(ctx context.Context, in *management.ListNodesRequest) (*management.ListNodesReply, error) {
+	reply := &management.ListNodesReply{}
+	for _, node := range m.xds.ListNodes() {
+		reply.Nodes = append(reply.Nodes, &management.Node{
+			Id:              node.ID,
+			Cluster:         node.Cluster,
+			Streams:         int32(node.Streams),
+			SnapshotVersion: node.SnapshotVersion,
+		})
+	}
+	return reply, nil
+}
+
+func (m *ManagementServer) ListCertificates(ctx context.Context, in *management.ListCertificatesRequest) (*management.ListCertificatesReply, error) {
+	certificates, err := m.xds.ListCertificates()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+	reply := &management.ListCertificatesReply{}
+	for _, certificate := range certificates {
+		item := &management.Certificate{
+			Name:    certificate.Name,
+			Domains: certificate.Domains,
+			Error:   certificate.Error,
+		}
+		if certificate.Error == "" {
+			item.NotBefore = certificate.NotBefore.Unix()
+			item.NotAfter = certificate.NotAfter.Unix()
+			item.RenewAfter = certificate.RenewAfter.Unix()
+		}
+		reply.Certificates = append(reply.Certificates, item)
+	}
+	return reply, nil
+}
+
+func (m *ManagementServer) TriggerRenewal(ctx context.Context, in *management.TriggerRenewalRequest) (*management.TriggerReply, error) {
+	logger.Infof("Received renewal request")
+	err := m.xds.TriggerRenewal()
+	if err != nil {
+		logger.Errorf("Renewal error: %s", err)
+		return nil, status.Errorf(codes.FailedPrecondition, "%s", err)
+	}
+	return &management.TriggerReply{Result: true}, nil
+}
+
+func (m *ManagementServer) TriggerImport(ctx context.Context, in *management.TriggerImportRequest) (*management.TriggerReply, error) {
+	logger.Infof("Received import request")
+	err := m.xds.TriggerImport()
+	if err != nil {
+		logger.Errorf("Import error: %s", err)
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+	return &management.TriggerReply{Result: true}, nil
+}
+
+func (m *ManagementServer) ApplyObject(ctx context.Context, in *management.ApplyObjectRequest) (*management.ApplyObjectReply, error) {
+	if !m.enableWrites {
+		return nil, status.Errorf(codes.PermissionDenied, "Writes are disabled")
+	}
+	format := pkgApi.FormatYAML
+	switch in.GetFormat() {
+	case "", "yaml":
+	case "json":
+		format = pkgApi.FormatJSON
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown format %q, use yaml or json", in.GetFormat())
+	}
+	result, err := m.xds.ApplyObject(format, in.GetData(), in.GetResourceVersion(), in.GetDryRun())
+	if err != nil {
+		return nil, newWriteStatus(err)
+	}
+	item, err := newObject(result.Object)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+	item.Filename = result.Filename
+	item.ResourceVersion = result.ResourceVersion
+	reply := &management.ApplyObjectReply{Object: item, Created: result.Created}
+	for _, dependency := range result.Pending {
+		reply.MissingDependencies = append(reply.MissingDependencies, &management.PendingObject_Dependency{
+			Kind: dependency.Type,
+			Name: dependency.Name,
+		})
+	}
+	return reply, nil
+}
+
+func (m *ManagementServer) DeleteObject(ctx context.Context, in *management.DeleteObjectRequest) (*management.DeleteObjectReply, error) {
+	if !m.enableWrites {
+		return nil, status.Errorf(codes.PermissionDenied, "Writes are disabled")
+	}
+	location, err := m.xds.DeleteObject(in.GetKind(), in.GetName(), in.GetResourceVersion(), in.GetDryRun())
+	if err != nil {
+		return nil, newWriteStatus(err)
+	}
+	return &management.DeleteObjectReply{Filename: location.Filename}, nil
+}
+
+// newWriteStatus returns the grpc status of an ApplyObject or DeleteObject error
+func newWriteStatus(err error) error {
+	writeErr, ok := err.(*envoy.WriteError)
+	if !ok {
+		logger.Errorf("Write error: %s", err)
+		return status.Errorf(codes.Internal, "%s", err)
+	}
+	switch writeErr.Reason {
+	case envoy.WriteErrorInvalid:
+		return status.Errorf(codes.InvalidArgument, "%s", err)
+	case envoy.WriteErrorNotFound:
+		return status.Errorf(codes.NotFound, "%s", err)
+	case envoy.WriteErrorConflict:
+		return status.Errorf(codes.Aborted, "%s", err)
+	}
+	return status.Errorf(codes.FailedPrecondition, "%s", err)
+}
+
+// newObject returns the object with the data in json format, without private keys
+func newObject(object pkgApi.Object) (*management.Object, error) {
+	data, err := json.Marshal(envoy.RedactObject(object).Data)
+	if err != nil {
+		return nil, err
+	}
+	return &management.Object{
+		Kind: object.Kind,
+		Name: object.Metadata.Name,
+		Data: data,
+	}, nil
+}
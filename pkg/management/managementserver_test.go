@@ -0,0 +1,260 @@
+package management
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	envoy "github.com/in4it/roxprox/pkg/envoy"
+	storage "github.com/in4it/roxprox/pkg/storage"
+	localStorage "github.com/in4it/roxprox/pkg/storage/local"
+	management "github.com/in4it/roxprox/proto/management"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestManagementServer(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "management")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(dir+"/objects.yaml", []byte(`api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  conditions:
+    - hostname: test1.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+---
+api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test2
+spec:
+  auth:
+    jwtProvider: missing-provider
+  conditions:
+    - hostname: test2.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+`), 0644)
+	if err != nil {
+		t.Errorf("WriteFile error: %s", err)
+		return
+	}
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	cert, err := newTestCertificate("test1.example.com")
+	if err != nil {
+		t.Errorf("Couldn't create certificate: %s", err)
+		return
+	}
+	if err := s.WriteCert("test1", cert); err != nil {
+		t.Errorf("WriteCert error: %s", err)
+		return
+	}
+	if err := s.WriteCertBundle("test1", cert); err != nil {
+		t.Errorf("WriteCertBundle error: %s", err)
+		return
+	}
+
+	xds := envoy.NewXDS(s, "", "")
+	m := NewManagementServer(xds)
+	ctx := context.Background()
+
+	if _, err := m.GetSnapshot(ctx, &management.GetSnapshotRequest{}); status.Code(err) != codes.Unavailable {
+		t.Errorf("Expected unavailable error before the import, got: %v", err)
+	}
+	if err := xds.ImportObjects(); err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+
+	objects, err := m.ListObjects(ctx, &management.ListObjectsRequest{Kinds: []string{"rule"}})
+	if err != nil {
+		t.Errorf("ListObjects error: %s", err)
+		return
+	}
+	// pending objects are included
+	if len(objects.GetObjects()) != 2 || objects.GetObjects()[0].GetName() != "test1" {
+		t.Errorf("Unexpected objects: %+v", objects.GetObjects())
+	}
+	objects, err = m.ListObjects(ctx, &management.ListObjectsRequest{Kinds: []string{"jwtProvider"}})
+	if err != nil || len(objects.GetObjects()) != 0 {
+		t.Errorf("Unexpected objects: %+v (error: %v)", objects.GetObjects(), err)
+	}
+
+	object, err := m.GetObject(ctx, &management.GetObjectRequest{Kind: "rule", Name: "test2"})
+	if err != nil {
+		t.Errorf("GetObject error: %s", err)
+		return
+	}
+	if object.GetObject().GetKind() != "rule" || len(object.GetObject().GetData()) == 0 {
+		t.Errorf("Unexpected object: %+v", object.GetObject())
+	}
+	if _, err := m.GetObject(ctx, &management.GetObjectRequest{Kind: "rule", Name: "test3"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	pending, err := m.ListPendingObjects(ctx, &management.ListPendingObjectsRequest{})
+	if err != nil {
+		t.Errorf("ListPendingObjects error: %s", err)
+		return
+	}
+	if len(pending.GetPendingObjects()) != 1 {
+		t.Errorf("Unexpected pending objects: %+v", pending.GetPendingObjects())
+		return
+	}
+	missing := pending.GetPendingObjects()[0].GetMissingDependencies()
+	if len(missing) != 1 || missing[0].GetKind() != "jwtProvider" || missing[0].GetName() != "missing-provider" {
+		t.Errorf("Unexpected missing dependencies: %+v", missing)
+	}
+
+	snapshot, err := m.GetSnapshot(ctx, &management.GetSnapshotRequest{})
+	if err != nil {
+		t.Errorf("GetSnapshot error: %s", err)
+		return
+	}
+	config, err := envoy.UnmarshalRenderResult(snapshot.GetResources())
+	if err != nil {
+		t.Errorf("UnmarshalRenderResult error: %s", err)
+		return
+	}
+	if snapshot.GetVersion() == "" || len(config.Clusters) != 1 || len(config.Listeners) != 1 {
+		t.Errorf("Unexpected snapshot: %s %s", snapshot.GetVersion(), snapshot.GetResources())
+	}
+
+	nodes, err := m.ListNodes(ctx, &management.ListNodesRequest{})
+	if err != nil || len(nodes.GetNodes()) != 0 {
+		t.Errorf("Unexpected nodes: %+v (error: %v)", nodes.GetNodes(), err)
+	}
+
+	certificates, err := m.ListCertificates(ctx, &management.ListCertificatesRequest{})
+	if err != nil {
+		t.Errorf("ListCertificates error: %s", err)
+		return
+	}
+	if len(certificates.GetCertificates()) != 1 {
+		t.Errorf("Unexpected certificates: %+v", certificates.GetCertificates())
+		return
+	}
+	certificate := certificates.GetCertificates()[0]
+	if certificate.GetName() != "test1" || certificate.GetError() != "" || len(certificate.GetDomains()) != 1 || certificate.GetDomains()[0] != "test1.example.com" ||
+		certificate.GetRenewAfter() != certificate.GetNotAfter()-30*24*3600 {
+		t.Errorf("Unexpected certificate: %+v", certificate)
+	}
+
+	// the renewal queue only runs with an acme contact
+	if _, err := m.TriggerRenewal(ctx, &management.TriggerRenewalRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Expected failed precondition error, got: %v", err)
+	}
+	reply, err := m.TriggerImport(ctx, &management.TriggerImportRequest{})
+	if err != nil || !reply.GetResult() {
+		t.Errorf("Unexpected import reply: %+v (error: %v)", reply, err)
+	}
+}
+
+func newTestCertificate(domain string) ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().AddDate(0, 0, 90),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func TestManagementServerWrites(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "management")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	s, err := storage.NewStorage("local", localStorage.Config{Path: dir})
+	if err != nil {
+		t.Errorf("Couldn't initialize storage: %s", err)
+		return
+	}
+	xds := envoy.NewXDS(s, "", "")
+	if err := xds.ImportObjects(); err != nil {
+		t.Errorf("ImportObjects error: %s", err)
+		return
+	}
+	ctx := context.Background()
+	rule := []byte(`api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  conditions:
+    - hostname: test1.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+`)
+
+	if _, err := NewManagementServer(xds).ApplyObject(ctx, &management.ApplyObjectRequest{Data: rule}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected permission denied when writes are disabled, got: %v", err)
+	}
+
+	m := NewManagementServerWithConfig(xds, ManagementServerConfig{EnableWrites: true})
+	if _, err := m.ApplyObject(ctx, &management.ApplyObjectRequest{Data: rule, Format: "xml"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected invalid argument for unknown format, got: %v", err)
+	}
+	applied, err := m.ApplyObject(ctx, &management.ApplyObjectRequest{Data: rule})
+	if err != nil {
+		t.Errorf("ApplyObject error: %s", err)
+		return
+	}
+	if !applied.GetCreated() || applied.GetObject().GetName() != "test1" || applied.GetObject().GetResourceVersion() == "" {
+		t.Errorf("Unexpected apply reply: %+v", applied)
+	}
+	object, err := m.GetObject(ctx, &management.GetObjectRequest{Kind: "rule", Name: "test1"})
+	if err != nil {
+		t.Errorf("GetObject error: %s", err)
+		return
+	}
+	if object.GetObject().GetFilename() != "rule-test1.yaml" || object.GetObject().GetResourceVersion() != applied.GetObject().GetResourceVersion() {
+		t.Errorf("Unexpected object: %+v", object.GetObject())
+	}
+	if _, err := m.ApplyObject(ctx, &management.ApplyObjectRequest{Data: rule, ResourceVersion: "stale"}); status.Code(err) != codes.Aborted {
+		t.Errorf("Expected aborted for a version conflict, got: %v", err)
+	}
+	if _, err := m.DeleteObject(ctx, &management.DeleteObjectRequest{Kind: "rule", Name: "test2"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected not found, got: %v", err)
+	}
+	deleted, err := m.DeleteObject(ctx, &management.DeleteObjectRequest{Kind: "rule", Name: "test1", ResourceVersion: applied.GetObject().GetResourceVersion()})
+	if err != nil || deleted.GetFilename() != "rule-test1.yaml" {
+		t.Errorf("Unexpected delete reply: %+v (error: %v)", deleted, err)
+	}
+}
@@ -2,25 +2,75 @@ package management
 
 import (
 	"context"
+	"sync"
 
 	envoy "github.com/in4it/roxprox/pkg/envoy"
 	notification "github.com/in4it/roxprox/proto/notification"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+var notificationsReceivedMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "roxprox_notifications_received_total",
+	Help: "Notifications received by source (empty for senders without source id)",
+}, []string{"source"})
+
 type NotificationReceiver struct {
-	xds *envoy.XDS
+	xds       *envoy.XDS
+	mu        sync.Mutex
+	sequences map[string]uint64
 }
 
 func (n *NotificationReceiver) SendNotification(ctx context.Context, in *notification.NotificationRequest) (*notification.NotificationReply, error) {
 	logger.Debugf("Received %d events", len(in.GetNotificationItem()))
+	notificationsReceivedMetric.WithLabelValues(in.GetSourceId()).Inc()
+	if n.detectGap(in.GetSourceId(), in.GetSequence()) {
+		// storage is the source of truth, a resync also imports the items of this notification
+		logger.Infof("Missed notifications from %s (received sequence %d), resyncing with storage", in.GetSourceId(), in.GetSequence())
+		err := n.xds.Resync()
+		if err != nil {
+			logger.Errorf("Resync error: %s", err)
+			return &notification.NotificationReply{Result: false}, nil
+		}
+		return &notification.NotificationReply{Result: true}, nil
+	}
 	err := n.xds.ReceiveNotification(in.GetNotificationItem())
 	if err != nil {
 		logger.Errorf("%s", err)
 	}
 	return &notification.NotificationReply{Result: true}, nil
 }
+
+func (n *NotificationReceiver) Resync(ctx context.Context, in *notification.ResyncRequest) (*notification.NotificationReply, error) {
+	logger.Infof("Received resync request (source: %s)", in.GetSourceId())
+	err := n.xds.Resync()
+	if err != nil {
+		logger.Errorf("Resync error: %s", err)
+		return &notification.NotificationReply{Result: false}, nil
+	}
+	return &notification.NotificationReply{Result: true}, nil
+}
+
+// detectGap returns true when notifications of the source were missed. The first notification
+// of a source and notifications without a sequence number don't have gaps
+func (n *NotificationReceiver) detectGap(sourceID string, sequence uint64) bool {
+	if sourceID == "" || sequence == 0 {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	last, ok := n.sequences[sourceID]
+	if ok && sequence <= last {
+		logger.Debugf("Received sequence %d from %s again (last sequence: %d)", sequence, sourceID, last)
+		return false
+	}
+	n.sequences[sourceID] = sequence
+	return ok && sequence > last+1
+}
+
 func NewNotificationReceiver(xds *envoy.XDS) *NotificationReceiver {
 	return &NotificationReceiver{
-		xds: xds,
+		xds:       xds,
+		sequences: make(map[string]uint64),
 	}
 }
@@ -0,0 +1,27 @@
+package management
+
+import "testing"
+
+func TestDetectGap(t *testing.T) {
+	n := NewNotificationReceiver(nil)
+	tests := []struct {
+		sourceID string
+		sequence uint64
+		gap      bool
+	}{
+		{"a", 5, false}, // first notification of a source
+		{"a", 6, false},
+		{"a", 6, false}, // retry
+		{"a", 8, true},
+		{"b", 1, false},
+		{"a", 9, false},
+		{"", 20, false}, // no source id
+		{"a", 0, false}, // no sequence
+		{"a", 10, false},
+	}
+	for k, test := range tests {
+		if gap := n.detectGap(test.sourceID, test.sequence); gap != test.gap {
+			t.Errorf("Test %d: expected gap %v for %s/%d, got %v", k, test.gap, test.sourceID, test.sequence, gap)
+		}
+	}
+}
@@ -1,12 +1,21 @@
 package management
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
+	"strings"
 
+	"github.com/in4it/roxprox/pkg/crypto"
+	m "github.com/in4it/roxprox/proto/management"
 	n "github.com/in4it/roxprox/proto/notification"
 	"github.com/juju/loggo"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -15,15 +24,42 @@ const (
 
 var logger = loggo.GetLogger("management")
 
-func NewServer(notificationServer n.NotificationServer) error {
-	lis, err := net.Listen("tcp", port)
+// ServerConfig configures the grpc server of the notification and management services
+type ServerConfig struct {
+	Address   string      // defaults to :50051
+	TLSConfig *tls.Config // serve with TLS when set, client certificates are verified when the config has client CAs
+	// AllowedNotificationClients are the identities (subject alternative names) of the client certificates
+	// that can send notifications. All clients are allowed when empty
+	AllowedNotificationClients []string
+	// AllowedWriteClients are the identities of the client certificates that can apply and delete objects,
+	// and trigger an import or a certificate renewal. All clients are allowed when empty
+	AllowedWriteClients []string
+}
+
+func NewServer(notificationServer n.NotificationServer, managementServer m.ManagementServer) error {
+	return NewServerWithConfig(notificationServer, managementServer, ServerConfig{})
+}
+
+func NewServerWithConfig(notificationServer n.NotificationServer, managementServer m.ManagementServer, config ServerConfig) error {
+	if config.Address == "" {
+		config.Address = port
+	}
+	if (len(config.AllowedNotificationClients) > 0 || len(config.AllowedWriteClients) > 0) && (config.TLSConfig == nil || config.TLSConfig.ClientCAs == nil) {
+		return fmt.Errorf("Allowed clients need TLS with client certificate verification")
+	}
+	lis, err := net.Listen("tcp", config.Address)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
-	logger.Infof("Starting grpc management interface")
-	s := grpc.NewServer()
+	s := newGRPCServer(config)
 	n.RegisterNotificationServer(s, notificationServer)
+	m.RegisterManagementServer(s, managementServer)
 
+	if config.TLSConfig != nil {
+		logger.Infof("Starting grpc management interface with TLS")
+	} else {
+		logger.Infof("Starting grpc management interface")
+	}
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			logger.Errorf("failed to serve: %v", err)
@@ -32,3 +68,77 @@ func NewServer(notificationServer n.NotificationServer) error {
 
 	return nil
 }
+
+func newGRPCServer(config ServerConfig) *grpc.Server {
+	var options []grpc.ServerOption
+	if config.TLSConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(config.TLSConfig)))
+	}
+	if len(config.AllowedNotificationClients) > 0 || len(config.AllowedWriteClients) > 0 {
+		options = append(options, grpc.UnaryInterceptor(newAuthorizer(config)))
+	}
+	return grpc.NewServer(options...)
+}
+
+// writeMethods are the management methods that change the objects in the storage, or trigger an import or
+// a certificate renewal
+var writeMethods = map[string]bool{
+	"/Management/ApplyObject":    true,
+	"/Management/DeleteObject":   true,
+	"/Management/TriggerImport":  true,
+	"/Management/TriggerRenewal": true,
+}
+
+// newAuthorizer only lets clients with an allowed identity in their verified certificate call the notification service
+// and the write methods of the management service
+func newAuthorizer(config ServerConfig) grpc.UnaryServerInterceptor {
+	notificationClients := newIdentitySet(config.AllowedNotificationClients)
+	writeClients := newIdentitySet(config.AllowedWriteClients)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		switch {
+		case strings.HasPrefix(info.FullMethod, "/Notification/") && len(notificationClients) > 0:
+			if identities, ok := isAllowedClient(ctx, notificationClients); !ok {
+				logger.Infof("Notification client not allowed (identities: %s)", strings.Join(identities, ", "))
+				return nil, status.Errorf(codes.PermissionDenied, "client is not allowed to send notifications")
+			}
+		case writeMethods[info.FullMethod] && len(writeClients) > 0:
+			if identities, ok := isAllowedClient(ctx, writeClients); !ok {
+				logger.Infof("Write client not allowed (identities: %s)", strings.Join(identities, ", "))
+				return nil, status.Errorf(codes.PermissionDenied, "client is not allowed to change objects")
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func newIdentitySet(identities []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, identity := range identities {
+		set[identity] = true
+	}
+	return set
+}
+
+// isAllowedClient returns the identities of the client, and whether one of them is allowed
+func isAllowedClient(ctx context.Context, allowed map[string]bool) ([]string, bool) {
+	identities := getClientIdentities(ctx)
+	for _, identity := range identities {
+		if allowed[identity] {
+			return identities, true
+		}
+	}
+	return identities, false
+}
+
+// getClientIdentities returns the subject alternative names of the verified client certificate
+func getClientIdentities(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return crypto.GetCertificateIdentities(tlsInfo.State.VerifiedChains[0][0])
+}
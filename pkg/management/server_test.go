@@ -0,0 +1,202 @@
+package management
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/in4it/roxprox/pkg/crypto"
+	n "github.com/in4it/roxprox/proto/notification"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type testNotificationServer struct {
+	received int
+}
+
+func (t *testNotificationServer) SendNotification(ctx context.Context, in *n.NotificationRequest) (*n.NotificationReply, error) {
+	t.received++
+	return &n.NotificationReply{Result: true}, nil
+}
+func (t *testNotificationServer) Resync(ctx context.Context, in *n.ResyncRequest) (*n.NotificationReply, error) {
+	return &n.NotificationReply{Result: true}, nil
+}
+
+func TestServerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "tls")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	ca, caKey, err := newTestCA(dir)
+	if err != nil {
+		t.Errorf("Couldn't create CA: %s", err)
+		return
+	}
+	for _, name := range []string{"server", "peer", "other"} {
+		if err := newTestLeafCertificate(dir, name, ca, caKey); err != nil {
+			t.Errorf("Couldn't create certificate: %s", err)
+			return
+		}
+	}
+	serverTLSConfig, err := crypto.NewServerTLSConfig(crypto.TLSFiles{Cert: dir + "/server.crt", Key: dir + "/server.key", CA: dir + "/ca.crt"})
+	if err != nil {
+		t.Errorf("NewServerTLSConfig error: %s", err)
+		return
+	}
+
+	notificationServer := &testNotificationServer{}
+	s := newGRPCServer(ServerConfig{TLSConfig: serverTLSConfig, AllowedNotificationClients: []string{"peer.roxprox.local"}})
+	n.RegisterNotificationServer(s, notificationServer)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Couldn't listen: %s", err)
+		return
+	}
+	go s.Serve(lis)
+	defer s.Stop()
+
+	send := func(clientName string) error {
+		files := crypto.TLSFiles{CA: dir + "/ca.crt"}
+		if clientName != "" {
+			files.Cert = dir + "/" + clientName + ".crt"
+			files.Key = dir + "/" + clientName + ".key"
+		}
+		clientTLSConfig, err := crypto.NewClientTLSConfig(files, "server.roxprox.local")
+		if err != nil {
+			return err
+		}
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(clientTLSConfig)))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = n.NewNotificationClient(conn).SendNotification(ctx, &n.NotificationRequest{})
+		return err
+	}
+
+	if err := send("peer"); err != nil {
+		t.Errorf("Notification of an allowed client failed: %s", err)
+	}
+	if err := send("other"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected permission denied for a client that isn't allowed, got: %v", err)
+	}
+	if err := send(""); err == nil {
+		t.Errorf("Expected an error for a client without certificate")
+	}
+	if notificationServer.received != 1 {
+		t.Errorf("Expected 1 notification, got: %d", notificationServer.received)
+	}
+}
+
+func TestServerConfigValidation(t *testing.T) {
+	err := NewServerWithConfig(&testNotificationServer{}, nil, ServerConfig{AllowedNotificationClients: []string{"peer.roxprox.local"}})
+	if err == nil {
+		t.Errorf("Expected an error for allowed clients without TLS")
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	authorizer := newAuthorizer(ServerConfig{AllowedNotificationClients: []string{"peer.roxprox.local"}, AllowedWriteClients: []string{"deploy.roxprox.local"}})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	// the read methods of the management service aren't restricted by the allow-lists
+	if _, err := authorizer(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Management/ListNodes"}, handler); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	for _, method := range []string{"/Notification/Resync", "/Management/ApplyObject", "/Management/DeleteObject", "/Management/TriggerImport", "/Management/TriggerRenewal"} {
+		if _, err := authorizer(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Expected permission denied for %s without client certificate, got: %v", method, err)
+		}
+	}
+}
+
+func TestAuthorizerWriteClients(t *testing.T) {
+	authorizer := newAuthorizer(ServerConfig{AllowedWriteClients: []string{"deploy.roxprox.local"}})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	clientContext := func(name string) context.Context {
+		cert := &x509.Certificate{DNSNames: []string{name}}
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}},
+		})
+	}
+	for _, method := range []string{"/Management/ApplyObject", "/Management/DeleteObject", "/Management/TriggerImport", "/Management/TriggerRenewal"} {
+		if _, err := authorizer(clientContext("other.roxprox.local"), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Expected permission denied for %s of a client that isn't allowed, got: %v", method, err)
+		}
+		if _, err := authorizer(clientContext("deploy.roxprox.local"), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler); err != nil {
+			t.Errorf("Unexpected error for %s of an allowed client: %s", method, err)
+		}
+	}
+}
+
+func newTestCA(dir string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "roxprox test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(0, 0, 1),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ioutil.WriteFile(dir+"/ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	return ca, key, err
+}
+
+// newTestLeafCertificate writes <name>.crt and <name>.key, for <name>.roxprox.local
+func newTestLeafCertificate(dir, name string, ca *x509.Certificate, caKey *rsa.PrivateKey) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name + ".roxprox.local"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(0, 0, 1),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(dir+"/"+name+".crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return crypto.SavePEMKey(dir+"/"+name+".key", key)
+}
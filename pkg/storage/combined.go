@@ -0,0 +1,78 @@
+package storage
+
+import "fmt"
+
+// CombinedStorage reads the objects from an object store and the secrets from a secret store
+type CombinedStorage struct {
+	ObjectStore
+	SecretStore
+}
+
+func NewCombinedStorage(objects ObjectStore, secrets SecretStore) *CombinedStorage {
+	return &CombinedStorage{
+		ObjectStore: objects,
+		SecretStore: secrets,
+	}
+}
+
+func (c *CombinedStorage) SetLogLevel(loglevel string) {
+	if s, ok := c.ObjectStore.(interface{ SetLogLevel(string) }); ok {
+		s.SetLogLevel(loglevel)
+	}
+	if s, ok := c.SecretStore.(interface{ SetLogLevel(string) }); ok {
+		s.SetLogLevel(loglevel)
+	}
+}
+
+func (c *CombinedStorage) SetStoragePath(path string) {
+	if s, ok := c.ObjectStore.(interface{ SetStoragePath(string) }); ok {
+		s.SetStoragePath(path)
+	}
+}
+
+// GetConfigVersion returns the version of the object store (see VersionedStorage)
+func (c *CombinedStorage) GetConfigVersion() string {
+	if s, ok := c.ObjectStore.(VersionedStorage); ok {
+		return s.GetConfigVersion()
+	}
+	return ""
+}
+
+// SetObjectStatus reports the import state to the object store (see StatusStorage)
+func (c *CombinedStorage) SetObjectStatus(kind, name, state, message string) {
+	if s, ok := c.ObjectStore.(StatusStorage); ok {
+		s.SetObjectStatus(kind, name, state, message)
+	}
+}
+
+// ObjectFilename returns the filename of a new object file in the object store (see WritableStorage)
+func (c *CombinedStorage) ObjectFilename(basename string) string {
+	if s, ok := c.ObjectStore.(WritableStorage); ok {
+		return s.ObjectFilename(basename)
+	}
+	return basename
+}
+
+// GetObjectVersion returns the version of an object file in the object store (see WritableStorage)
+func (c *CombinedStorage) GetObjectVersion(filename string) (string, error) {
+	if s, ok := c.ObjectStore.(WritableStorage); ok {
+		return s.GetObjectVersion(filename)
+	}
+	return "", fmt.Errorf("The object storage can't be written to")
+}
+
+// PutObject writes an object file to the object store (see WritableStorage)
+func (c *CombinedStorage) PutObject(filename string, contents []byte, version string) (string, error) {
+	if s, ok := c.ObjectStore.(WritableStorage); ok {
+		return s.PutObject(filename, contents, version)
+	}
+	return "", fmt.Errorf("The object storage can't be written to")
+}
+
+// DeleteObject deletes an object file from the object store (see WritableStorage)
+func (c *CombinedStorage) DeleteObject(filename string, version string) error {
+	if s, ok := c.ObjectStore.(WritableStorage); ok {
+		return s.DeleteObject(filename, version)
+	}
+	return fmt.Errorf("The object storage can't be written to")
+}
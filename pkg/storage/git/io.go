@@ -0,0 +1,201 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/in4it/roxprox/pkg/crypto"
+	"github.com/in4it/roxprox/pkg/storage/local"
+	"github.com/juju/loggo"
+)
+
+var logger = loggo.GetLogger("storage.git")
+
+type Config struct {
+	Repository  string             // url of the repository
+	Branch      string             // defaults to the default branch of the repository
+	WorkDir     string             // the repository is cloned in this directory
+	Path        string             // directory within the repository with the objects
+	KeyProvider crypto.KeyProvider // encrypts the certificates and keys when set
+}
+
+// GitStorage reads the objects from a clone of a git repository. Certificates and keys are
+// written to the work dir (they're not committed)
+type GitStorage struct {
+	*local.LocalStorage
+	config Config
+	mu     sync.Mutex
+	commit string
+}
+
+func NewGitStorage(config Config) (*GitStorage, error) {
+	if config.Repository == "" {
+		return nil, fmt.Errorf("No git repository specified")
+	}
+	if config.WorkDir == "" {
+		config.WorkDir = getDefaultWorkDir(config)
+	}
+	workDir, err := filepath.Abs(config.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	config.WorkDir = workDir
+
+	g := &GitStorage{config: config}
+
+	if _, err := os.Stat(filepath.Join(config.WorkDir, ".git")); os.IsNotExist(err) {
+		err = g.clone()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err = g.checkRemote()
+		if err != nil {
+			return nil, err
+		}
+		if g.config.Branch == "" {
+			g.config.Branch, err = g.git("rev-parse", "--abbrev-ref", "HEAD")
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = g.Fetch()
+		if err != nil {
+			return nil, err
+		}
+		err = g.Checkout("origin/" + g.config.Branch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := g.git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	g.commit = commit
+	logger.Infof("Using git repository %s (branch: %s, commit: %s)", config.Repository, g.config.Branch, commit)
+
+	localStorage, err := local.NewLocalStorage(local.Config{Path: filepath.Join(config.WorkDir, config.Path), KeyProvider: config.KeyProvider})
+	if err != nil {
+		return nil, err
+	}
+	g.LocalStorage = localStorage
+
+	return g, nil
+}
+
+// getDefaultWorkDir returns a work dir in the temp dir for the repository and branch, so instances using
+// different repositories or branches don't share a work dir, and the clone is reused after a restart
+func getDefaultWorkDir(config Config) string {
+	sum := sha256.Sum256([]byte(config.Repository + "#" + config.Branch))
+	return filepath.Join(os.TempDir(), "roxprox-git-"+hex.EncodeToString(sum[:8]))
+}
+
+// checkRemote verifies that an existing work dir is a clone of the repository
+func (g *GitStorage) checkRemote() error {
+	remote, err := g.git("remote", "get-url", "origin")
+	if err != nil {
+		return err
+	}
+	if remote != g.config.Repository {
+		return fmt.Errorf("Work dir %s is a clone of %s, not of %s", g.config.WorkDir, remote, g.config.Repository)
+	}
+	return nil
+}
+
+func (g *GitStorage) clone() error {
+	args := []string{"clone", "--single-branch"}
+	if g.config.Branch != "" {
+		args = append(args, "--branch", g.config.Branch)
+	}
+	out, err := exec.Command("git", append(args, g.config.Repository, g.config.WorkDir)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Couldn't clone %s: %s (%s)", g.config.Repository, err, strings.TrimSpace(string(out)))
+	}
+	if g.config.Branch == "" {
+		branch, err := g.git("rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return err
+		}
+		g.config.Branch = branch
+	}
+	return nil
+}
+
+// Fetch fetches the branch and returns the latest commit
+func (g *GitStorage) Fetch() (string, error) {
+	_, err := g.git("fetch", "origin", "+refs/heads/"+g.config.Branch+":refs/remotes/origin/"+g.config.Branch)
+	if err != nil {
+		return "", err
+	}
+	return g.git("rev-parse", "origin/"+g.config.Branch)
+}
+
+// Checkout updates the work dir to the commit. The config version is set with SetConfigVersion once the
+// objects of the commit are imported
+func (g *GitStorage) Checkout(commit string) error {
+	_, err := g.git("reset", "--hard", commit)
+	return err
+}
+
+// ChangedFiles returns the files within the storage path that changed between two commits.
+// The filenames are relative to the storage path
+func (g *GitStorage) ChangedFiles(from, to string) (changed []string, deleted []string, err error) {
+	args := []string{"diff", "--name-status", "--no-renames", "-z", from, to}
+	if g.config.Path != "" {
+		args = append(args, "--", g.config.Path)
+	}
+	out, err := g.git(args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, filename := fields[i], fields[i+1]
+		if g.config.Path != "" {
+			filename, err = filepath.Rel(g.config.Path, filename)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if strings.Contains(filename, "/") {
+			// only the objects in the storage path are imported, not the subdirectories
+			continue
+		}
+		if status == "D" {
+			deleted = append(deleted, filename)
+		} else {
+			changed = append(changed, filename)
+		}
+	}
+	return changed, deleted, nil
+}
+
+// GetConfigVersion returns the commit of which the objects are imported
+func (g *GitStorage) GetConfigVersion() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.commit
+}
+
+// SetConfigVersion sets the commit of which the objects are imported
+func (g *GitStorage) SetConfigVersion(commit string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.commit = commit
+}
+
+func (g *GitStorage) git(args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", g.config.WorkDir}, args...)...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s error: %s (%s)", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,95 @@
+package git
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/in4it/roxprox/pkg/storage/util"
+	n "github.com/in4it/roxprox/proto/notification"
+)
+
+// Watcher fetches the branch every interval and converts the changed and deleted files
+// between the imported commit and the new commit into notifications
+type Watcher struct {
+	storage  *GitStorage
+	interval time.Duration
+	stop     chan struct{}
+}
+
+func NewWatcher(storage *GitStorage, interval time.Duration) *Watcher {
+	return &Watcher{
+		storage:  storage,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start fetches the branch every interval and sends the notifications to receive
+func (w *Watcher) Start(receive util.NotificationReceiver) {
+	logger.Infof("Polling %s for new commits (branch: %s, interval: %s)", w.storage.config.Repository, w.storage.config.Branch, w.interval)
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := w.Poll(receive)
+				if err != nil {
+					logger.Errorf("Couldn't poll git repository: %s", err)
+				}
+			case <-w.stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (w *Watcher) Stop() {
+	close(w.stop)
+}
+
+// Poll fetches the branch, checks out the latest commit and sends the changes to receive. The config version
+// is only updated when the changes are received without error, otherwise they're sent again at the next poll
+func (w *Watcher) Poll(receive util.NotificationReceiver) error {
+	var items []*n.NotificationRequest_NotificationItem
+
+	current := w.storage.GetConfigVersion()
+	latest, err := w.storage.Fetch()
+	if err != nil {
+		return err
+	}
+	if latest == current {
+		return nil
+	}
+
+	changed, deleted, err := w.storage.ChangedFiles(current, latest)
+	if err != nil {
+		return err
+	}
+	// the work dir needs to be up to date before the notifications are processed
+	err = w.storage.Checkout(latest)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range changed {
+		if util.IsObjectFile(filename) {
+			items = append(items, &n.NotificationRequest_NotificationItem{Filename: filename, EventName: util.EventObjectCreated})
+		}
+	}
+	for _, filename := range deleted {
+		if util.IsObjectFile(filename) {
+			items = append(items, &n.NotificationRequest_NotificationItem{Filename: filename, EventName: util.EventObjectRemoved})
+		}
+	}
+	if len(items) > 0 {
+		err = receive(items)
+		if err != nil {
+			return fmt.Errorf("Couldn't import commit %s (retrying at the next poll): %s", latest, err)
+		}
+	}
+	w.storage.SetConfigVersion(latest)
+	logger.Infof("Updated to commit %s (%d changed objects)", latest, len(items))
+
+	return nil
+}
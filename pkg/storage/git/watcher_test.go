@@ -0,0 +1,209 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	n "github.com/in4it/roxprox/proto/notification"
+)
+
+const testRule = `api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: %s
+spec:
+  conditions:
+    - hostname: %s.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+`
+
+func runGit(dir string, args ...string) (string, error) {
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s (%s)", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// commitFiles writes (or removes, when the contents are empty) the files and pushes a commit to the bare repository
+func commitFiles(dir string, files map[string]string) (string, error) {
+	for filename, contents := range files {
+		if contents == "" {
+			if _, err := runGit(dir, "rm", "-q", filename); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, filename)), 0755); err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, filename), []byte(contents), 0644); err != nil {
+			return "", err
+		}
+		if _, err := runGit(dir, "add", filename); err != nil {
+			return "", err
+		}
+	}
+	if _, err := runGit(dir, "commit", "-q", "-m", "update"); err != nil {
+		return "", err
+	}
+	if _, err := runGit(dir, "push", "-q", "origin", "HEAD:main"); err != nil {
+		return "", err
+	}
+	return runGit(dir, "rev-parse", "HEAD")
+}
+
+// testReceiver records the received items, and fails when fail is set
+type testReceiver struct {
+	items []*n.NotificationRequest_NotificationItem
+	fail  bool
+}
+
+func (r *testReceiver) receive(items []*n.NotificationRequest_NotificationItem) error {
+	r.items = append(r.items, items...)
+	if r.fail {
+		return fmt.Errorf("receive failed")
+	}
+	return nil
+}
+
+func TestGitStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitstorage")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	remote := filepath.Join(dir, "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", "--initial-branch=main", remote).CombinedOutput(); err != nil {
+		t.Errorf("git init error: %s (%s)", err, out)
+		return
+	}
+	src := filepath.Join(dir, "src")
+	if out, err := exec.Command("git", "clone", "-q", remote, src).CombinedOutput(); err != nil {
+		t.Errorf("git clone error: %s (%s)", err, out)
+		return
+	}
+	_, err = commitFiles(src, map[string]string{
+		"config/rule1.yaml": fmt.Sprintf(testRule, "rule1", "rule1"),
+		"config/rule2.yaml": fmt.Sprintf(testRule, "rule2", "rule2"),
+		"README.md":         "roxprox config",
+	})
+	if err != nil {
+		t.Errorf("Commit error: %s", err)
+		return
+	}
+
+	s, err := NewGitStorage(Config{Repository: remote, WorkDir: filepath.Join(dir, "work"), Path: "config"})
+	if err != nil {
+		t.Errorf("NewGitStorage error: %s", err)
+		return
+	}
+	objects, err := s.ListObjects()
+	if err != nil {
+		t.Errorf("ListObjects error: %s", err)
+		return
+	}
+	if len(objects) != 2 {
+		t.Errorf("Expected 2 objects, got %d", len(objects))
+		return
+	}
+
+	w := NewWatcher(s, 0)
+	receiver := &testReceiver{}
+	err = w.Poll(receiver.receive)
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(receiver.items) != 0 {
+		t.Errorf("Expected no items, got: %+v", receiver.items)
+		return
+	}
+	previousCommit := s.GetConfigVersion()
+
+	commit, err := commitFiles(src, map[string]string{
+		"config/rule1.yaml":        fmt.Sprintf(testRule, "rule1", "rule1-changed"),
+		"config/rule2.yaml":        "",
+		"config/rule3.yaml":        fmt.Sprintf(testRule, "rule3", "rule3"),
+		"config/subdir/rule4.yaml": fmt.Sprintf(testRule, "rule4", "rule4"),
+		"other/rule5.yaml":         fmt.Sprintf(testRule, "rule5", "rule5"),
+	})
+	if err != nil {
+		t.Errorf("Commit error: %s", err)
+		return
+	}
+	// a failed import keeps the config version, the changes are sent again at the next poll
+	receiver.fail = true
+	if err := w.Poll(receiver.receive); err == nil {
+		t.Errorf("Expected poll error when the items can't be received")
+		return
+	}
+	if s.GetConfigVersion() != previousCommit {
+		t.Errorf("Expected config version %s after a failed import, got %s", previousCommit, s.GetConfigVersion())
+		return
+	}
+	receiver = &testReceiver{}
+	err = w.Poll(receiver.receive)
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	var events []string
+	for _, item := range receiver.items {
+		events = append(events, item.EventName+" "+item.Filename)
+	}
+	sort.Strings(events)
+	expected := []string{"ObjectCreated:Put rule1.yaml", "ObjectCreated:Put rule3.yaml", "ObjectRemoved:Delete rule2.yaml"}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected events: %v (expected %v)", events, expected)
+		return
+	}
+	if s.GetConfigVersion() != commit {
+		t.Errorf("Expected config version %s, got %s", commit, s.GetConfigVersion())
+		return
+	}
+	objects, err = s.GetObject("rule1.yaml")
+	if err != nil {
+		t.Errorf("GetObject error: %s", err)
+		return
+	}
+	if len(objects) != 1 || !strings.Contains(fmt.Sprintf("%+v", objects[0].Data), "rule1-changed") {
+		t.Errorf("Work dir not updated: %+v", objects)
+		return
+	}
+
+	// existing work dir is reused
+	s, err = NewGitStorage(Config{Repository: remote, WorkDir: filepath.Join(dir, "work"), Path: "config"})
+	if err != nil {
+		t.Errorf("NewGitStorage error: %s", err)
+		return
+	}
+	if s.GetConfigVersion() != commit {
+		t.Errorf("Expected config version %s, got %s", commit, s.GetConfigVersion())
+	}
+
+	// a work dir of another repository isn't reused
+	other := filepath.Join(dir, "other.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", "--initial-branch=main", other).CombinedOutput(); err != nil {
+		t.Errorf("git init error: %s (%s)", err, out)
+		return
+	}
+	if _, err := NewGitStorage(Config{Repository: other, WorkDir: filepath.Join(dir, "work"), Path: "config"}); err == nil {
+		t.Errorf("Expected error when the work dir is a clone of another repository")
+	}
+	if getDefaultWorkDir(Config{Repository: remote}) == getDefaultWorkDir(Config{Repository: other}) {
+		t.Errorf("Expected a different default work dir for each repository")
+	}
+}
@@ -0,0 +1,288 @@
+package httpsource
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/storage/util"
+	n "github.com/in4it/roxprox/proto/notification"
+	"github.com/juju/loggo"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	logger      = loggo.GetLogger("storage.http")
+	errNotExist = errors.New("Object does not exist")
+)
+
+type Config struct {
+	URL      string // url of the manifest
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// Manifest lists the urls of the objects. Relative urls are resolved against the url of the manifest.
+// A manifest that doesn't list objects is read as a multi-document yaml file with the objects
+type Manifest struct {
+	Objects []string `yaml:"objects"`
+}
+
+// HTTPStorage is an object store that fetches the objects from a http(s) endpoint. The manifest and the objects are fetched
+// every interval with If-None-Match, and the changed objects are sent as notifications to the queue.
+// Objects are named by their url, or by <kind>/<name> when the manifest contains the objects
+type HTTPStorage struct {
+	config    Config
+	client    *http.Client
+	mu        sync.Mutex
+	documents map[string][]byte // filename -> contents
+	eTags     map[string]string // url -> etag
+	bodies    map[string][]byte // url -> last fetched body
+	cache     *util.ObjectCache
+	queue     chan []*n.NotificationRequest_NotificationItem
+	stop      chan struct{}
+}
+
+func NewHTTPStorage(config Config) (*HTTPStorage, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("No url specified")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	h := &HTTPStorage{
+		config:    config,
+		client:    &http.Client{Timeout: config.Timeout},
+		documents: make(map[string][]byte),
+		eTags:     make(map[string]string),
+		bodies:    make(map[string][]byte),
+		cache:     util.NewObjectCache(),
+		queue:     make(chan []*n.NotificationRequest_NotificationItem),
+		stop:      make(chan struct{}),
+	}
+
+	// objects that exist at startup are imported with ListObjects
+	documents, err := h.fetchDocuments()
+	if err != nil {
+		return nil, err
+	}
+	h.documents = documents
+
+	return h, nil
+}
+
+func (h *HTTPStorage) GetQueue() chan []*n.NotificationRequest_NotificationItem {
+	return h.queue
+}
+
+// Start fetches the manifest every interval and sends notifications to the queue
+func (h *HTTPStorage) Start() {
+	logger.Infof("Polling %s for changes (interval: %s)", h.config.URL, h.config.Interval)
+	ticker := time.NewTicker(h.config.Interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				items, err := h.Poll()
+				if err != nil {
+					logger.Errorf("Couldn't poll %s: %s", h.config.URL, err)
+					continue
+				}
+				if len(items) > 0 {
+					h.queue <- items
+				}
+			case <-h.stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (h *HTTPStorage) Stop() {
+	close(h.stop)
+}
+
+// Poll fetches the manifest and the objects, and returns the objects that changed
+func (h *HTTPStorage) Poll() ([]*n.NotificationRequest_NotificationItem, error) {
+	var items []*n.NotificationRequest_NotificationItem
+
+	documents, err := h.fetchDocuments()
+	if err != nil {
+		return items, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, filename := range sortedKeys(documents) {
+		if current, ok := h.documents[filename]; !ok || !bytes.Equal(current, documents[filename]) {
+			items = append(items, &n.NotificationRequest_NotificationItem{Filename: filename, EventName: util.EventObjectCreated})
+		}
+	}
+	for _, filename := range sortedKeys(h.documents) {
+		if _, ok := documents[filename]; !ok {
+			items = append(items, &n.NotificationRequest_NotificationItem{Filename: filename, EventName: util.EventObjectRemoved})
+		}
+	}
+	h.documents = documents
+
+	return items, nil
+}
+
+// fetchDocuments returns the contents of the objects by filename
+func (h *HTTPStorage) fetchDocuments() (map[string][]byte, error) {
+	documents := make(map[string][]byte)
+
+	contents, err := h.fetch(h.config.URL)
+	if err != nil {
+		return documents, err
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(contents, &manifest); err != nil || len(manifest.Objects) == 0 {
+		// the manifest contains the objects
+		for _, document := range util.SplitDocuments(contents) {
+			var object api.Object
+			err = yaml.Unmarshal([]byte(document), &object)
+			if err != nil {
+				return documents, fmt.Errorf("Couldn't parse manifest: %s", err)
+			}
+			documents[object.Kind+"/"+object.Metadata.Name] = []byte(document)
+		}
+		return documents, nil
+	}
+
+	base, err := url.Parse(h.config.URL)
+	if err != nil {
+		return documents, err
+	}
+	for _, objectURL := range manifest.Objects {
+		ref, err := url.Parse(objectURL)
+		if err != nil {
+			return documents, fmt.Errorf("Invalid url in manifest: %s", objectURL)
+		}
+		resolvedURL := base.ResolveReference(ref).String()
+		contents, err := h.fetch(resolvedURL)
+		if err != nil {
+			return documents, err
+		}
+		documents[resolvedURL] = contents
+	}
+	return documents, nil
+}
+
+// fetch returns the body of the url. The body is only downloaded again when the etag changed
+func (h *HTTPStorage) fetch(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	eTag, hasETag := h.eTags[url]
+	h.mu.Unlock()
+	if hasETag {
+		req.Header.Set("If-None-Match", eTag)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't fetch %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		logger.Tracef("%s not modified", url)
+		return h.bodies[url], nil
+	case http.StatusOK:
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read %s: %s", url, err)
+		}
+		if eTag := resp.Header.Get("ETag"); eTag != "" {
+			h.eTags[url] = eTag
+			h.bodies[url] = body
+		} else {
+			delete(h.eTags, url)
+			delete(h.bodies, url)
+		}
+		return body, nil
+	default:
+		return nil, fmt.Errorf("Couldn't fetch %s: %s", url, resp.Status)
+	}
+}
+
+func sortedKeys(documents map[string][]byte) []string {
+	keys := make([]string, 0, len(documents))
+	for key := range documents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (h *HTTPStorage) ListObjects() ([]api.Object, error) {
+	var objects []api.Object
+	filenames, err := h.ListObjectFilenames()
+	if err != nil {
+		return objects, err
+	}
+	for _, filename := range filenames {
+		object, err := h.GetObject(filename)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object...)
+	}
+	return objects, nil
+}
+
+func (h *HTTPStorage) ListObjectFilenames() ([]string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return sortedKeys(h.documents), nil
+}
+
+// GetObject returns the objects of the last fetched version
+func (h *HTTPStorage) GetObject(filename string) ([]api.Object, error) {
+	h.mu.Lock()
+	contents, ok := h.documents[filename]
+	h.mu.Unlock()
+	if !ok {
+		return []api.Object{}, errNotExist
+	}
+	objects, err := util.ParseObjects(filename, contents)
+	if err != nil {
+		return objects, err
+	}
+	// keep a cache of filename -> rule name matching
+	h.cache.SetCachedObjects(filename, objects)
+	return objects, nil
+}
+
+func (h *HTTPStorage) GetCachedObjectName(filename string) ([]*api.Object, error) {
+	return h.cache.GetCachedObjectName(filename)
+}
+func (h *HTTPStorage) DeleteCachedObject(filename string) error {
+	return h.cache.DeleteCachedObject(filename)
+}
+func (h *HTTPStorage) ListCachedObjectFilenames() []string {
+	return h.cache.ListCachedObjectFilenames()
+}
+func (h *HTTPStorage) CountCachedObjectByCondition(condition api.RuleConditions, actions []api.RuleActions) int {
+	return h.cache.CountCachedObjectByCondition(condition, actions)
+}
+func (h *HTTPStorage) CountCachedJwtRulesByCondition(condition api.RuleConditions, jwtProvider string) int {
+	return h.cache.CountCachedJwtRulesByCondition(condition, jwtProvider)
+}
+func (h *HTTPStorage) GetCachedRule(name string) *api.Object {
+	return h.cache.GetCachedRule(name)
+}
@@ -0,0 +1,185 @@
+package httpsource
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/in4it/roxprox/pkg/api"
+)
+
+const testRule = `api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: %s
+spec:
+  conditions:
+    - hostname: %s
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+`
+
+// fakeServer serves files with an ETag and answers 304 when If-None-Match matches
+type fakeServer struct {
+	mu          sync.Mutex
+	files       map[string]string
+	notModified int
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	contents, ok := f.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	eTag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(contents)))
+	if r.Header.Get("If-None-Match") == eTag {
+		f.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", eTag)
+	w.Write([]byte(contents))
+}
+
+func (f *fakeServer) set(path, contents string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if contents == "" {
+		delete(f.files, path)
+		return
+	}
+	f.files[path] = contents
+}
+
+func events(h *HTTPStorage) ([]string, error) {
+	items, err := h.Poll()
+	if err != nil {
+		return nil, err
+	}
+	var events []string
+	for _, item := range items {
+		events = append(events, item.EventName+" "+item.Filename)
+	}
+	sort.Strings(events)
+	return events, nil
+}
+
+func TestHTTPStorageMultiDocument(t *testing.T) {
+	f := &fakeServer{files: map[string]string{
+		"/manifest.yaml": fmt.Sprintf(testRule, "rule1", "rule1.example.com") + "---\n" + fmt.Sprintf(testRule, "rule2", "rule2.example.com"),
+	}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	h, err := NewHTTPStorage(Config{URL: server.URL + "/manifest.yaml"})
+	if err != nil {
+		t.Errorf("NewHTTPStorage error: %s", err)
+		return
+	}
+	objects, err := h.ListObjects()
+	if err != nil {
+		t.Errorf("ListObjects error: %s", err)
+		return
+	}
+	if len(objects) != 2 {
+		t.Errorf("Expected 2 objects, got: %+v", objects)
+		return
+	}
+
+	// not modified
+	e, err := events(h)
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(e) != 0 || f.notModified != 1 {
+		t.Errorf("Expected no events and a 304 response, got: %v (304 responses: %d)", e, f.notModified)
+		return
+	}
+
+	f.set("/manifest.yaml", fmt.Sprintf(testRule, "rule1", "rule1-changed.example.com")+"---\n"+fmt.Sprintf(testRule, "rule3", "rule3.example.com"))
+	e, err = events(h)
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	expected := []string{"ObjectCreated:Put rule/rule1", "ObjectCreated:Put rule/rule3", "ObjectRemoved:Delete rule/rule2"}
+	if strings.Join(e, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected events: %v (expected %v)", e, expected)
+		return
+	}
+	objects, err = h.GetObject("rule/rule1")
+	if err != nil {
+		t.Errorf("GetObject error: %s", err)
+		return
+	}
+	if objects[0].Data.(api.Rule).Spec.Conditions[0].Hostname != "rule1-changed.example.com" {
+		t.Errorf("Unexpected object: %+v", objects[0])
+	}
+}
+
+func TestHTTPStorageObjectURLs(t *testing.T) {
+	f := &fakeServer{files: map[string]string{
+		"/config/manifest.yaml":    "objects:\n  - rules/rule1.yaml\n  - /other/rule2.yaml\n",
+		"/config/rules/rule1.yaml": fmt.Sprintf(testRule, "rule1", "rule1.example.com"),
+		"/other/rule2.yaml":        fmt.Sprintf(testRule, "rule2", "rule2.example.com"),
+	}}
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	h, err := NewHTTPStorage(Config{URL: server.URL + "/config/manifest.yaml"})
+	if err != nil {
+		t.Errorf("NewHTTPStorage error: %s", err)
+		return
+	}
+	filenames, _ := h.ListObjectFilenames()
+	expected := []string{server.URL + "/config/rules/rule1.yaml", server.URL + "/other/rule2.yaml"}
+	if strings.Join(filenames, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected filenames: %v (expected %v)", filenames, expected)
+		return
+	}
+
+	// object changes, manifest doesn't
+	f.set("/config/rules/rule1.yaml", fmt.Sprintf(testRule, "rule1", "rule1-changed.example.com"))
+	e, err := events(h)
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(e) != 1 || e[0] != "ObjectCreated:Put "+server.URL+"/config/rules/rule1.yaml" {
+		t.Errorf("Unexpected events: %v", e)
+		return
+	}
+
+	// object removed from the manifest
+	f.set("/config/manifest.yaml", "objects:\n  - rules/rule1.yaml\n")
+	e, err = events(h)
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(e) != 1 || e[0] != "ObjectRemoved:Delete "+server.URL+"/other/rule2.yaml" {
+		t.Errorf("Unexpected events: %v", e)
+		return
+	}
+
+	// fetch errors are returned, the objects are kept
+	f.set("/config/rules/rule1.yaml", "")
+	if _, err := h.Poll(); err == nil {
+		t.Errorf("Expected error when object is not found")
+		return
+	}
+	if filenames, _ := h.ListObjectFilenames(); len(filenames) != 1 {
+		t.Errorf("Expected objects to be kept, got: %v", filenames)
+	}
+}
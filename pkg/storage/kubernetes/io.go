@@ -0,0 +1,338 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/storage/util"
+	n "github.com/in4it/roxprox/proto/notification"
+	"github.com/juju/loggo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	Group   = "proxy.in4it.io"
+	Version = "v1"
+)
+
+var (
+	logger       = loggo.GetLogger("storage.kubernetes")
+	errNotExist  = errors.New("Custom resource does not exist")
+	errDuplicate = errors.New("Custom resource with the same kind and name exists in another namespace")
+)
+
+// Resources maps the custom resources on the roxprox object kinds
+var Resources = map[string]string{
+	"rules":            "rule",
+	"jwtproviders":     "jwtProvider",
+	"authzfilters":     "authzFilter",
+	"tracings":         "tracing",
+	"compressions":     "compression",
+	"accesslogservers": "accessLogServer",
+	"ratelimits":       "rateLimit",
+	"mtlses":           "mTLS",
+}
+
+type Config struct {
+	Kubeconfig string // defaults to the in-cluster config
+	Namespace  string // defaults to all namespaces
+}
+
+// KubernetesStorage is an object store that reads the objects from custom resources. Changes are received using informers and
+// are sent as notifications to the queue. The custom resources are named <resource>/<namespace>/<name>
+// in the notifications. The object names are unique across namespaces: a custom resource with the same kind and name
+// as a custom resource in another namespace isn't imported and gets an error status
+type KubernetesStorage struct {
+	config           Config
+	client           dynamic.Interface
+	listers          map[string]cache.GenericLister
+	mu               sync.Mutex
+	cache            *util.ObjectCache
+	filenames        map[string]string // kind/name -> filename of the imported custom resource
+	resourceVersions map[string]string // resource versions at startup (already imported)
+	queue            chan []*n.NotificationRequest_NotificationItem
+	stop             chan struct{}
+}
+
+func NewKubernetesStorage(config Config) (*KubernetesStorage, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", config.Kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load kubernetes config: %s", err)
+	}
+	client, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create kubernetes client: %s", err)
+	}
+	return newKubernetesStorage(config, client)
+}
+
+func newKubernetesStorage(config Config, client dynamic.Interface) (*KubernetesStorage, error) {
+	k := &KubernetesStorage{
+		config:    config,
+		client:    client,
+		listers:   make(map[string]cache.GenericLister),
+		cache:     util.NewObjectCache(),
+		filenames: make(map[string]string),
+		queue:     make(chan []*n.NotificationRequest_NotificationItem),
+		stop:      make(chan struct{}),
+	}
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, config.Namespace, nil)
+	for resource := range Resources {
+		informer := factory.ForResource(gvr(resource))
+		informer.Informer().AddEventHandler(k.eventHandler(resource))
+		k.listers[resource] = informer.Lister()
+	}
+	factory.Start(k.stop)
+	for resource, synced := range factory.WaitForCacheSync(k.stop) {
+		if !synced {
+			close(k.stop)
+			return nil, fmt.Errorf("Couldn't sync informer for %s", resource.Resource)
+		}
+	}
+
+	// custom resources that exist at startup are imported with ListObjects
+	resourceVersions := make(map[string]string)
+	for resource, lister := range k.listers {
+		items, err := lister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			u := item.(*unstructured.Unstructured)
+			resourceVersions[filename(resource, u)] = u.GetResourceVersion()
+		}
+	}
+	k.mu.Lock()
+	k.resourceVersions = resourceVersions
+	k.mu.Unlock()
+
+	logger.Infof("Watching custom resources (namespace: %s)", config.Namespace)
+
+	return k, nil
+}
+
+func gvr(resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: Group, Version: Version, Resource: resource}
+}
+
+func filename(resource string, u *unstructured.Unstructured) string {
+	return resource + "/" + u.GetNamespace() + "/" + u.GetName()
+}
+
+func (k *KubernetesStorage) eventHandler(resource string) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			k.mu.Lock()
+			initialVersion, imported := k.resourceVersions[filename(resource, u)]
+			synced := k.resourceVersions != nil
+			k.mu.Unlock()
+			if !synced || (imported && initialVersion == u.GetResourceVersion()) {
+				return
+			}
+			k.notify(filename(resource, u), util.EventObjectCreated)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldU, ok := oldObj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			newU, ok := newObj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			// status updates and resyncs don't change the spec
+			if reflect.DeepEqual(oldU.Object["spec"], newU.Object["spec"]) {
+				return
+			}
+			k.notify(filename(resource, newU), util.EventObjectCreated)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			k.notify(filename(resource, u), util.EventObjectRemoved)
+		},
+	}
+}
+
+func (k *KubernetesStorage) notify(filename, eventName string) {
+	logger.Debugf("Received %s for %s", eventName, filename)
+	k.queue <- []*n.NotificationRequest_NotificationItem{{Filename: filename, EventName: eventName}}
+}
+
+func (k *KubernetesStorage) GetQueue() chan []*n.NotificationRequest_NotificationItem {
+	return k.queue
+}
+
+func (k *KubernetesStorage) Stop() {
+	close(k.stop)
+}
+
+func (k *KubernetesStorage) ListObjects() ([]api.Object, error) {
+	var objects []api.Object
+	filenames, err := k.ListObjectFilenames()
+	if err != nil {
+		return objects, err
+	}
+	for _, filename := range filenames {
+		object, err := k.GetObject(filename)
+		if err == errDuplicate {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object...)
+	}
+	return objects, nil
+}
+
+func (k *KubernetesStorage) ListObjectFilenames() ([]string, error) {
+	var filenames []string
+	for resource, lister := range k.listers {
+		items, err := lister.List(labels.Everything())
+		if err != nil {
+			return filenames, err
+		}
+		for _, item := range items {
+			filenames = append(filenames, filename(resource, item.(*unstructured.Unstructured)))
+		}
+	}
+	sort.Strings(filenames)
+	return filenames, nil
+}
+
+func (k *KubernetesStorage) get(filename string) (string, *unstructured.Unstructured, error) {
+	parts := strings.Split(filename, "/")
+	if len(parts) != 3 {
+		return "", nil, fmt.Errorf("Invalid custom resource name: %s", filename)
+	}
+	lister, ok := k.listers[parts[0]]
+	if !ok {
+		return "", nil, fmt.Errorf("Unknown custom resource: %s", parts[0])
+	}
+	item, err := lister.ByNamespace(parts[1]).Get(parts[2])
+	if err != nil {
+		return "", nil, errNotExist
+	}
+	return parts[0], item.(*unstructured.Unstructured), nil
+}
+
+func (k *KubernetesStorage) GetObject(filename string) ([]api.Object, error) {
+	var objects []api.Object
+	resource, u, err := k.get(filename)
+	if err != nil {
+		return objects, err
+	}
+	object, err := toObject(Resources[resource], u)
+	if err != nil {
+		return objects, fmt.Errorf("Couldn't parse %s: %s", filename, err)
+	}
+	objects = append(objects, object)
+
+	k.mu.Lock()
+	owner, ok := k.filenames[object.Kind+"/"+object.Metadata.Name]
+	if ok && owner != filename {
+		if _, _, err := k.get(owner); err == nil {
+			k.mu.Unlock()
+			logger.Errorf("Couldn't import %s: %s %s is already defined in %s", filename, object.Kind, object.Metadata.Name, owner)
+			k.setStatus(filename, "Error", fmt.Sprintf("%s %s is already defined in %s", object.Kind, object.Metadata.Name, owner))
+			return nil, errDuplicate
+		}
+	}
+	k.filenames[object.Kind+"/"+object.Metadata.Name] = filename
+	k.mu.Unlock()
+
+	// keep a cache of filename -> rule name matching
+	k.cache.SetCachedObjects(filename, objects)
+	return objects, nil
+}
+
+// toObject converts a custom resource to an object. The name of the custom resource is used as object name
+func toObject(kind string, u *unstructured.Unstructured) (api.Object, error) {
+	var object api.Object
+	contents, err := json.Marshal(map[string]interface{}{
+		"api":      u.GetAPIVersion(),
+		"kind":     kind,
+		"metadata": map[string]string{"name": u.GetName()},
+		"spec":     u.Object["spec"],
+	})
+	if err != nil {
+		return object, err
+	}
+	return api.DecodeObject(api.FormatJSON, contents)
+}
+
+// SetObjectStatus writes the import status to the status of the custom resource
+func (k *KubernetesStorage) SetObjectStatus(kind, name, state, message string) {
+	k.mu.Lock()
+	filename, ok := k.filenames[kind+"/"+name]
+	k.mu.Unlock()
+	if !ok {
+		return
+	}
+	k.setStatus(filename, state, message)
+}
+
+func (k *KubernetesStorage) setStatus(filename, state, message string) {
+	resource, u, err := k.get(filename)
+	if err != nil {
+		return
+	}
+	status := map[string]interface{}{
+		"state":              state,
+		"message":            message,
+		"observedGeneration": u.GetGeneration(),
+	}
+	if current, ok := u.Object["status"].(map[string]interface{}); ok && reflect.DeepEqual(current, status) {
+		return
+	}
+	u = u.DeepCopy()
+	u.Object["status"] = status
+	_, err = k.client.Resource(gvr(resource)).Namespace(u.GetNamespace()).UpdateStatus(context.Background(), u, metav1.UpdateOptions{})
+	if err != nil {
+		logger.Errorf("Couldn't update status of %s: %s", filename, err)
+	}
+}
+
+func (k *KubernetesStorage) GetCachedObjectName(filename string) ([]*api.Object, error) {
+	return k.cache.GetCachedObjectName(filename)
+}
+func (k *KubernetesStorage) DeleteCachedObject(filename string) error {
+	return k.cache.DeleteCachedObject(filename)
+}
+func (k *KubernetesStorage) ListCachedObjectFilenames() []string {
+	return k.cache.ListCachedObjectFilenames()
+}
+func (k *KubernetesStorage) CountCachedObjectByCondition(condition api.RuleConditions, actions []api.RuleActions) int {
+	return k.cache.CountCachedObjectByCondition(condition, actions)
+}
+func (k *KubernetesStorage) CountCachedJwtRulesByCondition(condition api.RuleConditions, jwtProvider string) int {
+	return k.cache.CountCachedJwtRulesByCondition(condition, jwtProvider)
+}
+func (k *KubernetesStorage) GetCachedRule(name string) *api.Object {
+	return k.cache.GetCachedRule(name)
+}
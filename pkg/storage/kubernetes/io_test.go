@@ -0,0 +1,182 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/storage/util"
+	n "github.com/in4it/roxprox/proto/notification"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func newRule(name, hostname string) *unstructured.Unstructured {
+	return newNamespacedRule("default", name, hostname)
+}
+
+func newNamespacedRule(namespace, name, hostname string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": Group + "/" + Version,
+		"kind":       "Rule",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"hostname": hostname},
+			},
+			"actions": []interface{}{
+				map[string]interface{}{"proxy": map[string]interface{}{"hostname": "target-example.com", "port": int64(443)}},
+			},
+		},
+	}}
+}
+
+func newFakeClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for resource := range Resources {
+		listKinds[gvr(resource)] = resource + "List"
+	}
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+func waitForNotification(queue chan []*n.NotificationRequest_NotificationItem) *n.NotificationRequest_NotificationItem {
+	select {
+	case items := <-queue:
+		return items[0]
+	case <-time.After(5 * time.Second):
+		return nil
+	}
+}
+
+func TestKubernetesStorage(t *testing.T) {
+	client := newFakeClient(newRule("rule1", "rule1.example.com"))
+	k, err := newKubernetesStorage(Config{}, client)
+	if err != nil {
+		t.Errorf("newKubernetesStorage error: %s", err)
+		return
+	}
+	defer k.Stop()
+
+	objects, err := k.ListObjects()
+	if err != nil {
+		t.Errorf("ListObjects error: %s", err)
+		return
+	}
+	if len(objects) != 1 || objects[0].Kind != "rule" || objects[0].Metadata.Name != "rule1" {
+		t.Errorf("Unexpected objects: %+v", objects)
+		return
+	}
+	rule := objects[0].Data.(api.Rule)
+	if rule.Spec.Conditions[0].Hostname != "rule1.example.com" || rule.Spec.Actions[0].Proxy.Port != 443 {
+		t.Errorf("Unexpected rule: %+v", rule)
+		return
+	}
+
+	// status is written back
+	k.SetObjectStatus("rule", "rule1", "Imported", "")
+	u, err := client.Resource(gvr("rules")).Namespace("default").Get(context.Background(), "rule1", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("Get error: %s", err)
+		return
+	}
+	if state, _, _ := unstructured.NestedString(u.Object, "status", "state"); state != "Imported" {
+		t.Errorf("Expected state Imported, got: %s", state)
+		return
+	}
+
+	// add, update and delete are sent to the queue
+	_, err = client.Resource(gvr("rules")).Namespace("default").Create(context.Background(), newRule("rule2", "rule2.example.com"), metav1.CreateOptions{})
+	if err != nil {
+		t.Errorf("Create error: %s", err)
+		return
+	}
+	item := waitForNotification(k.GetQueue())
+	if item == nil || item.Filename != "rules/default/rule2" || item.EventName != util.EventObjectCreated {
+		t.Errorf("Unexpected notification: %+v", item)
+		return
+	}
+	_, err = client.Resource(gvr("rules")).Namespace("default").Update(context.Background(), newRule("rule2", "rule2-changed.example.com"), metav1.UpdateOptions{})
+	if err != nil {
+		t.Errorf("Update error: %s", err)
+		return
+	}
+	item = waitForNotification(k.GetQueue())
+	if item == nil || item.Filename != "rules/default/rule2" || item.EventName != util.EventObjectCreated {
+		t.Errorf("Unexpected notification: %+v", item)
+		return
+	}
+	objects, err = k.GetObject("rules/default/rule2")
+	if err != nil {
+		t.Errorf("GetObject error: %s", err)
+		return
+	}
+	if objects[0].Data.(api.Rule).Spec.Conditions[0].Hostname != "rule2-changed.example.com" {
+		t.Errorf("Unexpected object: %+v", objects[0])
+		return
+	}
+	err = client.Resource(gvr("rules")).Namespace("default").Delete(context.Background(), "rule2", metav1.DeleteOptions{})
+	if err != nil {
+		t.Errorf("Delete error: %s", err)
+		return
+	}
+	item = waitForNotification(k.GetQueue())
+	if item == nil || item.Filename != "rules/default/rule2" || item.EventName != util.EventObjectRemoved {
+		t.Errorf("Unexpected notification: %+v", item)
+		return
+	}
+	if _, err := k.GetCachedObjectName("rules/default/rule2"); err != nil {
+		t.Errorf("Expected deleted object to be in cache until the delete is processed")
+	}
+}
+
+func TestKubernetesStorageDuplicateName(t *testing.T) {
+	client := newFakeClient(newRule("rule1", "rule1.example.com"), newNamespacedRule("other", "rule1", "other.example.com"))
+	k, err := newKubernetesStorage(Config{}, client)
+	if err != nil {
+		t.Errorf("newKubernetesStorage error: %s", err)
+		return
+	}
+	defer k.Stop()
+
+	// the custom resource in the other namespace isn't imported
+	objects, err := k.ListObjects()
+	if err != nil {
+		t.Errorf("ListObjects error: %s", err)
+		return
+	}
+	if len(objects) != 1 || objects[0].Data.(api.Rule).Spec.Conditions[0].Hostname != "rule1.example.com" {
+		t.Errorf("Unexpected objects: %+v", objects)
+		return
+	}
+	if _, err := k.GetObject("rules/other/rule1"); err != errDuplicate {
+		t.Errorf("Expected duplicate error, got: %v", err)
+		return
+	}
+	u, err := client.Resource(gvr("rules")).Namespace("other").Get(context.Background(), "rule1", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("Get error: %s", err)
+		return
+	}
+	if state, _, _ := unstructured.NestedString(u.Object, "status", "state"); state != "Error" {
+		t.Errorf("Expected state Error, got: %s", state)
+		return
+	}
+
+	// the status of the imported custom resource is written to the custom resource in its own namespace
+	k.SetObjectStatus("rule", "rule1", "Imported", "")
+	u, err = client.Resource(gvr("rules")).Namespace("default").Get(context.Background(), "rule1", metav1.GetOptions{})
+	if err != nil {
+		t.Errorf("Get error: %s", err)
+		return
+	}
+	if state, _, _ := unstructured.NestedString(u.Object, "status", "state"); state != "Imported" {
+		t.Errorf("Expected state Imported, got: %s", state)
+	}
+}
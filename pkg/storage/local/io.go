@@ -6,13 +6,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/in4it/roxprox/pkg/api"
 	"github.com/in4it/roxprox/pkg/crypto"
 	"github.com/in4it/roxprox/pkg/storage/util"
 	"github.com/juju/loggo"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -21,24 +22,30 @@ var (
 )
 
 type LocalStorage struct {
-	config Config
-	dir    string
-	cache  map[string][]*api.Object
+	config   Config
+	dir      string
+	cache    map[string][]*api.Object
+	envelope *crypto.Envelope
 }
 type Config struct {
-	Path string
+	Path        string
+	KeyProvider crypto.KeyProvider // encrypts the files in pki/ when set
 }
 
 func NewLocalStorage(config Config) (*LocalStorage, error) {
 	var dir string
 
 	wd, err := os.Getwd()
-	if err == nil {
+	if err == nil && !filepath.IsAbs(config.Path) {
 		dir = wd + "/" + config.Path
 	} else {
 		dir = config.Path
 	}
-	return &LocalStorage{config: config, dir: dir, cache: make(map[string][]*api.Object)}, nil
+	l := &LocalStorage{config: config, dir: dir, cache: make(map[string][]*api.Object)}
+	if config.KeyProvider != nil {
+		l.envelope = crypto.NewEnvelope(config.KeyProvider)
+	}
+	return l, nil
 }
 
 func (l *LocalStorage) GetError(name string) error {
@@ -82,7 +89,7 @@ func (l *LocalStorage) ListObjects() ([]api.Object, error) {
 	}
 
 	for _, f := range files {
-		if strings.HasSuffix(f.Name(), ".yaml") || strings.HasSuffix(f.Name(), ".yml") {
+		if util.IsObjectFile(f.Name()) {
 			object, err := l.GetObject(f.Name())
 			if err != nil {
 				return nil, err
@@ -93,6 +100,24 @@ func (l *LocalStorage) ListObjects() ([]api.Object, error) {
 	return objects, nil
 }
 
+/*
+ * ListObjectFilenames returns the filenames of the objects in the storage path
+ */
+func (l *LocalStorage) ListObjectFilenames() ([]string, error) {
+	var filenames []string
+
+	files, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return filenames, err
+	}
+	for _, f := range files {
+		if util.IsObjectFile(f.Name()) {
+			filenames = append(filenames, f.Name())
+		}
+	}
+	return filenames, nil
+}
+
 /*
  * GetObject gets a single rule from storage and converts contents into rules
  */
@@ -104,82 +129,78 @@ func (l *LocalStorage) GetObject(name string) ([]api.Object, error) {
 	if err != nil {
 		return objects, err
 	}
-	for _, contentsSplitted := range strings.Split(string(contents), "\n---") {
-		if strings.TrimSpace(contentsSplitted) != "" {
-			var object api.Object
-			err = yaml.Unmarshal([]byte(contentsSplitted), &object)
-			if err != nil {
-				return objects, err
-			}
-			switch object.Kind {
-			case "rule":
-				var rule api.Rule
-				err = yaml.Unmarshal([]byte(contentsSplitted), &rule)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = rule
-			case "jwtProvider":
-				var jwtProvider api.JwtProvider
-				err = yaml.Unmarshal([]byte(contentsSplitted), &jwtProvider)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = jwtProvider
-			case "authzFilter":
-				var authzFilter api.AuthzFilter
-				err = yaml.Unmarshal([]byte(contentsSplitted), &authzFilter)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = authzFilter
-			case "tracing":
-				var tracing api.Tracing
-				err = yaml.Unmarshal([]byte(contentsSplitted), &tracing)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = tracing
-			case "compression":
-				var compression api.Compression
-				err = yaml.Unmarshal([]byte(contentsSplitted), &compression)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = compression
-			case "accessLogServer":
-				var accessLogServer api.AccessLogServer
-				err = yaml.Unmarshal([]byte(contentsSplitted), &accessLogServer)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = accessLogServer
-			case "rateLimit":
-				var rateLimit api.RateLimit
-				err = yaml.Unmarshal([]byte(contentsSplitted), &rateLimit)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = rateLimit
-			case "mTLS":
-				var mTLS api.MTLS
-				err = yaml.Unmarshal([]byte(contentsSplitted), &mTLS)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = mTLS
-			default:
-				return objects, errors.New("Rule in wrong format")
-			}
-			objects = append(objects, object)
-			objectsP = append(objectsP, &object)
-		}
+	objects, err = util.ParseObjects(name, contents)
+	if err != nil {
+		return objects, err
+	}
+	for _, object := range objects {
+		object := object
+		objectsP = append(objectsP, &object)
 	}
 	// keep a cache of filename -> rule name matching
 	logger.Debugf("Updating cache for %s (%d objects)", name, len(objectsP))
 	l.cache[name] = objectsP
 	return objects, nil
 }
+
+// ObjectFilename returns the filename of a new object file
+func (l *LocalStorage) ObjectFilename(basename string) string {
+	return basename
+}
+
+// GetObjectVersion returns the version of an object file, or an empty version when the file doesn't exist
+func (l *LocalStorage) GetObjectVersion(filename string) (string, error) {
+	contents, err := ioutil.ReadFile(l.dir + "/" + filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return util.GetContentVersion(contents), nil
+}
+
+// PutObject writes an object file and returns the new version. The write fails with a version conflict when
+// the version is set and the file changed. The file is written to a temporary file first, so the watcher never sees a partial file
+func (l *LocalStorage) PutObject(filename string, contents []byte, version string) (string, error) {
+	if err := l.checkObjectVersion(filename, version); err != nil {
+		return "", err
+	}
+	tmpFilename := l.dir + "/." + filepath.Base(filename) + ".tmp"
+	err := ioutil.WriteFile(tmpFilename, contents, 0644)
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmpFilename, l.dir+"/"+filename)
+	if err != nil {
+		os.Remove(tmpFilename)
+		return "", err
+	}
+	return util.GetContentVersion(contents), nil
+}
+
+// DeleteObject deletes an object file. The delete fails with a version conflict when the version is set and the file changed
+func (l *LocalStorage) DeleteObject(filename string, version string) error {
+	if err := l.checkObjectVersion(filename, version); err != nil {
+		return err
+	}
+	return os.Remove(l.dir + "/" + filename)
+}
+
+func (l *LocalStorage) checkObjectVersion(filename, version string) error {
+	if version == "" {
+		return nil
+	}
+	currentVersion, err := l.GetObjectVersion(filename)
+	if err != nil {
+		return err
+	}
+	if currentVersion != version {
+		return util.ErrVersionConflict
+	}
+	return nil
+}
+
 func (l *LocalStorage) ListCerts() (map[string]string, error) {
 	dirname := l.dir + "/pki/certs/"
 	files, err := ioutil.ReadDir(dirname)
@@ -202,19 +223,11 @@ func (l *LocalStorage) ListCerts() (map[string]string, error) {
 	return certs, nil
 }
 func (l *LocalStorage) GetCert(name string) (string, error) {
-	filename := l.dir + "/pki/certs/" + name + ".crt"
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return "", errNotExist
-	}
-	contents, err := ioutil.ReadFile(filename)
+	contents, err := l.readPKIFile(l.dir + "/pki/certs/" + name + ".crt")
 	return string(contents), err
 }
 func (l *LocalStorage) GetCertBundle(name string) (string, error) {
-	filename := l.dir + "/pki/certs/" + name + "-bundle.crt"
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return "", errNotExist
-	}
-	contents, err := ioutil.ReadFile(filename)
+	contents, err := l.readPKIFile(l.dir + "/pki/certs/" + name + "-bundle.crt")
 	return string(contents), err
 }
 
@@ -228,7 +241,7 @@ func (l *LocalStorage) WriteCert(name string, cert []byte) error {
 	}
 
 	logger.Debugf("writing certundle: %s", filename)
-	return ioutil.WriteFile(filename, cert, 0644)
+	return l.writePKIFile(filename, cert, 0644)
 }
 func (l *LocalStorage) WriteCertBundle(name string, certs []byte) error {
 	filename := l.dir + "/pki/certs/" + name + "-bundle.crt"
@@ -240,53 +253,53 @@ func (l *LocalStorage) WriteCertBundle(name string, certs []byte) error {
 	}
 
 	logger.Debugf("writing cert bundle: %s", filename)
-	return ioutil.WriteFile(filename, certs, 0644)
+	return l.writePKIFile(filename, certs, 0644)
 }
-func (l *LocalStorage) GetPrivateAccountkey() (*rsa.PrivateKey, error) {
-	filename := l.dir + "/pki/accountkeys/private.pem"
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return nil, errNotExist
-	}
-	privateKey, err := ioutil.ReadFile(filename)
+func (l *LocalStorage) GetPrivateAccountkey(account string) (*rsa.PrivateKey, error) {
+	privateKey, err := l.readPKIFile(l.dir + util.AccountKeyPath(account) + "/private.pem")
 	if err != nil {
 		return nil, err
 	}
 	return crypto.GetPrivateKey(privateKey)
 }
-func (l *LocalStorage) GetPublicAccountkey() (*rsa.PublicKey, error) {
-	filename := l.dir + "/pki/accountkeys/public.pem"
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return nil, errNotExist
-	}
-	publicKey, err := ioutil.ReadFile(filename)
+func (l *LocalStorage) GetPublicAccountkey(account string) (*rsa.PublicKey, error) {
+	publicKey, err := l.readPKIFile(l.dir + util.AccountKeyPath(account) + "/public.pem")
 	if err != nil {
 		return nil, err
 	}
 	return crypto.GetPublicKey(publicKey)
 }
-func (l *LocalStorage) CreateAccountKey() error {
+func (l *LocalStorage) CreateAccountKey(account string) error {
 	key, err := crypto.GenerateKey()
 	if err != nil {
 		return err
 	}
 
-	if _, err := os.Stat(l.dir + "/pki/accountkeys"); os.IsNotExist(err) {
-		err = os.MkdirAll(l.dir+"/pki/accountkeys", 0755)
+	return l.WriteAccountKey(account, key)
+}
+func (l *LocalStorage) WriteAccountKey(account string, key *rsa.PrivateKey) error {
+	dirname := l.dir + util.AccountKeyPath(account)
+	if _, err := os.Stat(dirname); os.IsNotExist(err) {
+		err = os.MkdirAll(dirname, 0755)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = crypto.SavePEMKey(l.dir+"/pki/accountkeys/private.pem", key)
-	if err != nil {
-		return err
-	}
+	return l.writeKey(key, dirname+"/private.pem", dirname+"/public.pem")
+}
 
-	err = crypto.SavePublicPEMKey(l.dir+"/pki/accountkeys/public.pem", key.PublicKey)
-	if err != nil {
-		return err
+func (l *LocalStorage) DeleteAccountKey(account string) error {
+	dirname := l.dir + util.AccountKeyPath(account)
+	for _, filename := range []string{dirname + "/private.pem", dirname + "/public.pem"} {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if account != "" {
+		// the directory of the default account has the named accounts
+		os.Remove(dirname)
 	}
-
 	return nil
 }
 
@@ -312,39 +325,85 @@ func (l *LocalStorage) CreateKey(name string) error {
 		}
 	}
 
-	err = crypto.SavePEMKey(l.dir+"/pki/keys/"+name+".pem", key)
+	return l.writeKey(key, l.dir+"/pki/keys/"+name+".pem", l.dir+"/pki/keys/"+name+"-public.pem")
+}
+func (l *LocalStorage) GetPrivateKey(name string) (*rsa.PrivateKey, error) {
+	privateKey, err := l.readPKIFile(l.dir + "/pki/keys/" + name + ".pem")
+	if err != nil {
+		return nil, err
+	}
+	return crypto.GetPrivateKey(privateKey)
+}
+func (l *LocalStorage) GetPrivateKeyPem(name string) (string, error) {
+	privateKey, err := l.readPKIFile(l.dir + "/pki/keys/" + name + ".pem")
+	if err != nil {
+		return "", err
+	}
+	return string(privateKey), nil
+}
+
+func (l *LocalStorage) writeKey(key *rsa.PrivateKey, privateKeyFilename, publicKeyFilename string) error {
+	err := l.writePKIFile(privateKeyFilename, crypto.ConvertToPEMKey(key), 0600)
 	if err != nil {
 		return err
 	}
 
-	err = crypto.SavePublicPEMKey(l.dir+"/pki/keys/"+name+"-public.pem", key.PublicKey)
+	publicKey, err := crypto.ConvertToPublicPEMKey(key.PublicKey)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return l.writePKIFile(publicKeyFilename, publicKey, 0644)
 }
-func (l *LocalStorage) GetPrivateKey(name string) (*rsa.PrivateKey, error) {
-	filename := l.dir + "/pki/keys/" + name + ".pem"
+
+// readPKIFile reads a file in pki/ and decrypts it. Plaintext files are encrypted on first read when a key provider is set
+func (l *LocalStorage) readPKIFile(filename string) ([]byte, error) {
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		return nil, errNotExist
 	}
-	privateKey, err := ioutil.ReadFile(filename)
+	contents, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	return crypto.GetPrivateKey(privateKey)
+	if !crypto.IsEncrypted(contents) {
+		if l.envelope != nil {
+			logger.Infof("Encrypting %s", filename)
+			info, err := os.Stat(filename)
+			if err != nil {
+				return nil, err
+			}
+			err = l.writePKIFile(filename, contents, info.Mode().Perm())
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't encrypt %s: %s", filename, err)
+			}
+		}
+		return contents, nil
+	}
+	if l.envelope == nil {
+		return nil, fmt.Errorf("%s is encrypted, but no key provider is configured", filename)
+	}
+	return l.envelope.Decrypt(contents)
 }
-func (l *LocalStorage) GetPrivateKeyPem(name string) (string, error) {
-	filename := l.dir + "/pki/keys/" + name + ".pem"
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return "", errNotExist
+
+// writePKIFile encrypts the contents when a key provider is set and writes the file
+func (l *LocalStorage) writePKIFile(filename string, contents []byte, perm os.FileMode) error {
+	var err error
+	if l.envelope != nil {
+		contents, err = l.envelope.Encrypt(contents)
+		if err != nil {
+			return err
+		}
 	}
-	privateKey, err := ioutil.ReadFile(filename)
+	// write to a temporary file first, so a plaintext file is never truncated when it's migrated
+	err = ioutil.WriteFile(filename+".tmp", contents, perm)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(privateKey), nil
+	err = os.Chmod(filename+".tmp", perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
 }
 
 func (l *LocalStorage) GetCachedObjectName(filename string) ([]*api.Object, error) {
@@ -362,6 +421,14 @@ func (l *LocalStorage) DeleteCachedObject(filename string) error {
 
 	return fmt.Errorf("Filename %s not found in cache", filename)
 }
+func (l *LocalStorage) ListCachedObjectFilenames() []string {
+	filenames := make([]string, 0, len(l.cache))
+	for filename := range l.cache {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
 func (l *LocalStorage) CountCachedObjectByCondition(condition api.RuleConditions, actions []api.RuleActions) int {
 	count := 0
 	for _, objects := range l.cache {
@@ -0,0 +1,183 @@
+package local
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/in4it/roxprox/pkg/crypto"
+)
+
+func TestEncryptedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "pki")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// keys written before encryption was enabled
+	plaintextStorage, err := NewLocalStorage(Config{Path: dir})
+	if err != nil {
+		t.Errorf("NewLocalStorage error: %s", err)
+		return
+	}
+	if err := plaintextStorage.CreateKey("test1"); err != nil {
+		t.Errorf("CreateKey error: %s", err)
+		return
+	}
+	plaintextKey, err := ioutil.ReadFile(dir + "/pki/keys/test1.pem")
+	if err != nil {
+		t.Errorf("ReadFile error: %s", err)
+		return
+	}
+	if crypto.IsEncrypted(plaintextKey) {
+		t.Errorf("Key is encrypted without a key provider")
+		return
+	}
+
+	keyProvider, err := crypto.NewStaticKeyProvider(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Errorf("NewStaticKeyProvider error: %s", err)
+		return
+	}
+	l, err := NewLocalStorage(Config{Path: dir, KeyProvider: keyProvider})
+	if err != nil {
+		t.Errorf("NewLocalStorage error: %s", err)
+		return
+	}
+
+	// the plaintext key is migrated on first read
+	keyPem, err := l.GetPrivateKeyPem("test1")
+	if err != nil {
+		t.Errorf("GetPrivateKeyPem error: %s", err)
+		return
+	}
+	if keyPem != string(plaintextKey) {
+		t.Errorf("Unexpected key: %s", keyPem)
+		return
+	}
+	migratedKey, err := ioutil.ReadFile(dir + "/pki/keys/test1.pem")
+	if err != nil {
+		t.Errorf("ReadFile error: %s", err)
+		return
+	}
+	if !crypto.IsEncrypted(migratedKey) {
+		t.Errorf("Key not encrypted after first read")
+		return
+	}
+	info, err := os.Stat(dir + "/pki/keys/test1.pem")
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Unexpected permissions after migration: %v (error: %v)", info.Mode(), err)
+	}
+	if _, err := l.GetPrivateKey("test1"); err != nil {
+		t.Errorf("GetPrivateKey error: %s", err)
+		return
+	}
+
+	// new keys and certs are encrypted
+	if err := l.CreateAccountKey("tenant1"); err != nil {
+		t.Errorf("CreateAccountKey error: %s", err)
+		return
+	}
+	accountKey, err := l.GetPrivateAccountkey("tenant1")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	publicKey, err := l.GetPublicAccountkey("tenant1")
+	if err != nil || !publicKey.Equal(&accountKey.PublicKey) {
+		t.Errorf("Public key doesn't match private key (error: %v)", err)
+	}
+	if err := l.WriteCertBundle("test1", []byte("bundle")); err != nil {
+		t.Errorf("WriteCertBundle error: %s", err)
+		return
+	}
+	for _, filename := range []string{"/pki/accountkeys/tenant1/private.pem", "/pki/certs/test1-bundle.crt"} {
+		contents, err := ioutil.ReadFile(dir + filename)
+		if err != nil || !crypto.IsEncrypted(contents) {
+			t.Errorf("%s not encrypted (error: %v)", filename, err)
+		}
+	}
+	bundle, err := l.GetCertBundle("test1")
+	if err != nil || bundle != "bundle" {
+		t.Errorf("GetCertBundle: unexpected result: %s (error: %v)", bundle, err)
+	}
+
+	// encrypted keys can't be read without the key provider
+	if _, err := plaintextStorage.GetPrivateKey("test1"); err == nil {
+		t.Errorf("Expected error when reading an encrypted key without key provider")
+	}
+	if _, err := l.GetPrivateKey("doesnotexist"); err != errNotExist {
+		t.Errorf("Expected errNotExist, got: %v", err)
+	}
+}
+
+func TestGetObjectUnknownKind(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "objects")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	contents := "api: proxy.in4it.io/v1\nkind: rule\nmetadata:\n  name: test1\n---\napi: proxy.in4it.io/v1\nkind: rules\nmetadata:\n  name: test2\n"
+	if err := ioutil.WriteFile(dir+"/rules.yaml", []byte(contents), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+	l, err := NewLocalStorage(Config{Path: dir})
+	if err != nil {
+		t.Errorf("NewLocalStorage error: %s", err)
+		return
+	}
+	_, err = l.GetObject("rules.yaml")
+	if err == nil || err.Error() != `rules.yaml (document 2): unknown kind "rules"` {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestGetObjectJSON(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "objects")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	contents := `[
+  {"api": "proxy.in4it.io/v1", "kind": "rule", "metadata": {"name": "test1"}, "spec": {"conditions": [{"hostname": "test1.example.com"}], "actions": [{"proxy": {"hostname": "target-example.com", "port": 443}}]}},
+  {"api": "proxy.in4it.io/v1", "kind": "jwtProvider", "metadata": {"name": "test-jwt"}, "spec": {"remoteJwks": "https://example.com/.well-known/jwks.json", "issuer": "https://example.com"}}
+]`
+	if err := ioutil.WriteFile(dir+"/rules.json", []byte(contents), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(dir+"/invalid.json", []byte(`{"api": "proxy.in4it.io/v1", "kind": "rule", "metadata": {"name": "test2"}, "spec": {"action": []}}`), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+	l, err := NewLocalStorage(Config{Path: dir})
+	if err != nil {
+		t.Errorf("NewLocalStorage error: %s", err)
+		return
+	}
+	filenames, err := l.ListObjectFilenames()
+	if err != nil || len(filenames) != 2 {
+		t.Errorf("ListObjectFilenames: unexpected result: %v (error: %v)", filenames, err)
+		return
+	}
+	objects, err := l.GetObject("rules.json")
+	if err != nil {
+		t.Errorf("GetObject error: %s", err)
+		return
+	}
+	if len(objects) != 2 || objects[0].Kind != "rule" || objects[1].Metadata.Name != "test-jwt" {
+		t.Errorf("Unexpected objects: %+v", objects)
+	}
+	_, err = l.GetObject("invalid.json")
+	if err == nil || err.Error() != "invalid.json (document 1): spec.action: unknown field" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
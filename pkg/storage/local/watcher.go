@@ -0,0 +1,190 @@
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/in4it/roxprox/pkg/storage/util"
+	n "github.com/in4it/roxprox/proto/notification"
+)
+
+// Watcher polls the storage path and converts file creates, changes and deletes into notifications.
+// A change is only sent once the file didn't change for the debounce duration, so editors
+// writing temporary files or writing files in multiple steps don't trigger partial imports
+type Watcher struct {
+	dir       string
+	interval  time.Duration
+	debounce  time.Duration
+	checksums map[string]string
+	pending   map[string]pendingChange
+	queue     chan []*n.NotificationRequest_NotificationItem
+	stop      chan struct{}
+}
+
+type WatcherConfig struct {
+	Path     string
+	Interval time.Duration
+	Debounce time.Duration
+}
+
+type pendingChange struct {
+	checksum   string
+	deleted    bool
+	lastChange time.Time
+}
+
+func NewWatcher(config WatcherConfig) (*Watcher, error) {
+	var dir string
+
+	wd, err := os.Getwd()
+	if err == nil {
+		dir = wd + "/" + config.Path
+	} else {
+		dir = config.Path
+	}
+
+	w := &Watcher{
+		dir:       dir,
+		interval:  config.Interval,
+		debounce:  config.Debounce,
+		checksums: make(map[string]string),
+		pending:   make(map[string]pendingChange),
+		queue:     make(chan []*n.NotificationRequest_NotificationItem),
+		stop:      make(chan struct{}),
+	}
+
+	// files that exist at startup are already imported
+	checksums, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.checksums = checksums
+
+	return w, nil
+}
+
+func (w *Watcher) GetQueue() chan []*n.NotificationRequest_NotificationItem {
+	return w.queue
+}
+
+// Start polls the storage path every interval and sends notifications to the queue
+func (w *Watcher) Start() {
+	logger.Infof("Watching %s for changes (interval: %s, debounce: %s)", w.dir, w.interval, w.debounce)
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				items, err := w.Poll(time.Now())
+				if err != nil {
+					logger.Errorf("Couldn't poll storage path: %s", err)
+					continue
+				}
+				if len(items) > 0 {
+					w.queue <- items
+				}
+			case <-w.stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (w *Watcher) Stop() {
+	close(w.stop)
+}
+
+// Poll compares the storage path with the last known state and returns the changes that are debounced
+func (w *Watcher) Poll(now time.Time) ([]*n.NotificationRequest_NotificationItem, error) {
+	var items []*n.NotificationRequest_NotificationItem
+
+	checksums, err := w.scan()
+	if err != nil {
+		return items, err
+	}
+
+	// new or changed files
+	for filename, checksum := range checksums {
+		if pending, ok := w.pending[filename]; ok && !pending.deleted && pending.checksum == checksum {
+			continue
+		}
+		if w.checksums[filename] == checksum {
+			delete(w.pending, filename)
+			continue
+		}
+		w.pending[filename] = pendingChange{checksum: checksum, lastChange: now}
+	}
+	// deleted files
+	for filename := range w.checksums {
+		if _, ok := checksums[filename]; ok {
+			continue
+		}
+		if pending, ok := w.pending[filename]; ok && pending.deleted {
+			continue
+		}
+		w.pending[filename] = pendingChange{deleted: true, lastChange: now}
+	}
+	// files that were created and deleted again before they were sent
+	for filename, pending := range w.pending {
+		if _, ok := checksums[filename]; !ok && !pending.deleted {
+			delete(w.pending, filename)
+		}
+		if _, ok := w.checksums[filename]; !ok && pending.deleted {
+			delete(w.pending, filename)
+		}
+	}
+
+	// send changes that didn't change during the debounce duration
+	var filenames []string
+	for filename, pending := range w.pending {
+		if now.Sub(pending.lastChange) >= w.debounce {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		pending := w.pending[filename]
+		if pending.deleted {
+			logger.Debugf("File deleted: %s", filename)
+			items = append(items, &n.NotificationRequest_NotificationItem{Filename: filename, EventName: util.EventObjectRemoved})
+			delete(w.checksums, filename)
+		} else {
+			logger.Debugf("File created or changed: %s", filename)
+			items = append(items, &n.NotificationRequest_NotificationItem{Filename: filename, EventName: util.EventObjectCreated})
+			w.checksums[filename] = pending.checksum
+		}
+		delete(w.pending, filename)
+	}
+
+	return items, nil
+}
+
+func (w *Watcher) scan() (map[string]string, error) {
+	checksums := make(map[string]string)
+
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return checksums, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !util.IsObjectFile(f.Name()) {
+			continue
+		}
+		contents, err := ioutil.ReadFile(w.dir + "/" + f.Name())
+		if err != nil {
+			if os.IsNotExist(err) { // file was removed after reading the directory
+				continue
+			}
+			return checksums, err
+		}
+		sum := sha256.Sum256(contents)
+		checksums[f.Name()] = hex.EncodeToString(sum[:])
+	}
+	return checksums, nil
+}
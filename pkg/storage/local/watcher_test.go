@@ -0,0 +1,126 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	dir, err := ioutil.TempDir(".", "watcher")
+	if err != nil {
+		t.Errorf("Couldn't create temp dir: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(dir+"/existing.yaml", []byte("kind: rule"), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+
+	w, err := NewWatcher(WatcherConfig{Path: dir, Debounce: 2 * time.Second})
+	if err != nil {
+		t.Errorf("Couldn't create watcher: %s", err)
+		return
+	}
+	now := time.Now()
+
+	// existing files are not reported
+	items, err := w.Poll(now)
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected no items, got: %+v", items)
+	}
+
+	// new file, editor swap file and a temp file that is removed before the debounce duration
+	if err := ioutil.WriteFile(dir+"/new.yaml", []byte("kind: rule"), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(dir+"/.new.yaml.swp", []byte("swap"), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(dir+"/tmp.yaml", []byte("kind: rule"), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+	items, err = w.Poll(now.Add(1 * time.Second))
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected no items within debounce duration, got: %+v", items)
+	}
+	if err := os.Remove(dir + "/tmp.yaml"); err != nil {
+		t.Errorf("Couldn't remove file: %s", err)
+		return
+	}
+	// a change within the debounce duration resets the timer
+	if err := ioutil.WriteFile(dir+"/new.yaml", []byte("kind: rule\nmetadata: {}"), 0644); err != nil {
+		t.Errorf("Couldn't write file: %s", err)
+		return
+	}
+	items, err = w.Poll(now.Add(2 * time.Second))
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected no items within debounce duration, got: %+v", items)
+	}
+	items, err = w.Poll(now.Add(4 * time.Second))
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected 1 item, got: %+v", items)
+		return
+	}
+	if items[0].Filename != "new.yaml" || items[0].EventName != "ObjectCreated:Put" {
+		t.Errorf("Unexpected item: %+v", items[0])
+	}
+
+	// delete
+	if err := os.Remove(dir + "/existing.yaml"); err != nil {
+		t.Errorf("Couldn't remove file: %s", err)
+		return
+	}
+	items, err = w.Poll(now.Add(5 * time.Second))
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected no items within debounce duration, got: %+v", items)
+	}
+	items, err = w.Poll(now.Add(8 * time.Second))
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(items) != 1 {
+		t.Errorf("Expected 1 item, got: %+v", items)
+		return
+	}
+	if items[0].Filename != "existing.yaml" || items[0].EventName != "ObjectRemoved:Delete" {
+		t.Errorf("Unexpected item: %+v", items[0])
+	}
+
+	// nothing changed
+	items, err = w.Poll(now.Add(20 * time.Second))
+	if err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected no items, got: %+v", items)
+	}
+}
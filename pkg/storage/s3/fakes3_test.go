@@ -0,0 +1,183 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeS3 is an in-process S3-compatible server (path-style addressing) for the integration tests
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+	server  *httptest.Server
+}
+
+type fakeS3ListBucketResult struct {
+	XMLName     xml.Name               `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string                 `xml:"Name"`
+	Prefix      string                 `xml:"Prefix"`
+	KeyCount    int                    `xml:"KeyCount"`
+	IsTruncated bool                   `xml:"IsTruncated"`
+	Contents    []fakeS3ListBucketItem `xml:"Contents"`
+}
+type fakeS3ListBucketItem struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+	Size int    `xml:"Size"`
+}
+
+func newFakeS3(bucket string, tls bool) *fakeS3 {
+	f := &fakeS3{bucket: bucket, objects: make(map[string][]byte)}
+	if tls {
+		f.server = httptest.NewTLSServer(f)
+	} else {
+		f.server = httptest.NewServer(f)
+	}
+	return f
+}
+
+func (f *fakeS3) Close() {
+	f.server.Close()
+}
+
+func (f *fakeS3) config(prefix string) Config {
+	return Config{
+		Bucket:          f.bucket,
+		Prefix:          prefix,
+		Region:          "us-east-1",
+		Endpoint:        f.server.URL,
+		ForcePathStyle:  true,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+	}
+}
+
+func (f *fakeS3) put(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = data
+}
+
+func (f *fakeS3) get(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket := path
+	key := ""
+	if i := strings.Index(path, "/"); i != -1 {
+		bucket = path[:i]
+		key = path[i+1:]
+	}
+	if bucket != f.bucket {
+		f.writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	if key == "" && r.Method == http.MethodGet {
+		f.listObjects(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			f.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", eTag(data))
+		start, end := 0, len(data)-1
+		status := http.StatusOK
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && len(data) > 0 {
+			parts := strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), "-")
+			start, _ = strconv.Atoi(parts[0])
+			if parts[1] != "" {
+				end, _ = strconv.Atoi(parts[1])
+			}
+			if end > len(data)-1 {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			status = http.StatusPartialContent
+		}
+		body := data[start : end+1]
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			f.writeError(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			if current, ok := f.objects[key]; !ok || eTag(current) != ifMatch {
+				f.writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+		f.objects[key] = data
+		w.Header().Set("ETag", eTag(data))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) listObjects(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	result := fakeS3ListBucketResult{Name: f.bucket, Prefix: prefix}
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Contents = append(result.Contents, fakeS3ListBucketItem{Key: key, ETag: eTag(f.objects[key]), Size: len(f.objects[key])})
+	}
+	result.KeyCount = len(result.Contents)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message></Error>", xml.Header, code, code)
+}
+
+func eTag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
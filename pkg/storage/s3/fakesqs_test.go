@@ -0,0 +1,166 @@
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeSQS is an in-process SQS server (query protocol) for the notification tests
+type fakeSQS struct {
+	mu       sync.Mutex
+	queues   map[string][]*fakeSQSMessage
+	sequence int
+	server   *httptest.Server
+}
+
+type fakeSQSMessage struct {
+	id       string
+	receipt  string
+	body     string
+	inFlight bool
+	received int
+}
+
+func newFakeSQS(queueNames ...string) *fakeSQS {
+	f := &fakeSQS{queues: make(map[string][]*fakeSQSMessage)}
+	for _, name := range queueNames {
+		f.queues[name] = []*fakeSQSMessage{}
+	}
+	f.server = httptest.NewServer(f)
+	return f
+}
+
+func (f *fakeSQS) Close() {
+	f.server.Close()
+}
+
+func (f *fakeSQS) queueURL(name string) string {
+	return f.server.URL + "/queue/" + name
+}
+
+func (f *fakeSQS) send(queueName, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sendLocked(queueName, body)
+}
+
+func (f *fakeSQS) sendLocked(queueName, body string) string {
+	f.sequence++
+	id := fmt.Sprintf("message-%d", f.sequence)
+	f.queues[queueName] = append(f.queues[queueName], &fakeSQSMessage{id: id, body: body})
+	return id
+}
+
+// expireVisibilityTimeout makes in-flight messages visible again
+func (f *fakeSQS) expireVisibilityTimeout() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, messages := range f.queues {
+		for _, message := range messages {
+			message.inFlight = false
+		}
+	}
+}
+
+func (f *fakeSQS) messages(queueName string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var bodies []string
+	for _, message := range f.queues[queueName] {
+		bodies = append(bodies, message.body)
+	}
+	return bodies
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		f.writeError(w, "InvalidParameterValue")
+		return
+	}
+	queueName := strings.TrimPrefix(r.Form.Get("QueueUrl"), f.server.URL+"/queue/")
+	if _, ok := f.queues[queueName]; !ok && r.Form.Get("Action") != "GetQueueUrl" {
+		f.writeError(w, "AWS.SimpleQueueService.NonExistentQueue")
+		return
+	}
+
+	switch r.Form.Get("Action") {
+	case "GetQueueUrl":
+		if _, ok := f.queues[r.Form.Get("QueueName")]; !ok {
+			f.writeError(w, "AWS.SimpleQueueService.NonExistentQueue")
+			return
+		}
+		f.writeResponse(w, "GetQueueUrl", fmt.Sprintf("<QueueUrl>%s</QueueUrl>", f.queueURL(r.Form.Get("QueueName"))))
+	case "ReceiveMessage":
+		max, _ := strconv.Atoi(r.Form.Get("MaxNumberOfMessages"))
+		var result strings.Builder
+		received := 0
+		for _, message := range f.queues[queueName] {
+			if message.inFlight || received >= max {
+				continue
+			}
+			f.sequence++
+			message.inFlight = true
+			message.receipt = fmt.Sprintf("receipt-%d", f.sequence)
+			message.received++
+			received++
+			result.WriteString("<Message><MessageId>" + message.id + "</MessageId><ReceiptHandle>" + message.receipt + "</ReceiptHandle>")
+			result.WriteString(fmt.Sprintf("<Attribute><Name>ApproximateReceiveCount</Name><Value>%d</Value></Attribute>", message.received))
+			result.WriteString("<MD5OfBody>" + md5Hex(message.body) + "</MD5OfBody><Body>")
+			xml.EscapeText(&result, []byte(message.body))
+			result.WriteString("</Body></Message>")
+		}
+		f.writeResponse(w, "ReceiveMessage", result.String())
+	case "SendMessage":
+		body := r.Form.Get("MessageBody")
+		id := f.sendLocked(queueName, body)
+		f.writeResponse(w, "SendMessage", fmt.Sprintf("<MD5OfMessageBody>%s</MD5OfMessageBody><MessageId>%s</MessageId>", md5Hex(body), id))
+	case "DeleteMessage":
+		f.deleteLocked(queueName, r.Form.Get("ReceiptHandle"))
+		f.writeResponse(w, "DeleteMessage", "")
+	case "DeleteMessageBatch":
+		var result strings.Builder
+		for i := 1; r.Form.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.Id", i)) != ""; i++ {
+			id := r.Form.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.Id", i))
+			f.deleteLocked(queueName, r.Form.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.ReceiptHandle", i)))
+			result.WriteString("<DeleteMessageBatchResultEntry><Id>" + id + "</Id></DeleteMessageBatchResultEntry>")
+		}
+		f.writeResponse(w, "DeleteMessageBatch", result.String())
+	default:
+		f.writeError(w, "InvalidAction")
+	}
+}
+
+func (f *fakeSQS) deleteLocked(queueName, receipt string) {
+	for k, message := range f.queues[queueName] {
+		if message.receipt == receipt {
+			f.queues[queueName] = append(f.queues[queueName][:k], f.queues[queueName][k+1:]...)
+			return
+		}
+	}
+}
+
+func (f *fakeSQS) writeResponse(w http.ResponseWriter, action, result string) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, "<%sResponse><%sResult>%s</%sResult><ResponseMetadata><RequestId>request</RequestId></ResponseMetadata></%sResponse>", action, action, result, action, action)
+}
+
+func (f *fakeSQS) writeError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>request</RequestId></ErrorResponse>", code, code)
+}
+
+func md5Hex(body string) string {
+	sum := md5.Sum([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
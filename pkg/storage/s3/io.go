@@ -5,6 +5,8 @@ import (
 	"crypto/rsa"
 	"errors"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -16,7 +18,6 @@ import (
 	"github.com/in4it/roxprox/pkg/crypto"
 	"github.com/in4it/roxprox/pkg/storage/util"
 	"github.com/juju/loggo"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -25,19 +26,20 @@ var (
 )
 
 type S3Storage struct {
-	config Config
-	svc    *s3.S3
-	sess   *session.Session
-	cache  map[string][]*api.Object
+	config   Config
+	svc      *s3.S3
+	sess     *session.Session
+	cache    map[string][]*api.Object
+	envelope *crypto.Envelope
 }
 
 func NewS3Storage(config Config) (*S3Storage, error) {
-	sess, err := session.NewSession(&aws.Config{Region: aws.String(config.Region)})
+	sess, err := newSession(config)
 	if err != nil {
 		logger.Errorf("Couldn't initialize S3: %s", err)
-		return nil, nil
+		return nil, err
 	}
-	svc := s3.New(sess)
+	svc := newS3Client(sess, config)
 
 	// test connection
 	input := &s3.GetObjectInput{
@@ -59,7 +61,11 @@ func NewS3Storage(config Config) (*S3Storage, error) {
 		}
 	}
 
-	return &S3Storage{config: config, svc: svc, sess: sess, cache: make(map[string][]*api.Object)}, nil
+	storage := &S3Storage{config: config, svc: svc, sess: sess, cache: make(map[string][]*api.Object)}
+	if config.KeyProvider != nil {
+		storage.envelope = crypto.NewEnvelope(config.KeyProvider)
+	}
+	return storage, nil
 }
 
 func (l *S3Storage) SetLogLevel(loglevel string) {
@@ -95,7 +101,7 @@ func (s *S3Storage) ListObjects() ([]api.Object, error) {
 		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
 			pageNum++
 			for _, item := range page.Contents {
-				if strings.HasSuffix(aws.StringValue(item.Key), ".yaml") || strings.HasSuffix(aws.StringValue(item.Key), ".yml") {
+				if util.IsObjectFile(aws.StringValue(item.Key)) {
 					object, err := s.GetObject(aws.StringValue(item.Key))
 					if err != nil {
 						logger.Errorf("error while getting rule: %s", err)
@@ -112,11 +118,31 @@ func (s *S3Storage) ListObjects() ([]api.Object, error) {
 	}
 	return objects, nil
 }
+func (s *S3Storage) ListObjectFilenames() ([]string, error) {
+	var filenames []string
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.config.Bucket),
+	}
+	err := s.svc.ListObjectsV2Pages(input,
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, item := range page.Contents {
+				if util.IsObjectFile(aws.StringValue(item.Key)) {
+					filenames = append(filenames, aws.StringValue(item.Key))
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return filenames, err
+	}
+	return filenames, nil
+}
 func (s *S3Storage) GetObject(filename string) ([]api.Object, error) {
 	var objects []api.Object
 	var objectsP []*api.Object
 	contents := aws.NewWriteAtBuffer([]byte{})
-	downloader := s3manager.NewDownloader(s.sess)
+	downloader := s3manager.NewDownloaderWithClient(s.svc)
 	logger.Debugf("GetObject: %s", filename)
 	_, err := downloader.Download(contents,
 		&s3.GetObjectInput{
@@ -126,81 +152,93 @@ func (s *S3Storage) GetObject(filename string) ([]api.Object, error) {
 	if err != nil {
 		return objects, err
 	}
-	for _, contentsSplitted := range strings.Split(string(contents.Bytes()), "\n---") {
-		if strings.TrimSpace(contentsSplitted) != "" {
-			var object api.Object
-			err = yaml.Unmarshal([]byte(contentsSplitted), &object)
-			if err != nil {
-				return objects, err
-			}
-			switch object.Kind {
-			case "rule":
-				var rule api.Rule
-				err = yaml.Unmarshal([]byte(contentsSplitted), &rule)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = rule
-			case "jwtProvider":
-				var jwtProvider api.JwtProvider
-				err = yaml.Unmarshal([]byte(contentsSplitted), &jwtProvider)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = jwtProvider
-			case "authzFilter":
-				var authzFilter api.AuthzFilter
-				err = yaml.Unmarshal([]byte(contentsSplitted), &authzFilter)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = authzFilter
-			case "tracing":
-				var tracing api.Tracing
-				err = yaml.Unmarshal([]byte(contentsSplitted), &tracing)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = tracing
-			case "compression":
-				var compression api.Compression
-				err = yaml.Unmarshal([]byte(contentsSplitted), &compression)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = compression
-			case "accessLogServer":
-				var accessLogServer api.AccessLogServer
-				err = yaml.Unmarshal([]byte(contentsSplitted), &accessLogServer)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = accessLogServer
-			case "rateLimit":
-				var rateLimit api.RateLimit
-				err = yaml.Unmarshal([]byte(contentsSplitted), &rateLimit)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = rateLimit
-			case "mTLS":
-				var mTLS api.MTLS
-				err = yaml.Unmarshal([]byte(contentsSplitted), &mTLS)
-				if err != nil {
-					return objects, err
-				}
-				object.Data = mTLS
-			default:
-				return objects, errors.New("Object in wrong format")
-			}
-			objectsP = append(objectsP, &object)
-			objects = append(objects, object)
-		}
+	objects, err = util.ParseObjects(filename, contents.Bytes())
+	if err != nil {
+		return objects, err
+	}
+	for _, object := range objects {
+		object := object
+		objectsP = append(objectsP, &object)
 	}
 	// keep a cache of filename -> rule name matching
 	s.cache[filename] = objectsP
 	return objects, nil
 }
+
+// ObjectFilename returns the key of a new object file, in the storage prefix
+func (s *S3Storage) ObjectFilename(basename string) string {
+	if s.config.Prefix == "" {
+		return basename
+	}
+	return strings.TrimSuffix(s.config.Prefix, "/") + "/" + basename
+}
+
+// GetObjectVersion returns the version (etag) of an object file, or an empty version when the file doesn't exist
+func (s *S3Storage) GetObjectVersion(filename string) (string, error) {
+	result, err := s.svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(filename),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(result.ETag), `"`), nil
+}
+
+// PutObject uploads an object file and returns the new version. The upload fails with a version conflict when
+// the version is set and the file changed. The version is checked before the upload, and sent as If-Match header
+// for the object stores that support conditional writes
+func (s *S3Storage) PutObject(filename string, contents []byte, version string) (string, error) {
+	if err := s.checkObjectVersion(filename, version); err != nil {
+		return "", err
+	}
+	logger.Debugf("Uploading %s to S3...", filename)
+	req, result := s.svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(filename),
+		Body:   bytes.NewReader(contents),
+	})
+	if version != "" {
+		req.HTTPRequest.Header.Set("If-Match", `"`+version+`"`)
+	}
+	if err := req.Send(); err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusPreconditionFailed {
+			return "", util.ErrVersionConflict
+		}
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(result.ETag), `"`), nil
+}
+
+// DeleteObject deletes an object file. The delete fails with a version conflict when the version is set and the file changed
+func (s *S3Storage) DeleteObject(filename string, version string) error {
+	if err := s.checkObjectVersion(filename, version); err != nil {
+		return err
+	}
+	_, err := s.svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(filename),
+	})
+	return err
+}
+
+func (s *S3Storage) checkObjectVersion(filename, version string) error {
+	if version == "" {
+		return nil
+	}
+	currentVersion, err := s.GetObjectVersion(filename)
+	if err != nil {
+		return err
+	}
+	if currentVersion != version {
+		return util.ErrVersionConflict
+	}
+	return nil
+}
+
 func (s *S3Storage) ListCerts() (map[string]string, error) {
 	var err error
 	certs := make(map[string]string)
@@ -233,160 +271,101 @@ func (s *S3Storage) ListCerts() (map[string]string, error) {
 	return certs, nil
 }
 func (s *S3Storage) GetCert(name string) (string, error) {
-	contents := aws.NewWriteAtBuffer([]byte{})
-	filename := s.config.Prefix + "/pki/certs/" + name + ".crt"
-	downloader := s3manager.NewDownloader(s.sess)
-	_, err := downloader.Download(contents,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.config.Bucket),
-			Key:    aws.String(filename),
-		})
+	contents, err := s.getPKIObject(s.config.Prefix + "/pki/certs/" + name + ".crt")
 	if err != nil {
 		return "", nil
 	}
-	return string(contents.Bytes()), nil
+	return string(contents), nil
 }
 func (s *S3Storage) GetCertBundle(name string) (string, error) {
-	contents := aws.NewWriteAtBuffer([]byte{})
-	filename := s.config.Prefix + "/pki/certs/" + name + "-bundle.crt"
-	downloader := s3manager.NewDownloader(s.sess)
-	_, err := downloader.Download(contents,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.config.Bucket),
-			Key:    aws.String(filename),
-		})
+	contents, err := s.getPKIObject(s.config.Prefix + "/pki/certs/" + name + "-bundle.crt")
 	if err != nil {
 		return "", nil
 	}
-	return string(contents.Bytes()), nil
+	return string(contents), nil
 }
 func (s *S3Storage) WriteCert(name string, cert []byte) error {
-	key := s.config.Prefix + "/pki/certs/" + name + ".crt"
-
-	uploader := s3manager.NewUploader(s.sess)
-
-	logger.Debugf("Uploading %s to S3...", key)
-
-	_, err := uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(s.config.Bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(cert),
-	})
+	return s.putPKIObject(s.config.Prefix+"/pki/certs/"+name+".crt", cert)
+}
+func (s *S3Storage) WriteCertBundle(name string, certs []byte) error {
+	return s.putPKIObject(s.config.Prefix+"/pki/certs/"+name+"-bundle.crt", certs)
+}
+func (s *S3Storage) GetPrivateAccountkey(account string) (*rsa.PrivateKey, error) {
+	privateKey, err := s.getPKIObject(s.config.Prefix + util.AccountKeyPath(account) + "/private.pem")
 	if err != nil {
-		return err
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, errNotExist
+		}
+		return nil, err
 	}
 
-	return nil
+	return crypto.GetPrivateKey(privateKey)
 }
-func (s *S3Storage) WriteCertBundle(name string, certs []byte) error {
-	key := s.config.Prefix + "/pki/certs/" + name + "-bundle.crt"
-
-	uploader := s3manager.NewUploader(s.sess)
-
-	logger.Debugf("Uploading %s to S3...", key)
-
-	_, err := uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(s.config.Bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(certs),
-	})
+func (s *S3Storage) GetPublicAccountkey(account string) (*rsa.PublicKey, error) {
+	publicKey, err := s.getPKIObject(s.config.Prefix + util.AccountKeyPath(account) + "/public.pem")
 	if err != nil {
-		return err
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, errNotExist
+		}
+		return nil, err
 	}
 
-	return nil
+	return crypto.GetPublicKey(publicKey)
 }
-func (s *S3Storage) GetPrivateAccountkey() (*rsa.PrivateKey, error) {
-	privateKey := aws.NewWriteAtBuffer([]byte{})
-	key := s.config.Prefix + "/pki/accountkeys/private.pem"
-	downloader := s3manager.NewDownloader(s.sess)
-	_, err := downloader.Download(privateKey,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.config.Bucket),
-			Key:    aws.String(key),
-		})
+func (s *S3Storage) CreateAccountKey(account string) error {
+	rsaKey, err := crypto.GenerateKey()
 	if err != nil {
-		return nil, nil
+		return err
 	}
-
-	return crypto.GetPrivateKey(privateKey.Bytes())
+	return s.WriteAccountKey(account, rsaKey)
 }
-func (s *S3Storage) GetPublicAccountkey() (*rsa.PublicKey, error) {
-	publicKey := aws.NewWriteAtBuffer([]byte{})
-	key := s.config.Prefix + "/pki/accountkeys/public.pem"
-	downloader := s3manager.NewDownloader(s.sess)
-	_, err := downloader.Download(publicKey,
-		&s3.GetObjectInput{
+func (s *S3Storage) WriteAccountKey(account string, rsaKey *rsa.PrivateKey) error {
+	return s.writeKey(rsaKey, util.AccountKeyPath(account)+"/private.pem", util.AccountKeyPath(account)+"/public.pem")
+}
+func (s *S3Storage) DeleteAccountKey(account string) error {
+	for _, key := range []string{util.AccountKeyPath(account) + "/private.pem", util.AccountKeyPath(account) + "/public.pem"} {
+		_, err := s.svc.DeleteObject(&s3.DeleteObjectInput{
 			Bucket: aws.String(s.config.Bucket),
-			Key:    aws.String(key),
+			Key:    aws.String(s.config.Prefix + key),
 		})
-	if err != nil {
-		return nil, nil
+		if err != nil {
+			return err
+		}
 	}
-
-	return crypto.GetPublicKey(publicKey.Bytes())
-}
-func (s *S3Storage) CreateAccountKey() error {
-	return s.createKey("/pki/accountkeys/private.pem", "/pki/accountkeys/public.pem")
+	return nil
 }
 func (s *S3Storage) CreateKey(name string) error {
 	return s.createKey("/pki/keys/"+name+".pem", "/pki/keys/"+name+"-public.pem")
 
 }
 func (s *S3Storage) GetPrivateKey(name string) (*rsa.PrivateKey, error) {
-	privateKey := aws.NewWriteAtBuffer([]byte{})
-	key := s.config.Prefix + "/pki/keys/" + name + ".pem"
-	downloader := s3manager.NewDownloader(s.sess)
-	_, err := downloader.Download(privateKey,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.config.Bucket),
-			Key:    aws.String(key),
-		})
+	privateKey, err := s.getPKIObject(s.config.Prefix + "/pki/keys/" + name + ".pem")
 	if err != nil {
 		return nil, err
 	}
 
-	return crypto.GetPrivateKey(privateKey.Bytes())
+	return crypto.GetPrivateKey(privateKey)
 }
 func (s *S3Storage) GetPrivateKeyPem(name string) (string, error) {
-	privateKey := aws.NewWriteAtBuffer([]byte{})
-	key := s.config.Prefix + "/pki/keys/" + name + ".pem"
-	downloader := s3manager.NewDownloader(s.sess)
-	_, err := downloader.Download(privateKey,
-		&s3.GetObjectInput{
-			Bucket: aws.String(s.config.Bucket),
-			Key:    aws.String(key),
-		})
+	privateKey, err := s.getPKIObject(s.config.Prefix + "/pki/keys/" + name + ".pem")
 	if err != nil {
 		return "", err
 	}
 
-	return string(privateKey.Bytes()), nil
+	return string(privateKey), nil
 
 }
 func (s *S3Storage) WriteChallenge(name string, data []byte) error {
-	key := s.config.Prefix + "/challenges/" + name + ".json"
-
-	uploader := s3manager.NewUploader(s.sess)
-
-	logger.Debugf("Uploading %s to S3...", key)
-
-	_, err := uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(s.config.Bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
-	})
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.putObject(s.config.Prefix+"/challenges/"+name+".json", data)
 }
 func (s *S3Storage) createKey(privateKeyPath, publicKeyPath string) error {
 	rsaKey, err := crypto.GenerateKey()
 	if err != nil {
 		return err
 	}
+	return s.writeKey(rsaKey, privateKeyPath, publicKeyPath)
+}
+func (s *S3Storage) writeKey(rsaKey *rsa.PrivateKey, privateKeyPath, publicKeyPath string) error {
 	privateKey := crypto.ConvertToPEMKey(rsaKey)
 	publicKey, err := crypto.ConvertToPublicPEMKey(rsaKey.PublicKey)
 	if err != nil {
@@ -394,36 +373,66 @@ func (s *S3Storage) createKey(privateKeyPath, publicKeyPath string) error {
 	}
 
 	// write private key
-	key := s.config.Prefix + privateKeyPath
-
-	uploader := s3manager.NewUploader(s.sess)
-
-	logger.Debugf("Uploading %s to S3...", key)
-
-	_, err = uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(s.config.Bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(privateKey),
-	})
+	err = s.putPKIObject(s.config.Prefix+privateKeyPath, privateKey)
 	if err != nil {
 		return err
 	}
 
 	// write public key
-	key = s.config.Prefix + publicKeyPath
+	return s.putPKIObject(s.config.Prefix+publicKeyPath, publicKey)
+}
+
+// getPKIObject downloads an object in pki/ and decrypts it. Plaintext objects are encrypted on first read when a key provider is set
+func (s *S3Storage) getPKIObject(key string) ([]byte, error) {
+	contents := aws.NewWriteAtBuffer([]byte{})
+	downloader := s3manager.NewDownloaderWithClient(s.svc)
+	_, err := downloader.Download(contents,
+		&s3.GetObjectInput{
+			Bucket: aws.String(s.config.Bucket),
+			Key:    aws.String(key),
+		})
+	if err != nil {
+		return nil, err
+	}
+	if !crypto.IsEncrypted(contents.Bytes()) {
+		if s.envelope != nil {
+			logger.Infof("Encrypting %s", key)
+			err = s.putPKIObject(key, contents.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't encrypt %s: %s", key, err)
+			}
+		}
+		return contents.Bytes(), nil
+	}
+	if s.envelope == nil {
+		return nil, fmt.Errorf("%s is encrypted, but no key provider is configured", key)
+	}
+	return s.envelope.Decrypt(contents.Bytes())
+}
+
+// putPKIObject encrypts the contents when a key provider is set and uploads the object
+func (s *S3Storage) putPKIObject(key string, contents []byte) error {
+	var err error
+	if s.envelope != nil {
+		contents, err = s.envelope.Encrypt(contents)
+		if err != nil {
+			return err
+		}
+	}
+	return s.putObject(key, contents)
+}
+
+func (s *S3Storage) putObject(key string, contents []byte) error {
+	uploader := s3manager.NewUploaderWithClient(s.svc)
 
 	logger.Debugf("Uploading %s to S3...", key)
 
-	_, err = uploader.Upload(&s3manager.UploadInput{
+	_, err := uploader.Upload(&s3manager.UploadInput{
 		Bucket: aws.String(s.config.Bucket),
 		Key:    aws.String(key),
-		Body:   bytes.NewReader(publicKey),
+		Body:   bytes.NewReader(contents),
 	})
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
 
 func (s *S3Storage) GetCachedObjectName(filename string) ([]*api.Object, error) {
@@ -448,6 +457,14 @@ func (s *S3Storage) DeleteCachedObject(filename string) error {
 
 	return fmt.Errorf("Filename %s not found in cache", filename)
 }
+func (s *S3Storage) ListCachedObjectFilenames() []string {
+	filenames := make([]string, 0, len(s.cache))
+	for filename := range s.cache {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
 func (s *S3Storage) CountCachedObjectByCondition(condition api.RuleConditions, actions []api.RuleActions) int {
 	count := 0
 	for _, objects := range s.cache {
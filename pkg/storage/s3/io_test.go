@@ -0,0 +1,338 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/in4it/roxprox/pkg/crypto"
+	"github.com/in4it/roxprox/pkg/storage/util"
+)
+
+const testRule = `api: proxy.in4it.io/v1
+kind: rule
+metadata:
+  name: test1
+spec:
+  conditions:
+    - hostname: test1.example.com
+  actions:
+    - proxy:
+        hostname: target-example.com
+        port: 443
+---
+api: proxy.in4it.io/v1
+kind: jwtProvider
+metadata:
+  name: test-jwt
+spec:
+  remoteJwks: https://example.com/.well-known/jwks.json
+  issuer: https://example.com
+`
+
+func TestS3StorageObjects(t *testing.T) {
+	f := newFakeS3("bucket", false)
+	defer f.Close()
+	f.put("config/test1.yaml", []byte(testRule))
+	f.put("config/README.md", []byte("not an object"))
+
+	s, err := NewS3Storage(f.config("config"))
+	if err != nil {
+		t.Errorf("NewS3Storage error: %s", err)
+		return
+	}
+	objects, err := s.ListObjects()
+	if err != nil {
+		t.Errorf("ListObjects error: %s", err)
+		return
+	}
+	if len(objects) != 2 {
+		t.Errorf("Expected 2 objects, got %d", len(objects))
+		return
+	}
+	if objects[0].Kind != "rule" || objects[0].Metadata.Name != "test1" {
+		t.Errorf("Unexpected object: %+v", objects[0])
+	}
+	if objects[1].Kind != "jwtProvider" || objects[1].Metadata.Name != "test-jwt" {
+		t.Errorf("Unexpected object: %+v", objects[1])
+	}
+	if s.GetCachedRule("test1") == nil {
+		t.Errorf("Rule test1 not found in cache")
+	}
+	if _, err := s.GetObject("config/doesnotexist.yaml"); err == nil {
+		t.Errorf("Expected error for object that doesn't exist")
+	}
+}
+
+func TestS3StorageKeysAndCerts(t *testing.T) {
+	f := newFakeS3("bucket", false)
+	defer f.Close()
+
+	s, err := NewS3Storage(f.config("config"))
+	if err != nil {
+		t.Errorf("NewS3Storage error: %s", err)
+		return
+	}
+
+	// account keys
+	if _, err := s.GetPrivateAccountkey(""); err != errNotExist {
+		t.Errorf("Expected errNotExist, got: %v", err)
+		return
+	}
+	if err := s.CreateAccountKey(""); err != nil {
+		t.Errorf("CreateAccountKey error: %s", err)
+		return
+	}
+	if err := s.CreateAccountKey("tenant1"); err != nil {
+		t.Errorf("CreateAccountKey error: %s", err)
+		return
+	}
+	defaultKey, err := s.GetPrivateAccountkey("")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	tenantKey, err := s.GetPrivateAccountkey("tenant1")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	if defaultKey.Equal(tenantKey) {
+		t.Errorf("Expected different keys for different accounts")
+	}
+	if _, ok := f.get("config/pki/accountkeys/tenant1/private.pem"); !ok {
+		t.Errorf("Account key for tenant1 not found in bucket")
+	}
+	publicKey, err := s.GetPublicAccountkey("tenant1")
+	if err != nil {
+		t.Errorf("GetPublicAccountkey error: %s", err)
+		return
+	}
+	if !publicKey.Equal(&tenantKey.PublicKey) {
+		t.Errorf("Public key doesn't match private key")
+	}
+	if err := s.DeleteAccountKey("tenant1"); err != nil {
+		t.Errorf("DeleteAccountKey error: %s", err)
+		return
+	}
+	if _, err := s.GetPrivateAccountkey("tenant1"); err != errNotExist {
+		t.Errorf("Expected errNotExist after delete, got: %v", err)
+	}
+	if _, err := s.GetPrivateAccountkey(""); err != nil {
+		t.Errorf("Default account key was deleted: %s", err)
+	}
+
+	// keys
+	if err := s.CreateKey("test1"); err != nil {
+		t.Errorf("CreateKey error: %s", err)
+		return
+	}
+	key, err := s.GetPrivateKey("test1")
+	if err != nil {
+		t.Errorf("GetPrivateKey error: %s", err)
+		return
+	}
+	keyPem, err := s.GetPrivateKeyPem("test1")
+	if err != nil {
+		t.Errorf("GetPrivateKeyPem error: %s", err)
+		return
+	}
+	if block, _ := pem.Decode([]byte(keyPem)); block == nil || key == nil {
+		t.Errorf("Private key in wrong format")
+	}
+
+	// certs
+	if err := s.WriteCert("test1", []byte("cert")); err != nil {
+		t.Errorf("WriteCert error: %s", err)
+		return
+	}
+	if err := s.WriteCertBundle("test1", []byte("bundle")); err != nil {
+		t.Errorf("WriteCertBundle error: %s", err)
+		return
+	}
+	cert, err := s.GetCert("test1")
+	if err != nil || cert != "cert" {
+		t.Errorf("GetCert: unexpected result: %s (error: %v)", cert, err)
+	}
+	bundle, err := s.GetCertBundle("test1")
+	if err != nil || bundle != "bundle" {
+		t.Errorf("GetCertBundle: unexpected result: %s (error: %v)", bundle, err)
+	}
+
+	// challenges
+	if err := s.WriteChallenge("test1-test1.example.com", []byte("{}")); err != nil {
+		t.Errorf("WriteChallenge error: %s", err)
+		return
+	}
+	if _, ok := f.get("config/challenges/test1-test1.example.com.json"); !ok {
+		t.Errorf("Challenge not found in bucket")
+	}
+}
+
+func TestS3StorageCABundle(t *testing.T) {
+	f := newFakeS3("bucket", true)
+	defer f.Close()
+
+	// without the CA bundle the server certificate is not trusted
+	if _, err := NewS3Storage(f.config("config")); err == nil {
+		t.Errorf("Expected error when connecting without CA bundle")
+		return
+	}
+
+	caBundle, err := ioutil.TempFile("", "ca-bundle")
+	if err != nil {
+		t.Errorf("Couldn't create CA bundle: %s", err)
+		return
+	}
+	defer os.Remove(caBundle.Name())
+	err = pem.Encode(caBundle, &pem.Block{Type: "CERTIFICATE", Bytes: f.server.Certificate().Raw})
+	caBundle.Close()
+	if err != nil {
+		t.Errorf("Couldn't write CA bundle: %s", err)
+		return
+	}
+
+	config := f.config("config")
+	config.CABundle = caBundle.Name()
+	s, err := NewS3Storage(config)
+	if err != nil {
+		t.Errorf("NewS3Storage error: %s", err)
+		return
+	}
+	if err := s.WriteCert("test1", []byte("cert")); err != nil {
+		t.Errorf("WriteCert error: %s", err)
+	}
+}
+
+func TestS3Poller(t *testing.T) {
+	f := newFakeS3("bucket", false)
+	defer f.Close()
+	f.put("config/test1.yaml", []byte(testRule))
+	f.put("other/test2.yaml", []byte(testRule))
+
+	p, err := NewPoller(f.config("config"), 0)
+	if err != nil {
+		t.Errorf("NewPoller error: %s", err)
+		return
+	}
+	f.put("config/test1.yaml", []byte(testRule+"\n"))
+	f.put("other/test2.yaml", []byte(testRule+"\n"))
+	receiver := &testReceiver{}
+	if err := p.Poll(receiver.receive); err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(receiver.items) != 1 || receiver.items[0].Filename != "config/test1.yaml" || receiver.items[0].EventName != "ObjectCreated:Put" {
+		t.Errorf("Unexpected notifications: %+v", receiver.items)
+	}
+}
+
+func TestS3StorageEncryptedKeys(t *testing.T) {
+	f := newFakeS3("bucket", false)
+	defer f.Close()
+
+	plaintextStorage, err := NewS3Storage(f.config("config"))
+	if err != nil {
+		t.Errorf("NewS3Storage error: %s", err)
+		return
+	}
+	if err := plaintextStorage.CreateAccountKey(""); err != nil {
+		t.Errorf("CreateAccountKey error: %s", err)
+		return
+	}
+
+	keyProvider, err := crypto.NewStaticKeyProvider(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Errorf("NewStaticKeyProvider error: %s", err)
+		return
+	}
+	config := f.config("config")
+	config.KeyProvider = keyProvider
+	s, err := NewS3Storage(config)
+	if err != nil {
+		t.Errorf("NewS3Storage error: %s", err)
+		return
+	}
+
+	// the plaintext account key is migrated on first read
+	if _, err := s.GetPrivateAccountkey(""); err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	if contents, _ := f.get("config/pki/accountkeys/private.pem"); !crypto.IsEncrypted(contents) {
+		t.Errorf("Account key not encrypted after first read")
+	}
+	if _, err := s.GetPrivateAccountkey(""); err != nil {
+		t.Errorf("GetPrivateAccountkey error after migration: %s", err)
+		return
+	}
+
+	// new keys and certs are encrypted
+	if err := s.CreateKey("test1"); err != nil {
+		t.Errorf("CreateKey error: %s", err)
+		return
+	}
+	if err := s.WriteCert("test1", []byte("cert")); err != nil {
+		t.Errorf("WriteCert error: %s", err)
+		return
+	}
+	for _, key := range []string{"config/pki/keys/test1.pem", "config/pki/keys/test1-public.pem", "config/pki/certs/test1.crt"} {
+		if contents, ok := f.get(key); !ok || !crypto.IsEncrypted(contents) {
+			t.Errorf("%s not encrypted", key)
+		}
+	}
+	if _, err := s.GetPrivateKey("test1"); err != nil {
+		t.Errorf("GetPrivateKey error: %s", err)
+	}
+	if cert, err := s.GetCert("test1"); err != nil || cert != "cert" {
+		t.Errorf("GetCert: unexpected result: %s (error: %v)", cert, err)
+	}
+	if _, err := plaintextStorage.GetPrivateKey("test1"); err == nil {
+		t.Errorf("Expected error when reading an encrypted key without key provider")
+	}
+}
+
+func TestS3StorageWriteObjects(t *testing.T) {
+	f := newFakeS3("bucket", false)
+	defer f.Close()
+	s, err := NewS3Storage(f.config("config"))
+	if err != nil {
+		t.Errorf("NewS3Storage error: %s", err)
+		return
+	}
+	filename := s.ObjectFilename("rule-test1.yaml")
+	if filename != "config/rule-test1.yaml" {
+		t.Errorf("Unexpected filename: %s", filename)
+	}
+	version, err := s.GetObjectVersion(filename)
+	if err != nil || version != "" {
+		t.Errorf("Expected empty version for a file that doesn't exist, got: %s (error: %v)", version, err)
+	}
+	version, err = s.PutObject(filename, []byte(testRule), "")
+	if err != nil {
+		t.Errorf("PutObject error: %s", err)
+		return
+	}
+	if currentVersion, err := s.GetObjectVersion(filename); err != nil || currentVersion != version || version == "" {
+		t.Errorf("Unexpected version: %s, expected %s (error: %v)", currentVersion, version, err)
+	}
+	if _, err := s.PutObject(filename, []byte(testRule), "stale"); err != util.ErrVersionConflict {
+		t.Errorf("Expected version conflict, got: %v", err)
+	}
+	// the file changed after the version check
+	f.put(filename, []byte(testRule+"\n"))
+	if _, err := s.PutObject(filename, []byte(testRule), version); err != util.ErrVersionConflict {
+		t.Errorf("Expected version conflict, got: %v", err)
+	}
+	if err := s.DeleteObject(filename, version); err != util.ErrVersionConflict {
+		t.Errorf("Expected version conflict, got: %v", err)
+	}
+	if err := s.DeleteObject(filename, ""); err != nil {
+		t.Errorf("DeleteObject error: %s", err)
+	}
+	if _, ok := f.get(filename); ok {
+		t.Errorf("File wasn't deleted")
+	}
+}
@@ -2,6 +2,7 @@ package s3
 
 import (
 	"context"
+	"fmt"
 
 	n "github.com/in4it/roxprox/proto/notification"
 )
@@ -21,6 +22,11 @@ func (s *NotificationReceiver) SendNotification(ctx context.Context, in *n.Notif
 	return &n.NotificationReply{Result: true}, nil
 }
 
+// Resync is not supported: the receiver only has access to the queue
+func (s *NotificationReceiver) Resync(ctx context.Context, in *n.ResyncRequest) (*n.NotificationReply, error) {
+	return &n.NotificationReply{Result: false}, fmt.Errorf("Resync is not supported by the queue receiver")
+}
+
 func (s *NotificationReceiver) GetQueue() chan []*n.NotificationRequest_NotificationItem {
 	return s.queue
 }
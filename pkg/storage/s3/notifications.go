@@ -2,34 +2,65 @@ package s3
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"net"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	pbN "github.com/in4it/roxprox/proto/notification"
 	"github.com/juju/loggo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 const (
-	serviceDiscovery = "roxprox.roxprox.local"
-	managementPort   = "50051"
+	serviceDiscovery    = "roxprox.roxprox.local"
+	managementPort      = "50051"
+	receiveErrorBackoff = 5 * time.Second
+	defaultPeerTTL      = 5 * time.Minute
+	defaultMaxReceives  = 10
 )
 
 var notificationLogger = loggo.GetLogger("storage.notifications")
 
+var (
+	notificationsRelayedMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "roxprox_notifications_relayed_total",
+		Help: "Notifications relayed to the peers by result",
+	}, []string{"peer", "result"})
+	sqsReceiveErrorsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "roxprox_sqs_receive_errors_total",
+		Help: "Errors receiving messages from the sqs queue",
+	})
+)
+
 type Notifications struct {
-	config               Config
-	queueName            string
-	sqsSvc               *sqs.SQS
-	peers                map[Peer]pbN.NotificationClient
-	eTags                map[string]string
-	storageNotifications []string
+	config             Config
+	queueName          string
+	deadLetterQueueURL string
+	sqsSvc             sqsiface.SQSAPI
+	discovery          PeerDiscovery
+	peerTTL            time.Duration
+	maxReceiveCount    int
+	peersMu            sync.Mutex
+	peers              map[Peer]*peerConnection
+	eTags              map[string]string
+	lookup             func() []Peer
+	now                func() time.Time
+	sourceID           string
+	sequence           uint64
 }
 
 type Peer struct {
@@ -37,104 +68,263 @@ type Peer struct {
 	port    string
 }
 
+func (p Peer) String() string {
+	return p.address + ":" + p.port
+}
+
+// PeerStats contains the fan-out statistics of a peer
+type PeerStats struct {
+	Peer      string
+	LastSeen  time.Time
+	Sent      uint64
+	Failed    uint64
+	LastError string
+}
+
+type peerConnection struct {
+	conn   *grpc.ClientConn
+	client pbN.NotificationClient
+	stats  PeerStats
+}
+
 func NewNotifications(config Config) *Notifications {
-	storageNotifications := []string{serviceDiscovery}
-	for _, v := range strings.Split(config.StorageNotifications, ",") {
-		storageNotifications = append(storageNotifications, v)
+	peerTTL := config.PeerTTL
+	if peerTTL == 0 {
+		peerTTL = defaultPeerTTL
+	}
+	maxReceiveCount := config.MaxReceiveCount
+	if maxReceiveCount == 0 {
+		maxReceiveCount = defaultMaxReceives
 	}
-	return &Notifications{
-		config:               config,
-		queueName:            config.Bucket + "-notifications",
-		peers:                make(map[Peer]pbN.NotificationClient),
-		eTags:                make(map[string]string),
-		storageNotifications: storageNotifications,
+	n := &Notifications{
+		config:          config,
+		queueName:       config.Bucket + "-notifications",
+		discovery:       newDefaultPeerDiscovery(config.StorageNotifications),
+		peerTTL:         peerTTL,
+		maxReceiveCount: maxReceiveCount,
+		peers:           make(map[Peer]*peerConnection),
+		eTags:           make(map[string]string),
+		now:             time.Now,
+		sourceID:        newSourceID(),
 	}
+	n.lookup = n.lookupPeers
+	return n
+}
+
+// newSourceID returns a unique id for this instance, receivers use it to keep track of the sequence numbers
+func newSourceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "roxprox"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hostname + "-" + hex.EncodeToString(b)
 }
 
 func (n *Notifications) StartQueue() error {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(n.config.Region)},
-	)
+	if n.config.PeerDiscovery != "" {
+		discovery, err := NewPeerDiscovery(n.config.PeerDiscovery)
+		if err != nil {
+			return err
+		}
+		n.discovery = discovery
+	}
+	logger.Infof("Using peer discovery: %s", n.discovery)
+
+	queueURL, err := n.initQueue()
 	if err != nil {
 		return err
 	}
 
+	logger.Infof("Starting SQS queue (%s)", queueURL)
+	go n.RunSQSQueue(queueURL)
+
+	return nil
+}
+
+func (n *Notifications) initQueue() (string, error) {
+	sess, err := newSession(n.config)
+	if err != nil {
+		return "", err
+	}
+
 	// Create a SQS service client.
-	n.sqsSvc = sqs.New(sess)
+	sqsConfig := aws.NewConfig()
+	if n.config.SQSEndpoint != "" {
+		sqsConfig = sqsConfig.WithEndpoint(n.config.SQSEndpoint)
+	}
+	n.sqsSvc = sqs.New(sess, sqsConfig)
 
+	return n.getQueueURLs()
+}
+
+// getQueueURLs returns the queue url and looks up the optional dead-letter queue (<bucket>-notifications-deadletter)
+func (n *Notifications) getQueueURLs() (string, error) {
 	resultURL, err := n.sqsSvc.GetQueueUrl(&sqs.GetQueueUrlInput{
 		QueueName: aws.String(n.queueName),
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	logger.Infof("Starting SQS queue (%s)", aws.StringValue(resultURL.QueueUrl))
-	go n.RunSQSQueue(aws.StringValue(resultURL.QueueUrl))
+	resultDeadLetterURL, err := n.sqsSvc.GetQueueUrl(&sqs.GetQueueUrlInput{
+		QueueName: aws.String(n.queueName + "-deadletter"),
+	})
+	if err != nil {
+		logger.Infof("No dead-letter queue found (%s-deadletter): messages in the wrong format will be logged and removed", n.queueName)
+	} else {
+		n.deadLetterQueueURL = aws.StringValue(resultDeadLetterURL.QueueUrl)
+	}
 
-	return nil
+	return aws.StringValue(resultURL.QueueUrl), nil
 }
 
 func (n *Notifications) RunSQSQueue(queueURL string) {
 	for {
-		result, err := n.sqsSvc.ReceiveMessage(&sqs.ReceiveMessageInput{
-			QueueUrl: aws.String(queueURL),
-			AttributeNames: aws.StringSlice([]string{
-				"SentTimestamp",
-			}),
-			MaxNumberOfMessages: aws.Int64(10),
-			MessageAttributeNames: aws.StringSlice([]string{
-				"All",
-			}),
-			WaitTimeSeconds: aws.Int64(20),
-		})
+		err := n.receiveMessages(queueURL)
 		if err != nil {
-			notificationLogger.Errorf("ReceiveMessage error: %s", err)
+			notificationLogger.Errorf("%s", err)
+			time.Sleep(receiveErrorBackoff)
 		}
+	}
+}
 
-		if len(result.Messages) > 0 {
-			var req pbN.NotificationRequest
-			logger.Infof("Received %d messages from sqs.\n", len(result.Messages))
-			for _, v := range result.Messages {
-				var body S3NotificationBody
-				err := json.Unmarshal([]byte(aws.StringValue(v.Body)), &body)
-				if err != nil {
-					notificationLogger.Errorf("Body unmarshal error: %s", err)
-				}
-
-				_, err = n.sqsSvc.DeleteMessage(&sqs.DeleteMessageInput{
-					QueueUrl:      aws.String(queueURL),
-					ReceiptHandle: v.ReceiptHandle,
-				})
-				if err != nil {
-					notificationLogger.Errorf("DeleteMessage error: %s", err)
-				}
-				// relay message using body.s3.object.key
-				// using second grpc interface (possible with service to service communication + service discovery)
-				if len(body.Records) > 0 {
-					// check eTag
-					if !n.eTagMatches(body.Records[0].S3.Object.Key, body.Records[0].S3.Object.ETag) {
-						req.NotificationItem = append(req.NotificationItem, &pbN.NotificationRequest_NotificationItem{
-							Filename:  body.Records[0].S3.Object.Key,
-							EventName: body.Records[0].EventName,
-						})
-						n.eTags[body.Records[0].S3.Object.Key] = body.Records[0].S3.Object.ETag
-					} else {
-						logger.Debugf("eTag of s3 object %s is the same: skipping notification", body.Records[0].S3.Object.Key)
-					}
-				}
+// receiveMessages receives messages from the queue and relays all records to the peers.
+// Messages are only deleted after the peers acknowledged the notification, otherwise they're
+// retried after the visibility timeout
+func (n *Notifications) receiveMessages(queueURL string) error {
+	result, err := n.sqsSvc.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl: aws.String(queueURL),
+		AttributeNames: aws.StringSlice([]string{
+			"SentTimestamp",
+			sqs.MessageSystemAttributeNameApproximateReceiveCount,
+		}),
+		MaxNumberOfMessages: aws.Int64(10),
+		MessageAttributeNames: aws.StringSlice([]string{
+			"All",
+		}),
+		WaitTimeSeconds: aws.Int64(20),
+	})
+	if err != nil {
+		sqsReceiveErrorsMetric.Inc()
+		return fmt.Errorf("ReceiveMessage error: %s", err)
+	}
+
+	if len(result.Messages) == 0 {
+		return nil
+	}
+
+	var (
+		req              pbN.NotificationRequest
+		messagesToDelete []*sqs.Message
+		eTags            = make(map[string]string)
+	)
+	logger.Infof("Received %d messages from sqs.\n", len(result.Messages))
+	for _, v := range result.Messages {
+		var body S3NotificationBody
+		err := json.Unmarshal([]byte(aws.StringValue(v.Body)), &body)
+		if err != nil {
+			notificationLogger.Errorf("Body unmarshal error: %s", err)
+			err = n.deadLetter(queueURL, v, "in the wrong format")
+			if err != nil {
+				notificationLogger.Errorf("Couldn't move message to dead-letter queue: %s", err)
 			}
-			if len(req.NotificationItem) > 0 {
-				logger.Debugf("SendNotificationToPeers: %+v", req.NotificationItem)
-				err = n.SendNotificationToPeers(req, n.lookupPeers(), 5)
-				if err != nil {
-					notificationLogger.Errorf("SendNotificationToPeers error: %s", err)
-				}
-			} else {
-				logger.Debugf("No notifications to send to peers")
+			continue
+		}
+		// relay message using body.s3.object.key
+		// using second grpc interface (possible with service to service communication + service discovery)
+		for _, record := range body.Records {
+			// check eTag
+			if n.eTagMatches(record.S3.Object.Key, record.S3.Object.ETag) {
+				logger.Debugf("eTag of s3 object %s is the same: skipping notification", record.S3.Object.Key)
+				continue
 			}
+			req.NotificationItem = append(req.NotificationItem, &pbN.NotificationRequest_NotificationItem{
+				Filename:  record.S3.Object.Key,
+				EventName: record.EventName,
+			})
+			eTags[record.S3.Object.Key] = record.S3.Object.ETag
+		}
+		messagesToDelete = append(messagesToDelete, v)
+	}
+
+	if len(req.NotificationItem) > 0 {
+		logger.Debugf("SendNotificationToPeers: %+v", req.NotificationItem)
+		err = n.SendNotificationToPeers(req, n.lookup(), 5)
+		if err != nil {
+			n.deadLetterExhausted(queueURL, messagesToDelete)
+			return fmt.Errorf("SendNotificationToPeers error: %s (messages will be retried)", err)
 		}
+		// only mark the objects as imported when all peers received the notification
+		for key, eTag := range eTags {
+			n.eTags[key] = eTag
+		}
+	} else {
+		logger.Debugf("No notifications to send to peers")
 	}
+
+	return n.deleteMessages(queueURL, messagesToDelete)
+}
+
+func (n *Notifications) deleteMessages(queueURL string, messages []*sqs.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	var entries []*sqs.DeleteMessageBatchRequestEntry
+	for k, v := range messages {
+		entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(fmt.Sprintf("%d", k)),
+			ReceiptHandle: v.ReceiptHandle,
+		})
+	}
+	result, err := n.sqsSvc.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(queueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteMessageBatch error: %s", err)
+	}
+	for _, failed := range result.Failed {
+		notificationLogger.Errorf("DeleteMessage error: %s (id: %s)", aws.StringValue(failed.Message), aws.StringValue(failed.Id))
+	}
+	return nil
+}
+
+// deadLetterExhausted moves the messages that couldn't be relayed after the max receive count to the dead-letter queue,
+// so they aren't redelivered forever. The other messages are retried after the visibility timeout
+func (n *Notifications) deadLetterExhausted(queueURL string, messages []*sqs.Message) {
+	for _, message := range messages {
+		receiveCount, err := strconv.Atoi(aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+		if err != nil || receiveCount < n.maxReceiveCount {
+			continue
+		}
+		err = n.deadLetter(queueURL, message, fmt.Sprintf("that couldn't be relayed after %d receives", receiveCount))
+		if err != nil {
+			notificationLogger.Errorf("Couldn't move message to dead-letter queue: %s", err)
+		}
+	}
+}
+
+// deadLetter moves a message that can't be processed to the dead-letter queue
+func (n *Notifications) deadLetter(queueURL string, message *sqs.Message, reason string) error {
+	if n.deadLetterQueueURL == "" {
+		notificationLogger.Errorf("Removing message %s (id: %s): %s", reason, aws.StringValue(message.MessageId), aws.StringValue(message.Body))
+	} else {
+		_, err := n.sqsSvc.SendMessage(&sqs.SendMessageInput{
+			QueueUrl:    aws.String(n.deadLetterQueueURL),
+			MessageBody: message.Body,
+		})
+		if err != nil {
+			return err
+		}
+		notificationLogger.Infof("Moved message %s to dead-letter queue (id: %s)", reason, aws.StringValue(message.MessageId))
+	}
+	_, err := n.sqsSvc.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(queueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	})
+	return err
 }
 
 func (n *Notifications) eTagMatches(key, eTag string) bool {
@@ -147,51 +337,125 @@ func (n *Notifications) eTagMatches(key, eTag string) bool {
 	return false
 }
 
+// SendNotificationToPeers sends the notification to all peers. An error is returned when one of the peers
+// didn't acknowledge the notification, after the notification has been sent to the other peers
 func (n *Notifications) SendNotificationToPeers(req pbN.NotificationRequest, peerAddresses []Peer, timeout int) error {
+	var errors []string
+	req.SourceId = n.sourceID
+	req.Sequence = atomic.AddUint64(&n.sequence, 1)
 	for _, v := range peerAddresses {
-		if _, ok := n.peers[v]; !ok {
-			// Set up a connection to the server.
-			conn, err := grpc.Dial(v.address+":"+v.port, grpc.WithInsecure())
-			if err != nil {
-				return err
-			}
-			n.peers[v] = pbN.NewNotificationClient(conn)
-			logger.Debugf("set up new grpc management connection with %s", v.address)
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-		defer cancel()
-		r, err := n.peers[v].SendNotification(ctx, &req)
+		err := n.sendNotificationToPeer(req, v, timeout)
+		n.recordResult(v, err)
 		if err != nil {
-			return fmt.Errorf("SendNotification error: %s", err)
-		}
-		if !r.GetResult() {
-			return fmt.Errorf("SendNotification error: %s", err)
+			errors = append(errors, fmt.Sprintf("%s: %s", v, err))
+			continue
 		}
-
 		logger.Debugf("Sent notification to %s", v.address)
 	}
+	if len(errors) > 0 {
+		return fmt.Errorf("SendNotification error: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
 
+func (n *Notifications) sendNotificationToPeer(req pbN.NotificationRequest, peer Peer, timeout int) error {
+	client, err := n.getClient(peer)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	r, err := client.SendNotification(ctx, &req)
+	if err != nil {
+		return err
+	}
+	if !r.GetResult() {
+		return fmt.Errorf("notification not acknowledged by %s", peer.address)
+	}
 	return nil
 }
 
-func (n *Notifications) lookupPeers() []Peer {
-	peers := []Peer{}
-	for _, storageNotification := range n.storageNotifications {
-		ips, err := net.LookupIP(storageNotification)
+func (n *Notifications) getClient(peer Peer) (pbN.NotificationClient, error) {
+	n.peersMu.Lock()
+	defer n.peersMu.Unlock()
+	p := n.getPeer(peer)
+	if p.client == nil {
+		// Set up a connection to the server.
+		transportCredentials := grpc.WithInsecure()
+		if n.config.PeerTLSConfig != nil {
+			transportCredentials = grpc.WithTransportCredentials(credentials.NewTLS(n.config.PeerTLSConfig))
+		}
+		conn, err := grpc.Dial(peer.String(), transportCredentials)
 		if err != nil {
-			logger.Infof("LookupPeers: couldn't do DNS lookup on %s (using local only instead)", serviceDiscovery)
-			peers = append(peers, Peer{
-				address: "127.0.0.1",
-				port:    managementPort,
-			})
-			return peers
+			return nil, err
 		}
-		for _, ip := range ips {
-			peers = append(peers, Peer{
-				address: ip.String(),
-				port:    managementPort,
-			})
+		p.conn = conn
+		p.client = pbN.NewNotificationClient(conn)
+		logger.Debugf("set up new grpc management connection with %s", peer.address)
+	}
+	return p.client, nil
+}
+
+// getPeer returns the peer connection (peersMu must be locked)
+func (n *Notifications) getPeer(peer Peer) *peerConnection {
+	if _, ok := n.peers[peer]; !ok {
+		n.peers[peer] = &peerConnection{stats: PeerStats{Peer: peer.String()}}
+	}
+	return n.peers[peer]
+}
+
+func (n *Notifications) recordResult(peer Peer, err error) {
+	n.peersMu.Lock()
+	defer n.peersMu.Unlock()
+	p := n.getPeer(peer)
+	if err != nil {
+		p.stats.Failed++
+		p.stats.LastError = err.Error()
+		notificationsRelayedMetric.WithLabelValues(peer.String(), "failed").Inc()
+		return
+	}
+	p.stats.Sent++
+	notificationsRelayedMetric.WithLabelValues(peer.String(), "sent").Inc()
+}
+
+// GetPeerStats returns the fan-out statistics of the known peers
+func (n *Notifications) GetPeerStats() []PeerStats {
+	n.peersMu.Lock()
+	defer n.peersMu.Unlock()
+	stats := []PeerStats{}
+	for _, p := range n.peers {
+		stats = append(stats, p.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Peer < stats[j].Peer })
+	return stats
+}
+
+// lookupPeers returns the discovered peers. Peers that are no longer discovered are kept until they
+// weren't seen for the peer TTL, so a failing lookup doesn't stop the notifications to the known peers
+func (n *Notifications) lookupPeers() []Peer {
+	discovered, err := n.discovery.Peers()
+	if err != nil {
+		notificationLogger.Errorf("LookupPeers: %s", err)
+	}
+
+	n.peersMu.Lock()
+	defer n.peersMu.Unlock()
+	now := n.now()
+	for _, peer := range discovered {
+		n.getPeer(peer).stats.LastSeen = now
+	}
+	peers := []Peer{}
+	for peer, p := range n.peers {
+		if now.Sub(p.stats.LastSeen) > n.peerTTL {
+			notificationLogger.Infof("LookupPeers: removing peer %s (last seen: %s)", peer, p.stats.LastSeen)
+			if p.conn != nil {
+				p.conn.Close()
+			}
+			delete(n.peers, peer)
+			continue
 		}
+		peers = append(peers, peer)
 	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].String() < peers[j].String() })
 	return peers
 }
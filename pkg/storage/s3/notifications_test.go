@@ -1,10 +1,15 @@
 package s3
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"sync"
 	"testing"
 
 	pbN "github.com/in4it/roxprox/proto/notification"
+	"google.golang.org/grpc"
 )
 
 func TestLookupPeers(t *testing.T) {
@@ -46,3 +51,174 @@ func TestSendNotificationToPeers(t *testing.T) {
 		t.Errorf("Expected error")
 	}
 }
+
+type fakePeer struct {
+	mu       sync.Mutex
+	fail     bool
+	received []*pbN.NotificationRequest_NotificationItem
+	requests []*pbN.NotificationRequest
+}
+
+func (f *fakePeer) SendNotification(ctx context.Context, in *pbN.NotificationRequest) (*pbN.NotificationReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return nil, fmt.Errorf("peer unavailable")
+	}
+	f.received = append(f.received, in.GetNotificationItem()...)
+	f.requests = append(f.requests, in)
+	return &pbN.NotificationReply{Result: true}, nil
+}
+
+func (f *fakePeer) Resync(ctx context.Context, in *pbN.ResyncRequest) (*pbN.NotificationReply, error) {
+	return &pbN.NotificationReply{Result: true}, nil
+}
+
+func startFakePeer(t *testing.T, peer *fakePeer) (Peer, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Couldn't listen: %s", err)
+		return Peer{}, func() {}
+	}
+	s := grpc.NewServer()
+	pbN.RegisterNotificationServer(s, peer)
+	go s.Serve(lis)
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	return Peer{address: "127.0.0.1", port: port}, s.Stop
+}
+
+func s3EventBody(records ...[2]string) string {
+	var body S3NotificationBody
+	for _, record := range records {
+		body.Records = append(body.Records, Records{
+			EventName: "ObjectCreated:Put",
+			S3:        S3{Object: Object{Key: record[0], ETag: record[1]}},
+		})
+	}
+	out, _ := json.Marshal(body)
+	return string(out)
+}
+
+func TestReceiveMessages(t *testing.T) {
+	sqsServer := newFakeSQS("bucket-notifications", "bucket-notifications-deadletter")
+	defer sqsServer.Close()
+	peer := &fakePeer{fail: true}
+	peerAddress, stop := startFakePeer(t, peer)
+	defer stop()
+
+	n := NewNotifications(Config{
+		Bucket:          "bucket",
+		Region:          "us-east-1",
+		SQSEndpoint:     sqsServer.server.URL,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+	})
+	n.lookup = func() []Peer {
+		return []Peer{peerAddress}
+	}
+	queueURL, err := n.initQueue()
+	if err != nil {
+		t.Errorf("initQueue error: %s", err)
+		return
+	}
+
+	sqsServer.send("bucket-notifications", s3EventBody([2]string{"rule1.yaml", "etag1"}, [2]string{"rule2.yaml", "etag2"}))
+	sqsServer.send("bucket-notifications", s3EventBody([2]string{"rule3.yaml", "etag3"}))
+	sqsServer.send("bucket-notifications", "not json")
+
+	// peer is unavailable: messages are kept, poison message is moved to the dead-letter queue
+	if err := n.receiveMessages(queueURL); err == nil {
+		t.Errorf("Expected error when peer is unavailable")
+		return
+	}
+	if messages := sqsServer.messages("bucket-notifications"); len(messages) != 2 {
+		t.Errorf("Expected 2 messages in queue, got %d", len(messages))
+		return
+	}
+	if messages := sqsServer.messages("bucket-notifications-deadletter"); len(messages) != 1 || messages[0] != "not json" {
+		t.Errorf("Expected poison message in dead-letter queue, got: %+v", messages)
+		return
+	}
+
+	// retry after visibility timeout
+	peer.fail = false
+	sqsServer.expireVisibilityTimeout()
+	if err := n.receiveMessages(queueURL); err != nil {
+		t.Errorf("receiveMessages error: %s", err)
+		return
+	}
+	if len(peer.received) != 3 {
+		t.Errorf("Expected 3 notifications (all records), got: %+v", peer.received)
+		return
+	}
+	if messages := sqsServer.messages("bucket-notifications"); len(messages) != 0 {
+		t.Errorf("Expected empty queue, got %d messages", len(messages))
+		return
+	}
+
+	// same eTag: message is removed without notification
+	sqsServer.send("bucket-notifications", s3EventBody([2]string{"rule1.yaml", "etag1"}))
+	if err := n.receiveMessages(queueURL); err != nil {
+		t.Errorf("receiveMessages error: %s", err)
+		return
+	}
+	if len(peer.received) != 3 {
+		t.Errorf("Expected no new notifications, got: %+v", peer.received)
+	}
+	if messages := sqsServer.messages("bucket-notifications"); len(messages) != 0 {
+		t.Errorf("Expected empty queue, got %d messages", len(messages))
+	}
+
+	// receive errors are returned
+	if err := n.receiveMessages(sqsServer.server.URL + "/queue/doesnotexist"); err == nil {
+		t.Errorf("Expected error for ReceiveMessage on queue that doesn't exist")
+	}
+}
+
+func TestReceiveMessagesMaxReceiveCount(t *testing.T) {
+	sqsServer := newFakeSQS("bucket-notifications", "bucket-notifications-deadletter")
+	defer sqsServer.Close()
+	peer := &fakePeer{fail: true}
+	peerAddress, stop := startFakePeer(t, peer)
+	defer stop()
+
+	n := NewNotifications(Config{
+		Bucket:          "bucket",
+		Region:          "us-east-1",
+		SQSEndpoint:     sqsServer.server.URL,
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		MaxReceiveCount: 2,
+	})
+	n.lookup = func() []Peer {
+		return []Peer{peerAddress}
+	}
+	queueURL, err := n.initQueue()
+	if err != nil {
+		t.Errorf("initQueue error: %s", err)
+		return
+	}
+
+	body := s3EventBody([2]string{"rule1.yaml", "etag1"})
+	sqsServer.send("bucket-notifications", body)
+	// the message is kept after the first failed relay, and moved to the dead-letter queue after the second
+	if err := n.receiveMessages(queueURL); err == nil {
+		t.Errorf("Expected error when peer is unavailable")
+		return
+	}
+	if messages := sqsServer.messages("bucket-notifications"); len(messages) != 1 {
+		t.Errorf("Expected 1 message in queue, got %d", len(messages))
+		return
+	}
+	sqsServer.expireVisibilityTimeout()
+	if err := n.receiveMessages(queueURL); err == nil {
+		t.Errorf("Expected error when peer is unavailable")
+		return
+	}
+	if messages := sqsServer.messages("bucket-notifications"); len(messages) != 0 {
+		t.Errorf("Expected empty queue, got %d messages", len(messages))
+	}
+	if messages := sqsServer.messages("bucket-notifications-deadletter"); len(messages) != 1 || messages[0] != body {
+		t.Errorf("Expected message in dead-letter queue, got: %+v", messages)
+	}
+}
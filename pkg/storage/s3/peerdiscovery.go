@@ -0,0 +1,229 @@
+package s3
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PeerDiscovery returns the roxprox instances that need to receive the notifications
+type PeerDiscovery interface {
+	Peers() ([]Peer, error)
+	String() string
+}
+
+// NewPeerDiscovery parses a comma separated list of discovery methods:
+//
+//	static:host[:port]   a fixed peer
+//	dns:name[:port]      A/AAAA lookup
+//	srv:name             SRV lookup (the SRV records carry the ports)
+//	file:path            a file with one host[:port] per line
+func NewPeerDiscovery(spec string) (PeerDiscovery, error) {
+	var discoveries MultiPeerDiscovery
+	for _, v := range strings.Split(spec, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Peer discovery in wrong format: %s (expected type:value)", v)
+		}
+		switch parts[0] {
+		case "static":
+			peer, err := parsePeer(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			discoveries = append(discoveries, &StaticPeerDiscovery{peers: []Peer{peer}})
+		case "dns":
+			peer, err := parsePeer(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			discoveries = append(discoveries, &DNSPeerDiscovery{name: peer.address, port: peer.port})
+		case "srv":
+			discoveries = append(discoveries, &SRVPeerDiscovery{name: parts[1]})
+		case "file":
+			discoveries = append(discoveries, &FilePeerDiscovery{path: parts[1]})
+		default:
+			return nil, fmt.Errorf("Unknown peer discovery type: %s", parts[0])
+		}
+	}
+	if len(discoveries) == 0 {
+		return nil, fmt.Errorf("No peer discovery specified")
+	}
+	return discoveries, nil
+}
+
+// newDefaultPeerDiscovery returns the discovery that is used when no peer discovery is configured:
+// DNS lookups on the service discovery name and the storage notifications, with a fallback to the local instance
+func newDefaultPeerDiscovery(storageNotifications string) PeerDiscovery {
+	discoveries := MultiPeerDiscovery{&DNSPeerDiscovery{name: serviceDiscovery, port: managementPort}}
+	for _, name := range strings.Split(storageNotifications, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			discoveries = append(discoveries, &DNSPeerDiscovery{name: name, port: managementPort})
+		}
+	}
+	return &FallbackPeerDiscovery{
+		discovery: discoveries,
+		fallback:  &StaticPeerDiscovery{peers: []Peer{{address: "127.0.0.1", port: managementPort}}},
+	}
+}
+
+func parsePeer(hostPort string) (Peer, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// no port supplied
+		return Peer{address: strings.Trim(hostPort, "[]"), port: managementPort}, nil
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return Peer{}, fmt.Errorf("Invalid port in peer %s", hostPort)
+	}
+	return Peer{address: host, port: port}, nil
+}
+
+// StaticPeerDiscovery returns a fixed list of peers
+type StaticPeerDiscovery struct {
+	peers []Peer
+}
+
+func (s *StaticPeerDiscovery) Peers() ([]Peer, error) {
+	return s.peers, nil
+}
+func (s *StaticPeerDiscovery) String() string {
+	var peers []string
+	for _, peer := range s.peers {
+		peers = append(peers, peer.String())
+	}
+	return "static:" + strings.Join(peers, ",")
+}
+
+// DNSPeerDiscovery looks up the A/AAAA records of a name
+type DNSPeerDiscovery struct {
+	name string
+	port string
+}
+
+func (d *DNSPeerDiscovery) Peers() ([]Peer, error) {
+	var peers []Peer
+	ips, err := net.LookupIP(d.name)
+	if err != nil {
+		return peers, err
+	}
+	for _, ip := range ips {
+		peers = append(peers, Peer{address: ip.String(), port: d.port})
+	}
+	return peers, nil
+}
+func (d *DNSPeerDiscovery) String() string {
+	return "dns:" + d.name
+}
+
+// SRVPeerDiscovery looks up the SRV records of a name (for example _grpc._tcp.roxprox.roxprox.local)
+type SRVPeerDiscovery struct {
+	name string
+}
+
+func (d *SRVPeerDiscovery) Peers() ([]Peer, error) {
+	var peers []Peer
+	_, records, err := net.LookupSRV("", "", d.name)
+	if err != nil {
+		return peers, err
+	}
+	for _, record := range records {
+		peers = append(peers, Peer{address: strings.TrimSuffix(record.Target, "."), port: strconv.Itoa(int(record.Port))})
+	}
+	return peers, nil
+}
+func (d *SRVPeerDiscovery) String() string {
+	return "srv:" + d.name
+}
+
+// FilePeerDiscovery reads the peers from a file with one host[:port] per line. The file is read on every lookup
+type FilePeerDiscovery struct {
+	path string
+}
+
+func (f *FilePeerDiscovery) Peers() ([]Peer, error) {
+	var peers []Peer
+	file, err := os.Open(f.path)
+	if err != nil {
+		return peers, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		peer, err := parsePeer(line)
+		if err != nil {
+			return peers, err
+		}
+		peers = append(peers, peer)
+	}
+	return peers, scanner.Err()
+}
+func (f *FilePeerDiscovery) String() string {
+	return "file:" + f.path
+}
+
+// MultiPeerDiscovery combines the peers of multiple discoveries. Errors are returned when
+// a discovery fails, together with the peers of the discoveries that succeeded
+type MultiPeerDiscovery []PeerDiscovery
+
+func (m MultiPeerDiscovery) Peers() ([]Peer, error) {
+	var (
+		peers  []Peer
+		errors []string
+	)
+	seen := make(map[Peer]bool)
+	for _, discovery := range m {
+		discovered, err := discovery.Peers()
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", discovery, err))
+		}
+		for _, peer := range discovered {
+			if !seen[peer] {
+				seen[peer] = true
+				peers = append(peers, peer)
+			}
+		}
+	}
+	if len(errors) > 0 {
+		return peers, fmt.Errorf("peer discovery error: %s", strings.Join(errors, "; "))
+	}
+	return peers, nil
+}
+func (m MultiPeerDiscovery) String() string {
+	var discoveries []string
+	for _, discovery := range m {
+		discoveries = append(discoveries, discovery.String())
+	}
+	return strings.Join(discoveries, ",")
+}
+
+// FallbackPeerDiscovery uses the fallback discovery when the discovery doesn't return any peers
+type FallbackPeerDiscovery struct {
+	discovery PeerDiscovery
+	fallback  PeerDiscovery
+}
+
+func (f *FallbackPeerDiscovery) Peers() ([]Peer, error) {
+	peers, err := f.discovery.Peers()
+	if len(peers) == 0 {
+		notificationLogger.Warningf("LookupPeers: no peers found using %s (error: %v), using %s instead", f.discovery, err, f.fallback)
+		return f.fallback.Peers()
+	}
+	return peers, err
+}
+func (f *FallbackPeerDiscovery) String() string {
+	return f.discovery.String()
+}
@@ -0,0 +1,145 @@
+package s3
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	pbN "github.com/in4it/roxprox/proto/notification"
+)
+
+func TestNewPeerDiscovery(t *testing.T) {
+	discovery, err := NewPeerDiscovery("static:10.0.0.1, static:10.0.0.2:50052,dns:roxprox.local,srv:_grpc._tcp.roxprox.local,file:/tmp/peers")
+	if err != nil {
+		t.Errorf("NewPeerDiscovery error: %s", err)
+		return
+	}
+	expected := "static:10.0.0.1:50051,static:10.0.0.2:50052,dns:roxprox.local,srv:_grpc._tcp.roxprox.local,file:/tmp/peers"
+	if discovery.String() != expected {
+		t.Errorf("Unexpected discovery: %s (expected %s)", discovery, expected)
+		return
+	}
+	for _, spec := range []string{"", "unknown:host", "static", "static:host:port"} {
+		if _, err := NewPeerDiscovery(spec); err == nil {
+			t.Errorf("Expected error for %s", spec)
+		}
+	}
+}
+
+func TestFilePeerDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "peers")
+	if err != nil {
+		t.Errorf("TempDir error: %s", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	filename := path.Join(dir, "peers")
+	err = ioutil.WriteFile(filename, []byte("# roxprox peers\n10.0.0.1\n\n[::1]:50052\n10.0.0.1:50051\n"), 0644)
+	if err != nil {
+		t.Errorf("WriteFile error: %s", err)
+		return
+	}
+	discovery, err := NewPeerDiscovery("file:" + filename)
+	if err != nil {
+		t.Errorf("NewPeerDiscovery error: %s", err)
+		return
+	}
+	peers, err := discovery.Peers()
+	if err != nil {
+		t.Errorf("Peers error: %s", err)
+		return
+	}
+	if fmt.Sprintf("%v", peers) != "[10.0.0.1:50051 ::1:50052]" {
+		t.Errorf("Unexpected peers: %v", peers)
+	}
+}
+
+func TestFallbackPeerDiscovery(t *testing.T) {
+	discovery := &FallbackPeerDiscovery{
+		discovery: MultiPeerDiscovery{&FilePeerDiscovery{path: "/nonexistent/peers"}},
+		fallback:  &StaticPeerDiscovery{peers: []Peer{{address: "127.0.0.1", port: managementPort}}},
+	}
+	peers, err := discovery.Peers()
+	if err != nil {
+		t.Errorf("Peers error: %s", err)
+		return
+	}
+	if len(peers) != 1 || peers[0].address != "127.0.0.1" {
+		t.Errorf("Expected fallback peer, got: %v", peers)
+	}
+}
+
+func TestLookupPeersExpiry(t *testing.T) {
+	now := time.Now()
+	discovery := &StaticPeerDiscovery{peers: []Peer{{address: "10.0.0.1", port: managementPort}, {address: "10.0.0.2", port: managementPort}}}
+	n := NewNotifications(Config{Bucket: "bucket", PeerTTL: time.Minute})
+	n.discovery = discovery
+	n.now = func() time.Time { return now }
+
+	if peers := n.lookupPeers(); len(peers) != 2 {
+		t.Errorf("Expected 2 peers, got: %v", peers)
+		return
+	}
+	// peer disappears from discovery: kept until the ttl expires
+	discovery.peers = discovery.peers[:1]
+	now = now.Add(30 * time.Second)
+	if peers := n.lookupPeers(); len(peers) != 2 {
+		t.Errorf("Expected 2 peers, got: %v", peers)
+		return
+	}
+	now = now.Add(time.Minute)
+	peers := n.lookupPeers()
+	if len(peers) != 1 || peers[0].address != "10.0.0.1" {
+		t.Errorf("Expected 1 peer, got: %v", peers)
+		return
+	}
+	if stats := n.GetPeerStats(); len(stats) != 1 || stats[0].Peer != "10.0.0.1:50051" {
+		t.Errorf("Unexpected peer stats: %+v", stats)
+	}
+}
+
+func TestSendNotificationToPeersStats(t *testing.T) {
+	peer := &fakePeer{}
+	peerAddress, stop := startFakePeer(t, peer)
+	defer stop()
+	failingPeer := &fakePeer{fail: true}
+	failingPeerAddress, stopFailing := startFakePeer(t, failingPeer)
+	defer stopFailing()
+
+	n := NewNotifications(Config{Bucket: "bucket"})
+	req := pbN.NotificationRequest{
+		NotificationItem: []*pbN.NotificationRequest_NotificationItem{
+			{Filename: "rule1.yaml", EventName: "ObjectCreated:Put"},
+		},
+	}
+	err := n.SendNotificationToPeers(req, []Peer{failingPeerAddress, peerAddress}, 5)
+	if err == nil {
+		t.Errorf("Expected error")
+		return
+	}
+	// a failing peer doesn't stop the notification to the other peers
+	if len(peer.received) != 1 {
+		t.Errorf("Expected 1 notification, got %d", len(peer.received))
+		return
+	}
+	if peer.requests[0].GetSourceId() == "" || peer.requests[0].GetSequence() != 1 {
+		t.Errorf("Unexpected source id or sequence: %s %d", peer.requests[0].GetSourceId(), peer.requests[0].GetSequence())
+	}
+	for _, stats := range n.GetPeerStats() {
+		switch stats.Peer {
+		case peerAddress.String():
+			if stats.Sent != 1 || stats.Failed != 0 {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+		case failingPeerAddress.String():
+			if stats.Sent != 0 || stats.Failed != 1 || stats.LastError == "" {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+		default:
+			t.Errorf("Unexpected peer: %s", stats.Peer)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package s3
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/in4it/roxprox/pkg/storage/util"
+	pbN "github.com/in4it/roxprox/proto/notification"
+)
+
+// Poller lists the bucket every interval and compares the ETags with the last imported version.
+// It's an alternative to the SQS notifications for S3-compatible stores without SQS
+type Poller struct {
+	config   Config
+	svc      s3iface.S3API
+	interval time.Duration
+	eTags    map[string]string // ETags of the imported objects
+	stop     chan struct{}
+}
+
+// NewPoller lists the ETags of the objects in the bucket. Create the poller before the objects are imported,
+// so the changes during the import are received at the first poll
+func NewPoller(config Config, interval time.Duration) (*Poller, error) {
+	sess, err := newSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return newPoller(config, newS3Client(sess, config), interval)
+}
+
+func newPoller(config Config, svc s3iface.S3API, interval time.Duration) (*Poller, error) {
+	p := &Poller{
+		config:   config,
+		svc:      svc,
+		interval: interval,
+		eTags:    make(map[string]string),
+		stop:     make(chan struct{}),
+	}
+
+	// objects that exist at startup are imported by ImportObjects
+	eTags, err := p.listETags()
+	if err != nil {
+		return nil, err
+	}
+	p.eTags = eTags
+
+	return p, nil
+}
+
+// Start polls the bucket every interval and sends the notifications to receive
+func (p *Poller) Start(receive util.NotificationReceiver) {
+	logger.Infof("Polling bucket %s for changes (interval: %s)", p.config.Bucket, p.interval)
+	ticker := time.NewTicker(p.interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				err := p.Poll(receive)
+				if err != nil {
+					notificationLogger.Errorf("Couldn't poll bucket: %s", err)
+				}
+			case <-p.stop:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+func (p *Poller) Stop() {
+	close(p.stop)
+}
+
+// Poll lists the bucket and sends synthetic put/delete notifications for the objects that changed to receive.
+// The ETag of an object is only updated when its notification is received without error, so a failed import
+// is retried at the next poll
+func (p *Poller) Poll(receive util.NotificationReceiver) error {
+	eTags, err := p.listETags()
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for key := range eTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if eTag, ok := p.eTags[key]; ok && eTag == eTags[key] {
+			continue
+		}
+		logger.Debugf("eTag of s3 object %s changed: sending notification", key)
+		err := receive([]*pbN.NotificationRequest_NotificationItem{
+			{Filename: key, EventName: util.EventObjectCreated},
+		})
+		if err != nil {
+			notificationLogger.Errorf("Couldn't import %s (retrying at the next poll): %s", key, err)
+			continue
+		}
+		p.eTags[key] = eTags[key]
+	}
+
+	keys = []string{}
+	for key := range p.eTags {
+		if _, ok := eTags[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		logger.Debugf("s3 object %s removed: sending notification", key)
+		err := receive([]*pbN.NotificationRequest_NotificationItem{
+			{Filename: key, EventName: util.EventObjectRemoved},
+		})
+		if err != nil {
+			notificationLogger.Errorf("Couldn't remove %s (retrying at the next poll): %s", key, err)
+			continue
+		}
+		delete(p.eTags, key)
+	}
+
+	return nil
+}
+func (p *Poller) listETags() (map[string]string, error) {
+	eTags := make(map[string]string)
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.config.Bucket),
+	}
+	if p.config.Prefix != "" {
+		input.Prefix = aws.String(p.config.Prefix + "/")
+	}
+	err := p.svc.ListObjectsV2Pages(input,
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, item := range page.Contents {
+				key := aws.StringValue(item.Key)
+				if util.IsObjectFile(key) {
+					eTags[key] = aws.StringValue(item.ETag)
+				}
+			}
+			return true
+		})
+
+	return eTags, err
+}
@@ -0,0 +1,124 @@
+package s3
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	pbN "github.com/in4it/roxprox/proto/notification"
+)
+
+type mockListS3 struct {
+	s3iface.S3API
+	objects map[string]string
+	prefix  string
+}
+
+func (m *mockListS3) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	m.prefix = aws.StringValue(input.Prefix)
+	output := &s3.ListObjectsV2Output{}
+	for key, eTag := range m.objects {
+		output.Contents = append(output.Contents, &s3.Object{Key: aws.String(key), ETag: aws.String(eTag)})
+	}
+	fn(output, true)
+	return nil
+}
+
+// testReceiver collects the notifications, and fails for the files in fail
+type testReceiver struct {
+	items []*pbN.NotificationRequest_NotificationItem
+	fail  map[string]bool
+}
+
+func (r *testReceiver) receive(items []*pbN.NotificationRequest_NotificationItem) error {
+	for _, item := range items {
+		if r.fail[item.Filename] {
+			return fmt.Errorf("import of %s failed", item.Filename)
+		}
+	}
+	r.items = append(r.items, items...)
+	return nil
+}
+
+func TestPoll(t *testing.T) {
+	svc := &mockListS3{
+		objects: map[string]string{
+			"config/rule1.yaml":             "etag1",
+			"config/rule2.yaml":             "etag2",
+			"config/pki/certs/test.crt":     "etag3",
+			"config/challenges/test-1.json": "etag4",
+		},
+	}
+	p, err := newPoller(Config{Bucket: "bucket", Prefix: "config"}, svc, 0)
+	if err != nil {
+		t.Errorf("newPoller error: %s", err)
+		return
+	}
+	if svc.prefix != "config/" {
+		t.Errorf("Expected prefix config/, got: %s", svc.prefix)
+		return
+	}
+	receiver := &testReceiver{}
+	if err := p.Poll(receiver.receive); err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(receiver.items) != 0 {
+		t.Errorf("Expected no notifications, got: %+v", receiver.items)
+		return
+	}
+
+	svc.objects["config/rule1.yaml"] = "etag1-changed"
+	svc.objects["config/rule3.yaml"] = "etag5"
+	delete(svc.objects, "config/rule2.yaml")
+	receiver = &testReceiver{}
+	if err := p.Poll(receiver.receive); err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(receiver.items) != 3 {
+		t.Errorf("Expected 3 notifications, got: %+v", receiver.items)
+		return
+	}
+	expected := []struct{ filename, eventName string }{
+		{"config/rule1.yaml", "ObjectCreated:Put"},
+		{"config/rule3.yaml", "ObjectCreated:Put"},
+		{"config/rule2.yaml", "ObjectRemoved:Delete"},
+	}
+	for k, v := range expected {
+		if receiver.items[k].Filename != v.filename || receiver.items[k].EventName != v.eventName {
+			t.Errorf("Unexpected notification: %+v (expected %+v)", receiver.items[k], v)
+		}
+	}
+
+	receiver = &testReceiver{}
+	if err := p.Poll(receiver.receive); err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(receiver.items) != 0 {
+		t.Errorf("Expected no notifications, got: %+v", receiver.items)
+	}
+
+	// a failed import is sent again at the next poll
+	svc.objects["config/rule1.yaml"] = "etag1-changed-again"
+	svc.objects["config/rule3.yaml"] = "etag5-changed"
+	receiver = &testReceiver{fail: map[string]bool{"config/rule1.yaml": true}}
+	if err := p.Poll(receiver.receive); err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(receiver.items) != 1 || receiver.items[0].Filename != "config/rule3.yaml" {
+		t.Errorf("Unexpected notifications: %+v", receiver.items)
+	}
+	receiver = &testReceiver{}
+	if err := p.Poll(receiver.receive); err != nil {
+		t.Errorf("Poll error: %s", err)
+		return
+	}
+	if len(receiver.items) != 1 || receiver.items[0].Filename != "config/rule1.yaml" {
+		t.Errorf("Expected the failed notification again, got: %+v", receiver.items)
+	}
+}
@@ -0,0 +1,40 @@
+package s3
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// newSession returns an AWS session using the region, static credentials and CA bundle of the config
+func newSession(config Config) (*session.Session, error) {
+	awsConfig := aws.NewConfig().WithRegion(config.Region)
+	if config.AccessKeyID != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, ""))
+	}
+	options := session.Options{Config: *awsConfig}
+	if config.CABundle != "" {
+		caBundle, err := os.Open(config.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		defer caBundle.Close()
+		options.CustomCABundle = caBundle
+	}
+	return session.NewSessionWithOptions(options)
+}
+
+// newS3Client returns an S3 client using the custom endpoint of the config (S3-compatible object stores)
+func newS3Client(sess *session.Session, config Config) *s3.S3 {
+	s3Config := aws.NewConfig()
+	if config.Endpoint != "" {
+		s3Config = s3Config.WithEndpoint(config.Endpoint)
+	}
+	if config.ForcePathStyle {
+		s3Config = s3Config.WithS3ForcePathStyle(true)
+	}
+	return s3.New(sess, s3Config)
+}
@@ -1,7 +1,10 @@
 package s3
 
 import (
+	"crypto/tls"
 	"time"
+
+	"github.com/in4it/roxprox/pkg/crypto"
 )
 
 type Config struct {
@@ -9,6 +12,17 @@ type Config struct {
 	Bucket               string
 	Region               string
 	StorageNotifications string
+	Endpoint             string // custom endpoint for S3-compatible object stores
+	ForcePathStyle       bool
+	CABundle             string // path to a PEM file with CA certificates to trust
+	AccessKeyID          string
+	SecretAccessKey      string
+	SQSEndpoint          string // custom endpoint for the notifications queue
+	PeerDiscovery        string // see NewPeerDiscovery, defaults to dns lookups on the storage notifications
+	PeerTTL              time.Duration
+	KeyProvider          crypto.KeyProvider // encrypts the objects in pki/ when set
+	PeerTLSConfig        *tls.Config        // notifications are sent to the peers with TLS when set, e.g. to present a client certificate
+	MaxReceiveCount      int                // messages that couldn't be relayed after this many receives are moved to the dead-letter queue (default 10)
 }
 
 type NotificationEntry struct {
@@ -4,39 +4,102 @@ import (
 	"crypto/rsa"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/in4it/roxprox/pkg/api"
+	"github.com/in4it/roxprox/pkg/crypto"
+	"github.com/in4it/roxprox/pkg/storage/git"
+	"github.com/in4it/roxprox/pkg/storage/httpsource"
+	"github.com/in4it/roxprox/pkg/storage/kubernetes"
 	"github.com/in4it/roxprox/pkg/storage/local"
 	"github.com/in4it/roxprox/pkg/storage/s3"
+	"github.com/in4it/roxprox/pkg/storage/util"
+	"github.com/in4it/roxprox/pkg/storage/vault"
 )
 
 type Config struct {
 	path string
 }
 
+// Storage stores both the objects and the secrets
 type Storage interface {
 	SetLogLevel(loglevel string)
 	SetStoragePath(path string)
-	GetError(name string) error
+	ObjectStore
+	SecretStore
+}
+
+// ObjectStore stores the objects (rules, jwt providers, ...) and keeps a cache of the objects per filename
+type ObjectStore interface {
 	ListObjects() ([]api.Object, error)
 	GetObject(name string) ([]api.Object, error)
+	ListObjectFilenames() ([]string, error)
+	GetCachedObjectName(filename string) ([]*api.Object, error)
+	DeleteCachedObject(filename string) error
+	ListCachedObjectFilenames() []string
+	CountCachedObjectByCondition(condition api.RuleConditions, actions []api.RuleActions) int
+	CountCachedJwtRulesByCondition(condition api.RuleConditions, jwtProvider string) int
+	GetCachedRule(name string) *api.Object
+}
+
+// SecretStore stores the certificates, keys and ACME challenges
+type SecretStore interface {
+	GetError(name string) error
 	ListCerts() (map[string]string, error)
 	GetCert(name string) (string, error)
 	GetCertBundle(name string) (string, error)
 	WriteCert(name string, cert []byte) error
 	WriteCertBundle(name string, certs []byte) error
-	GetPrivateAccountkey() (*rsa.PrivateKey, error)
-	GetPublicAccountkey() (*rsa.PublicKey, error)
-	CreateAccountKey() error
+	GetPrivateAccountkey(account string) (*rsa.PrivateKey, error)
+	GetPublicAccountkey(account string) (*rsa.PublicKey, error)
+	CreateAccountKey(account string) error
+	WriteAccountKey(account string, key *rsa.PrivateKey) error
+	DeleteAccountKey(account string) error
 	CreateKey(name string) error
 	GetPrivateKey(name string) (*rsa.PrivateKey, error)
 	GetPrivateKeyPem(name string) (string, error)
 	WriteChallenge(name string, data []byte) error
-	GetCachedObjectName(filename string) ([]*api.Object, error)
-	DeleteCachedObject(filename string) error
-	CountCachedObjectByCondition(condition api.RuleConditions, actions []api.RuleActions) int
-	CountCachedJwtRulesByCondition(condition api.RuleConditions, jwtProvider string) int
-	GetCachedRule(name string) *api.Object
+}
+
+// Import states of an object, see StatusStorage
+const (
+	ObjectStateImported          = "Imported"
+	ObjectStateError             = "Error"
+	ObjectStatePendingDependency = "PendingDependency"
+)
+
+// StatusStorage is implemented by the storage types that can report the import state of an object
+type StatusStorage interface {
+	SetObjectStatus(kind, name, state, message string)
+}
+
+// VersionedStorage is implemented by the storage types that know the version of the configuration
+type VersionedStorage interface {
+	GetConfigVersion() string
+}
+
+// WritableStorage is implemented by the storage types that can write object files (local and s3). The version of a
+// file changes when the file changes. Writes and deletes with a version fail with ErrVersionConflict when the file changed
+type WritableStorage interface {
+	ObjectFilename(basename string) string
+	GetObjectVersion(filename string) (string, error)
+	PutObject(filename string, contents []byte, version string) (string, error)
+	DeleteObject(filename string, version string) error
+}
+
+// ErrVersionConflict is returned by WritableStorage when the file was changed in the meantime
+var ErrVersionConflict = util.ErrVersionConflict
+
+// GetWritableStorage returns the storage when the object files can be written (the object store of a CombinedStorage)
+func GetWritableStorage(s Storage) (WritableStorage, bool) {
+	if c, ok := s.(*CombinedStorage); ok {
+		if _, ok := c.ObjectStore.(WritableStorage); !ok {
+			return nil, false
+		}
+		return c, true
+	}
+	w, ok := s.(WritableStorage)
+	return w, ok
 }
 
 func NewStorage(t string, config interface{}) (Storage, error) {
@@ -44,13 +107,37 @@ func NewStorage(t string, config interface{}) (Storage, error) {
 		return local.NewLocalStorage(config.(local.Config))
 	} else if t == "s3" {
 		return s3.NewS3Storage(config.(s3.Config))
+	} else if t == "git" {
+		return git.NewGitStorage(config.(git.Config))
 	} else {
 		return nil, fmt.Errorf("Unknown storage type supplied")
 	}
 }
 
+// NewSecretStore returns a store for the certificates and keys. It can be combined with any object store using NewCombinedStorage
+func NewSecretStore(t string, config interface{}) (SecretStore, error) {
+	if t == "local" {
+		return local.NewLocalStorage(config.(local.Config))
+	} else if t == "s3" {
+		s3Config := config.(s3.Config)
+		if s3Config.Bucket == "" {
+			return nil, fmt.Errorf("No bucket specified")
+		}
+		s3Config.Prefix = strings.TrimSuffix(s3Config.Prefix, "/")
+		return s3.NewS3Storage(s3Config)
+	} else if t == "vault" {
+		return vault.NewVaultStorage(config.(vault.Config))
+	} else {
+		return nil, fmt.Errorf("Unknown secret storage type supplied")
+	}
+}
+
 func NewLocalStorage(storagePath string) (Storage, error) {
-	storage, err := NewStorage("local", local.Config{Path: storagePath})
+	return NewLocalStorageWithConfig(local.Config{Path: storagePath})
+}
+
+func NewLocalStorageWithConfig(config local.Config) (Storage, error) {
+	storage, err := NewStorage("local", config)
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't inialize storage: %s", err)
 	}
@@ -58,14 +145,14 @@ func NewLocalStorage(storagePath string) (Storage, error) {
 	return storage, nil
 }
 func NewS3Storage(storageBucket, storagePath, awsRegion, storageNotifications string, startQueue bool) (Storage, error) {
-	if storageBucket == "" {
+	return NewS3StorageWithConfig(s3.Config{Prefix: storagePath, Bucket: storageBucket, Region: awsRegion, StorageNotifications: storageNotifications}, startQueue)
+}
+
+func NewS3StorageWithConfig(config s3.Config, startQueue bool) (Storage, error) {
+	if config.Bucket == "" {
 		return nil, fmt.Errorf("No bucket specified")
 	}
-	if strings.HasSuffix(storagePath, "/") {
-		storagePath = storagePath[:len(storagePath)-1]
-	}
-
-	config := s3.Config{Prefix: storagePath, Bucket: storageBucket, Region: awsRegion, StorageNotifications: storageNotifications}
+	config.Prefix = strings.TrimSuffix(config.Prefix, "/")
 
 	storage, err := NewStorage("s3", config)
 	if err != nil {
@@ -85,6 +172,67 @@ func NewS3Storage(storageBucket, storagePath, awsRegion, storageNotifications st
 	return storage, nil
 }
 
+func NewGitStorage(config git.Config) (Storage, error) {
+	storage, err := NewStorage("git", config)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't inialize storage: %s", err)
+	}
+
+	return storage, nil
+}
+
+func NewKubernetesStorage(config kubernetes.Config) (*kubernetes.KubernetesStorage, error) {
+	storage, err := kubernetes.NewKubernetesStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't inialize storage: %s", err)
+	}
+
+	return storage, nil
+}
+
+// NewHTTPStorage returns an object store that fetches the objects from a http(s) endpoint
+func NewHTTPStorage(config httpsource.Config) (*httpsource.HTTPStorage, error) {
+	storage, err := httpsource.NewHTTPStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't inialize storage: %s", err)
+	}
+
+	return storage, nil
+}
+
+// NewKeyProvider returns the key provider to encrypt the certificates and keys with: passphrase, keyfile or kms.
+// The value is the passphrase, the path to the key file or the KMS key id
+func NewKeyProvider(t, value, awsRegion string) (crypto.KeyProvider, error) {
+	switch t {
+	case "passphrase":
+		return crypto.NewPassphraseKeyProvider(value)
+	case "keyfile":
+		return crypto.NewKeyFileProvider(value)
+	case "kms":
+		if value == "" {
+			return nil, fmt.Errorf("No KMS key id specified")
+		}
+		return crypto.NewKMSKeyProvider(value, awsRegion)
+	default:
+		return nil, fmt.Errorf("Unknown key provider supplied")
+	}
+}
+
 func NewNotificationReceiver() *s3.NotificationReceiver {
 	return s3.NewNotificationReceiver()
 }
+
+func NewLocalWatcher(storagePath string, interval, debounce time.Duration) (*local.Watcher, error) {
+	return local.NewWatcher(local.WatcherConfig{Path: storagePath, Interval: interval, Debounce: debounce})
+}
+func NewGitWatcher(s ObjectStore, interval time.Duration) (*git.Watcher, error) {
+	gitStorage, ok := s.(*git.GitStorage)
+	if !ok {
+		return nil, fmt.Errorf("Storage is not a git storage")
+	}
+	return git.NewWatcher(gitStorage, interval), nil
+}
+func NewS3Poller(config s3.Config, interval time.Duration) (*s3.Poller, error) {
+	config.Prefix = strings.TrimSuffix(config.Prefix, "/")
+	return s3.NewPoller(config, interval)
+}
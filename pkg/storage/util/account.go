@@ -0,0 +1,10 @@
+package util
+
+// AccountKeyPath returns the path of the account keys of a named ACME account.
+// The default account (empty name) is kept in the root of pki/accountkeys
+func AccountKeyPath(account string) string {
+	if account == "" {
+		return "/pki/accountkeys"
+	}
+	return "/pki/accountkeys/" + account
+}
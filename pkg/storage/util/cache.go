@@ -0,0 +1,106 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/in4it/roxprox/pkg/api"
+)
+
+// ObjectCache keeps track of the objects per filename, so removed objects can be found when a file changes
+type ObjectCache struct {
+	mu    sync.Mutex
+	cache map[string][]*api.Object
+}
+
+func NewObjectCache() *ObjectCache {
+	return &ObjectCache{cache: make(map[string][]*api.Object)}
+}
+
+func (c *ObjectCache) SetCachedObjects(filename string, objects []api.Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	objectsP := make([]*api.Object, len(objects))
+	for k := range objects {
+		object := objects[k]
+		objectsP[k] = &object
+	}
+	c.cache[filename] = objectsP
+}
+
+func (c *ObjectCache) GetCachedObjectName(filename string) ([]*api.Object, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if val, ok := c.cache[filename]; ok {
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("Filename %s not found in cache", filename)
+}
+func (c *ObjectCache) DeleteCachedObject(filename string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.cache[filename]; ok {
+		delete(c.cache, filename)
+		return nil
+	}
+
+	return fmt.Errorf("Filename %s not found in cache", filename)
+}
+func (c *ObjectCache) ListCachedObjectFilenames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	filenames := make([]string, 0, len(c.cache))
+	for filename := range c.cache {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+func (c *ObjectCache) CountCachedObjectByCondition(condition api.RuleConditions, actions []api.RuleActions) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := 0
+	for _, objects := range c.cache {
+		for _, object := range objects {
+			if object.Kind == "rule" {
+				rule := object.Data.(api.Rule)
+				if CmpActions(rule.Spec.Actions, actions) && ConditionExists(rule.Spec.Conditions, condition) {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+func (c *ObjectCache) CountCachedJwtRulesByCondition(condition api.RuleConditions, jwtProvider string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := 0
+	for _, objects := range c.cache {
+		for _, object := range objects {
+			if object.Kind == "rule" {
+				rule := object.Data.(api.Rule)
+				if rule.Spec.Auth.JwtProvider == jwtProvider && ConditionExists(rule.Spec.Conditions, condition) {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+func (c *ObjectCache) GetCachedRule(name string) *api.Object {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, objects := range c.cache {
+		for _, object := range objects {
+			if object.Kind == "rule" && object.Metadata.Name == name {
+				return object
+			}
+		}
+	}
+	return nil
+}
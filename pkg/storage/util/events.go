@@ -0,0 +1,12 @@
+package util
+
+import n "github.com/in4it/roxprox/proto/notification"
+
+// Event names of the notifications sent by the storage watchers. These are the names of the S3 event notifications
+const (
+	EventObjectCreated = "ObjectCreated:Put"
+	EventObjectRemoved = "ObjectRemoved:Delete"
+)
+
+// NotificationReceiver imports the objects of the notifications (see XDS.ReceiveNotification)
+type NotificationReceiver func(items []*n.NotificationRequest_NotificationItem) error
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+
+	"github.com/in4it/roxprox/pkg/api"
+	"github.com/juju/loggo"
+)
+
+var logger = loggo.GetLogger("storage.util")
+
+// IsObjectFile returns true for the file types that can contain objects (yaml and json).
+// Hidden files (e.g. editor swap files) are skipped
+func IsObjectFile(filename string) bool {
+	if strings.HasPrefix(path.Base(filename), ".") {
+		return false
+	}
+	return strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml") || strings.HasSuffix(filename, ".json")
+}
+
+// ParseObjects converts the yaml or json documents in contents into objects using the kind registry.
+// A json file contains a single object or a list of objects. Errors contain the filename and the index of the document,
+// as do the logged warnings about deprecated fields
+func ParseObjects(filename string, contents []byte) ([]api.Object, error) {
+	var objects []api.Object
+	format := api.FormatYAML
+	documents := SplitDocuments(contents)
+	if strings.HasSuffix(filename, ".json") {
+		format = api.FormatJSON
+		var err error
+		documents, err = splitJSONDocuments(contents)
+		if err != nil {
+			return objects, &api.ObjectError{Filename: filename, Document: 1, Err: err}
+		}
+	}
+	for i, document := range documents {
+		object, warnings, err := api.DecodeObjectWithWarnings(format, []byte(document))
+		for _, warning := range warnings {
+			logger.Warningf("%s (document %d): %s", filename, i+1, warning)
+		}
+		if err != nil {
+			return objects, &api.ObjectError{Filename: filename, Document: i + 1, Err: err}
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// SplitDocuments splits a multi-document yaml file and skips the empty documents
+func SplitDocuments(contents []byte) []string {
+	var documents []string
+	for _, document := range strings.Split(string(contents), "\n---") {
+		if strings.TrimSpace(document) != "" {
+			documents = append(documents, document)
+		}
+	}
+	return documents
+}
+
+// splitJSONDocuments returns the objects of a json list, or the json object
+func splitJSONDocuments(contents []byte) ([]string, error) {
+	contents = bytes.TrimSpace(contents)
+	if len(contents) == 0 {
+		return nil, nil
+	}
+	if contents[0] != '[' {
+		return []string{string(contents)}, nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(contents, &list); err != nil {
+		return nil, err
+	}
+	documents := make([]string, len(list))
+	for i, document := range list {
+		documents[i] = string(document)
+	}
+	return documents, nil
+}
@@ -0,0 +1,16 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrVersionConflict is returned when an object file is written or deleted with a version that isn't the current version of the file
+var ErrVersionConflict = errors.New("The file was changed in the meantime (version conflict)")
+
+// GetContentVersion returns the version of the contents of a file, a hash of the contents
+func GetContentVersion(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:16])
+}
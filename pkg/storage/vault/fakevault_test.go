@@ -0,0 +1,139 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fakeVault is a minimal implementation of the vault KV v2 api with token and AppRole auth
+type fakeVault struct {
+	*httptest.Server
+	mount    string
+	roleID   string
+	secretID string
+	mu       sync.Mutex
+	tokens   map[string]bool
+	logins   int
+	secrets  map[string][]map[string]interface{} // versions per path
+}
+
+func newFakeVault(mount, token string) *fakeVault {
+	f := &fakeVault{
+		mount:    mount,
+		roleID:   "role1",
+		secretID: "secret1",
+		tokens:   map[string]bool{token: true},
+		secrets:  make(map[string][]map[string]interface{}),
+	}
+	f.Server = httptest.NewServer(f)
+	return f
+}
+
+// expireTokens revokes all tokens
+func (f *fakeVault) expireTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = make(map[string]bool)
+}
+
+func (f *fakeVault) get(path string) (map[string]interface{}, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versions, ok := f.secrets[path]
+	if !ok {
+		return nil, false
+	}
+	return versions[len(versions)-1], true
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" && r.Method == "POST" {
+		var input map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input["role_id"] != f.roleID || input["secret_id"] != f.secretID {
+			f.writeError(w, http.StatusBadRequest, "invalid role or secret ID")
+			return
+		}
+		f.logins++
+		token := fmt.Sprintf("s.approle%d", f.logins)
+		f.tokens[token] = true
+		json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]interface{}{"client_token": token}})
+		return
+	}
+	if !f.tokens[r.Header.Get("X-Vault-Token")] {
+		f.writeError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	dataPrefix := "/v1/" + f.mount + "/data/"
+	metadataPrefix := "/v1/" + f.mount + "/metadata/"
+	switch {
+	case strings.HasPrefix(r.URL.Path, dataPrefix) && r.Method == "GET":
+		versions, ok := f.secrets[strings.TrimPrefix(r.URL.Path, dataPrefix)]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     versions[len(versions)-1],
+				"metadata": map[string]interface{}{"version": len(versions)},
+			},
+		})
+	case strings.HasPrefix(r.URL.Path, dataPrefix) && (r.Method == "POST" || r.Method == "PUT"):
+		var input struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Data == nil {
+			f.writeError(w, http.StatusBadRequest, "no data provided")
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, dataPrefix)
+		f.secrets[path] = append(f.secrets[path], input.Data)
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"version": len(f.secrets[path])}})
+	case strings.HasPrefix(r.URL.Path, metadataPrefix) && r.Method == "DELETE":
+		delete(f.secrets, strings.TrimPrefix(r.URL.Path, metadataPrefix))
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(r.URL.Path, metadataPrefix) && (r.Method == "LIST" || r.URL.Query().Get("list") == "true"):
+		prefix := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, metadataPrefix), "/") + "/"
+		keys := map[string]bool{}
+		for path := range f.secrets {
+			if strings.HasPrefix(path, prefix) {
+				key := strings.TrimPrefix(path, prefix)
+				if i := strings.Index(key, "/"); i != -1 {
+					key = key[:i+1]
+				}
+				keys[key] = true
+			}
+		}
+		if len(keys) == 0 {
+			f.writeError(w, http.StatusNotFound, "")
+			return
+		}
+		list := []string{}
+		for key := range keys {
+			list = append(list, key)
+		}
+		sort.Strings(list)
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": list}})
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed, "unsupported path")
+	}
+}
+
+func (f *fakeVault) writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	errors := []string{}
+	if message != "" {
+		errors = append(errors, message)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": errors})
+}
@@ -0,0 +1,338 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/in4it/roxprox/pkg/crypto"
+	"github.com/in4it/roxprox/pkg/storage/util"
+	"github.com/juju/loggo"
+)
+
+var (
+	logger      = loggo.GetLogger("storage.vault")
+	errNotExist = errors.New("Secret does not exist")
+)
+
+type Config struct {
+	Address  string // e.g. https://vault.example.com:8200
+	Mount    string // KV v2 mount, defaults to secret
+	Path     string // path within the mount to store the secrets in
+	Token    string // token auth
+	RoleID   string // AppRole auth, used when no token is supplied
+	SecretID string
+	CACert   string // path to a PEM file with CA certificates to trust
+	Timeout  time.Duration
+}
+
+// VaultStorage stores the certificates, keys and challenges in a Vault KV v2 mount
+type VaultStorage struct {
+	config Config
+	client *http.Client
+	mu     sync.Mutex
+	token  string
+}
+
+type secretResponse struct {
+	Data struct {
+		Data map[string]string `json:"data"`
+		Keys []string          `json:"keys"`
+	} `json:"data"`
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+func NewVaultStorage(config Config) (*VaultStorage, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("No vault address specified")
+	}
+	if config.Token == "" && config.RoleID == "" {
+		return nil, fmt.Errorf("No vault token or AppRole role id specified")
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+	config.Mount = strings.Trim(config.Mount, "/")
+	config.Path = strings.Trim(config.Path, "/")
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	client := &http.Client{Timeout: config.Timeout}
+	if config.CACert != "" {
+		caCert, err := ioutil.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read CA certificate: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("No CA certificates found in %s", config.CACert)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+
+	v := &VaultStorage{config: config, client: client, token: config.Token}
+	if v.token == "" {
+		if err := v.login(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (v *VaultStorage) SetLogLevel(loglevel string) {
+	if loglevel == "debug" {
+		logger.SetLogLevel(loggo.DEBUG)
+	}
+}
+
+func (v *VaultStorage) GetError(name string) error {
+	if name == "errNotExist" {
+		return errNotExist
+	}
+	return nil
+}
+
+func (v *VaultStorage) ListCerts() (map[string]string, error) {
+	names, err := v.list("pki/bundles")
+	if err != nil {
+		if err == errNotExist {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	certs := make(map[string]string)
+	for _, name := range names {
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		cert, err := v.GetCert(name)
+		if err != nil {
+			return nil, err
+		}
+		certs[name] = cert
+	}
+	return certs, nil
+}
+func (v *VaultStorage) GetCert(name string) (string, error) {
+	data, err := v.read("pki/certs/" + name)
+	if err != nil {
+		return "", err
+	}
+	return data["cert"], nil
+}
+func (v *VaultStorage) GetCertBundle(name string) (string, error) {
+	data, err := v.read("pki/bundles/" + name)
+	if err != nil {
+		return "", err
+	}
+	return data["bundle"], nil
+}
+func (v *VaultStorage) WriteCert(name string, cert []byte) error {
+	return v.write("pki/certs/"+name, map[string]string{"cert": string(cert)})
+}
+func (v *VaultStorage) WriteCertBundle(name string, certs []byte) error {
+	return v.write("pki/bundles/"+name, map[string]string{"bundle": string(certs)})
+}
+func (v *VaultStorage) GetPrivateAccountkey(account string) (*rsa.PrivateKey, error) {
+	data, err := v.read(strings.TrimPrefix(util.AccountKeyPath(account), "/"))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.GetPrivateKey([]byte(data["private"]))
+}
+func (v *VaultStorage) GetPublicAccountkey(account string) (*rsa.PublicKey, error) {
+	data, err := v.read(strings.TrimPrefix(util.AccountKeyPath(account), "/"))
+	if err != nil {
+		return nil, err
+	}
+	return crypto.GetPublicKey([]byte(data["public"]))
+}
+func (v *VaultStorage) CreateAccountKey(account string) error {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	return v.WriteAccountKey(account, key)
+}
+func (v *VaultStorage) WriteAccountKey(account string, key *rsa.PrivateKey) error {
+	return v.writeKey(strings.TrimPrefix(util.AccountKeyPath(account), "/"), key)
+}
+func (v *VaultStorage) DeleteAccountKey(account string) error {
+	return v.delete(strings.TrimPrefix(util.AccountKeyPath(account), "/"))
+}
+func (v *VaultStorage) CreateKey(name string) error {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	return v.writeKey("pki/keys/"+name, key)
+}
+func (v *VaultStorage) GetPrivateKey(name string) (*rsa.PrivateKey, error) {
+	privateKey, err := v.GetPrivateKeyPem(name)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.GetPrivateKey([]byte(privateKey))
+}
+func (v *VaultStorage) GetPrivateKeyPem(name string) (string, error) {
+	data, err := v.read("pki/keys/" + name)
+	if err != nil {
+		return "", err
+	}
+	return data["private"], nil
+}
+func (v *VaultStorage) WriteChallenge(name string, data []byte) error {
+	return v.write("challenges/"+name, map[string]string{"data": string(data)})
+}
+
+func (v *VaultStorage) writeKey(path string, key *rsa.PrivateKey) error {
+	publicKey, err := crypto.ConvertToPublicPEMKey(key.PublicKey)
+	if err != nil {
+		return err
+	}
+	return v.write(path, map[string]string{
+		"private": string(crypto.ConvertToPEMKey(key)),
+		"public":  string(publicKey),
+	})
+}
+
+// read returns the latest version of a secret
+func (v *VaultStorage) read(path string) (map[string]string, error) {
+	var response secretResponse
+	err := v.do("GET", v.config.Mount+"/data/"+v.secretPath(path), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Data.Data, nil
+}
+
+// write creates a new version of a secret
+func (v *VaultStorage) write(path string, data map[string]string) error {
+	logger.Debugf("Writing %s to vault", v.secretPath(path))
+	return v.do("POST", v.config.Mount+"/data/"+v.secretPath(path), map[string]interface{}{"data": data}, nil)
+}
+
+// delete removes all versions of a secret
+func (v *VaultStorage) delete(path string) error {
+	logger.Debugf("Deleting %s from vault", v.secretPath(path))
+	err := v.do("DELETE", v.config.Mount+"/metadata/"+v.secretPath(path), nil, nil)
+	if err == errNotExist {
+		return nil
+	}
+	return err
+}
+
+// list returns the secrets and folders (ending with /) in a path
+func (v *VaultStorage) list(path string) ([]string, error) {
+	var response secretResponse
+	err := v.do("LIST", v.config.Mount+"/metadata/"+v.secretPath(path), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Data.Keys, nil
+}
+
+func (v *VaultStorage) secretPath(path string) string {
+	if v.config.Path == "" {
+		return path
+	}
+	return v.config.Path + "/" + path
+}
+
+// do sends a request to the vault api. With AppRole auth, it logs in again when the token expired
+func (v *VaultStorage) do(method, path string, input interface{}, output *secretResponse) error {
+	status, err := v.request(method, path, input, output)
+	if err == nil || status != http.StatusForbidden || v.config.RoleID == "" {
+		return err
+	}
+	logger.Debugf("Vault token expired, logging in again")
+	if err := v.login(); err != nil {
+		return err
+	}
+	_, err = v.request(method, path, input, output)
+	return err
+}
+
+func (v *VaultStorage) request(method, path string, input interface{}, output *secretResponse) (int, error) {
+	var body []byte
+	if input != nil {
+		var err error
+		body, err = json.Marshal(input)
+		if err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequest(method, v.config.Address+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	v.mu.Lock()
+	token := v.token
+	v.mu.Unlock()
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return resp.StatusCode, errNotExist
+	}
+	if resp.StatusCode >= 300 {
+		var response secretResponse
+		if err := json.Unmarshal(contents, &response); err == nil && len(response.Errors) > 0 {
+			return resp.StatusCode, fmt.Errorf("Vault error (%s %s): %s", method, path, strings.Join(response.Errors, ", "))
+		}
+		return resp.StatusCode, fmt.Errorf("Vault error (%s %s): %s", method, path, resp.Status)
+	}
+	if output != nil && len(contents) > 0 {
+		if err := json.Unmarshal(contents, output); err != nil {
+			return resp.StatusCode, fmt.Errorf("Couldn't parse vault response: %s", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// login retrieves a token using AppRole auth
+func (v *VaultStorage) login() error {
+	v.mu.Lock()
+	v.token = ""
+	v.mu.Unlock()
+
+	var response secretResponse
+	_, err := v.request("POST", "auth/approle/login", map[string]string{"role_id": v.config.RoleID, "secret_id": v.config.SecretID}, &response)
+	if err != nil {
+		return fmt.Errorf("Couldn't login to vault: %s", err)
+	}
+	if response.Auth.ClientToken == "" {
+		return fmt.Errorf("Couldn't login to vault: no token returned")
+	}
+
+	v.mu.Lock()
+	v.token = response.Auth.ClientToken
+	v.mu.Unlock()
+	return nil
+}
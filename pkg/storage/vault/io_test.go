@@ -0,0 +1,155 @@
+package vault
+
+import (
+	"testing"
+)
+
+func TestVaultStorage(t *testing.T) {
+	f := newFakeVault("kv", "s.root")
+	defer f.Close()
+
+	v, err := NewVaultStorage(Config{Address: f.URL, Mount: "kv", Path: "roxprox", Token: "s.root"})
+	if err != nil {
+		t.Errorf("NewVaultStorage error: %s", err)
+		return
+	}
+
+	// account keys
+	if _, err := v.GetPrivateAccountkey(""); err != errNotExist {
+		t.Errorf("Expected errNotExist, got: %v", err)
+		return
+	}
+	if err := v.CreateAccountKey(""); err != nil {
+		t.Errorf("CreateAccountKey error: %s", err)
+		return
+	}
+	if err := v.CreateAccountKey("tenant1"); err != nil {
+		t.Errorf("CreateAccountKey error: %s", err)
+		return
+	}
+	defaultKey, err := v.GetPrivateAccountkey("")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	tenantKey, err := v.GetPrivateAccountkey("tenant1")
+	if err != nil {
+		t.Errorf("GetPrivateAccountkey error: %s", err)
+		return
+	}
+	if defaultKey.Equal(tenantKey) {
+		t.Errorf("Expected different keys for different accounts")
+	}
+	publicKey, err := v.GetPublicAccountkey("tenant1")
+	if err != nil {
+		t.Errorf("GetPublicAccountkey error: %s", err)
+		return
+	}
+	if !publicKey.Equal(&tenantKey.PublicKey) {
+		t.Errorf("Public key doesn't match private key")
+	}
+	if _, ok := f.get("roxprox/pki/accountkeys/tenant1"); !ok {
+		t.Errorf("Account key for tenant1 not found in vault")
+	}
+	if err := v.DeleteAccountKey("tenant1"); err != nil {
+		t.Errorf("DeleteAccountKey error: %s", err)
+		return
+	}
+	if _, err := v.GetPrivateAccountkey("tenant1"); err != errNotExist {
+		t.Errorf("Expected errNotExist after delete, got: %v", err)
+	}
+	if _, err := v.GetPrivateAccountkey(""); err != nil {
+		t.Errorf("Default account key was deleted: %s", err)
+	}
+
+	// keys
+	if err := v.CreateKey("test1"); err != nil {
+		t.Errorf("CreateKey error: %s", err)
+		return
+	}
+	key, err := v.GetPrivateKey("test1")
+	if err != nil {
+		t.Errorf("GetPrivateKey error: %s", err)
+		return
+	}
+	keyPem, err := v.GetPrivateKeyPem("test1")
+	if err != nil || key == nil || keyPem == "" {
+		t.Errorf("GetPrivateKeyPem error: %v", err)
+		return
+	}
+
+	// certs
+	certs, err := v.ListCerts()
+	if err != nil || len(certs) != 0 {
+		t.Errorf("ListCerts: unexpected result: %+v (error: %v)", certs, err)
+		return
+	}
+	if err := v.WriteCert("test1", []byte("cert")); err != nil {
+		t.Errorf("WriteCert error: %s", err)
+		return
+	}
+	if err := v.WriteCertBundle("test1", []byte("bundle")); err != nil {
+		t.Errorf("WriteCertBundle error: %s", err)
+		return
+	}
+	bundle, err := v.GetCertBundle("test1")
+	if err != nil || bundle != "bundle" {
+		t.Errorf("GetCertBundle: unexpected result: %s (error: %v)", bundle, err)
+	}
+	certs, err = v.ListCerts()
+	if err != nil {
+		t.Errorf("ListCerts error: %s", err)
+		return
+	}
+	if len(certs) != 1 || certs["test1"] != "cert" {
+		t.Errorf("ListCerts: unexpected result: %+v", certs)
+	}
+
+	// challenges
+	if err := v.WriteChallenge("test1-test1.example.com", []byte("{}")); err != nil {
+		t.Errorf("WriteChallenge error: %s", err)
+		return
+	}
+	if data, ok := f.get("roxprox/challenges/test1-test1.example.com"); !ok || data["data"] != "{}" {
+		t.Errorf("Challenge not found in vault: %+v", data)
+	}
+
+	// token auth doesn't log in again
+	f.expireTokens()
+	if _, err := v.GetPrivateKey("test1"); err == nil {
+		t.Errorf("Expected error after the token expired")
+	}
+}
+
+func TestVaultStorageAppRole(t *testing.T) {
+	f := newFakeVault("secret", "s.root")
+	defer f.Close()
+
+	if _, err := NewVaultStorage(Config{Address: f.URL, RoleID: "role1", SecretID: "wrong"}); err == nil {
+		t.Errorf("Expected login error with the wrong secret id")
+		return
+	}
+
+	v, err := NewVaultStorage(Config{Address: f.URL, RoleID: "role1", SecretID: "secret1"})
+	if err != nil {
+		t.Errorf("NewVaultStorage error: %s", err)
+		return
+	}
+	if err := v.CreateKey("test1"); err != nil {
+		t.Errorf("CreateKey error: %s", err)
+		return
+	}
+	if _, ok := f.get("pki/keys/test1"); !ok {
+		t.Errorf("Key not found in vault")
+	}
+
+	// log in again when the token expired
+	f.expireTokens()
+	if _, err := v.GetPrivateKey("test1"); err != nil {
+		t.Errorf("GetPrivateKey error after the token expired: %s", err)
+		return
+	}
+	if f.logins != 2 {
+		t.Errorf("Expected 2 logins, got %d", f.logins)
+	}
+}
@@ -0,0 +1,1559 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: management.proto
+
+package management
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Object struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Data                 []byte   `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Filename             string   `protobuf:"bytes,4,opt,name=filename,proto3" json:"filename,omitempty"`
+	ResourceVersion      string   `protobuf:"bytes,5,opt,name=resourceVersion,proto3" json:"resourceVersion,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Object) Reset()         { *m = Object{} }
+func (m *Object) String() string { return proto.CompactTextString(m) }
+func (*Object) ProtoMessage()    {}
+func (*Object) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{0}
+}
+
+func (m *Object) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Object.Unmarshal(m, b)
+}
+func (m *Object) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Object.Marshal(b, m, deterministic)
+}
+func (m *Object) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Object.Merge(m, src)
+}
+func (m *Object) XXX_Size() int {
+	return xxx_messageInfo_Object.Size(m)
+}
+func (m *Object) XXX_DiscardUnknown() {
+	xxx_messageInfo_Object.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Object proto.InternalMessageInfo
+
+func (m *Object) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Object) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Object) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *Object) GetFilename() string {
+	if m != nil {
+		return m.Filename
+	}
+	return ""
+}
+
+func (m *Object) GetResourceVersion() string {
+	if m != nil {
+		return m.ResourceVersion
+	}
+	return ""
+}
+
+type ListObjectsRequest struct {
+	Kinds                []string `protobuf:"bytes,1,rep,name=kinds,proto3" json:"kinds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListObjectsRequest) Reset()         { *m = ListObjectsRequest{} }
+func (m *ListObjectsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListObjectsRequest) ProtoMessage()    {}
+func (*ListObjectsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{1}
+}
+
+func (m *ListObjectsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListObjectsRequest.Unmarshal(m, b)
+}
+func (m *ListObjectsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListObjectsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListObjectsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListObjectsRequest.Merge(m, src)
+}
+func (m *ListObjectsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListObjectsRequest.Size(m)
+}
+func (m *ListObjectsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListObjectsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListObjectsRequest proto.InternalMessageInfo
+
+func (m *ListObjectsRequest) GetKinds() []string {
+	if m != nil {
+		return m.Kinds
+	}
+	return nil
+}
+
+type ListObjectsReply struct {
+	Objects              []*Object `protobuf:"bytes,1,rep,name=objects,proto3" json:"objects,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ListObjectsReply) Reset()         { *m = ListObjectsReply{} }
+func (m *ListObjectsReply) String() string { return proto.CompactTextString(m) }
+func (*ListObjectsReply) ProtoMessage()    {}
+func (*ListObjectsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{2}
+}
+
+func (m *ListObjectsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListObjectsReply.Unmarshal(m, b)
+}
+func (m *ListObjectsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListObjectsReply.Marshal(b, m, deterministic)
+}
+func (m *ListObjectsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListObjectsReply.Merge(m, src)
+}
+func (m *ListObjectsReply) XXX_Size() int {
+	return xxx_messageInfo_ListObjectsReply.Size(m)
+}
+func (m *ListObjectsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListObjectsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListObjectsReply proto.InternalMessageInfo
+
+func (m *ListObjectsReply) GetObjects() []*Object {
+	if m != nil {
+		return m.Objects
+	}
+	return nil
+}
+
+type GetObjectRequest struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetObjectRequest) Reset()         { *m = GetObjectRequest{} }
+func (m *GetObjectRequest) String() string { return proto.CompactTextString(m) }
+func (*GetObjectRequest) ProtoMessage()    {}
+func (*GetObjectRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{3}
+}
+
+func (m *GetObjectRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetObjectRequest.Unmarshal(m, b)
+}
+func (m *GetObjectRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetObjectRequest.Marshal(b, m, deterministic)
+}
+func (m *GetObjectRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetObjectRequest.Merge(m, src)
+}
+func (m *GetObjectRequest) XXX_Size() int {
+	return xxx_messageInfo_GetObjectRequest.Size(m)
+}
+func (m *GetObjectRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetObjectRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetObjectRequest proto.InternalMessageInfo
+
+func (m *GetObjectRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *GetObjectRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type GetObjectReply struct {
+	Object               *Object  `protobuf:"bytes,1,opt,name=object,proto3" json:"object,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetObjectReply) Reset()         { *m = GetObjectReply{} }
+func (m *GetObjectReply) String() string { return proto.CompactTextString(m) }
+func (*GetObjectReply) ProtoMessage()    {}
+func (*GetObjectReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{4}
+}
+
+func (m *GetObjectReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetObjectReply.Unmarshal(m, b)
+}
+func (m *GetObjectReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetObjectReply.Marshal(b, m, deterministic)
+}
+func (m *GetObjectReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetObjectReply.Merge(m, src)
+}
+func (m *GetObjectReply) XXX_Size() int {
+	return xxx_messageInfo_GetObjectReply.Size(m)
+}
+func (m *GetObjectReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetObjectReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetObjectReply proto.InternalMessageInfo
+
+func (m *GetObjectReply) GetObject() *Object {
+	if m != nil {
+		return m.Object
+	}
+	return nil
+}
+
+type ListPendingObjectsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListPendingObjectsRequest) Reset()         { *m = ListPendingObjectsRequest{} }
+func (m *ListPendingObjectsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPendingObjectsRequest) ProtoMessage()    {}
+func (*ListPendingObjectsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{5}
+}
+
+func (m *ListPendingObjectsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPendingObjectsRequest.Unmarshal(m, b)
+}
+func (m *ListPendingObjectsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPendingObjectsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListPendingObjectsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPendingObjectsRequest.Merge(m, src)
+}
+func (m *ListPendingObjectsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListPendingObjectsRequest.Size(m)
+}
+func (m *ListPendingObjectsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPendingObjectsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPendingObjectsRequest proto.InternalMessageInfo
+
+type PendingObject struct {
+	Object               *Object                     `protobuf:"bytes,1,opt,name=object,proto3" json:"object,omitempty"`
+	MissingDependencies  []*PendingObject_Dependency `protobuf:"bytes,2,rep,name=missingDependencies,proto3" json:"missingDependencies,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
+}
+
+func (m *PendingObject) Reset()         { *m = PendingObject{} }
+func (m *PendingObject) String() string { return proto.CompactTextString(m) }
+func (*PendingObject) ProtoMessage()    {}
+func (*PendingObject) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{6}
+}
+
+func (m *PendingObject) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PendingObject.Unmarshal(m, b)
+}
+func (m *PendingObject) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PendingObject.Marshal(b, m, deterministic)
+}
+func (m *PendingObject) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PendingObject.Merge(m, src)
+}
+func (m *PendingObject) XXX_Size() int {
+	return xxx_messageInfo_PendingObject.Size(m)
+}
+func (m *PendingObject) XXX_DiscardUnknown() {
+	xxx_messageInfo_PendingObject.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PendingObject proto.InternalMessageInfo
+
+func (m *PendingObject) GetObject() *Object {
+	if m != nil {
+		return m.Object
+	}
+	return nil
+}
+
+func (m *PendingObject) GetMissingDependencies() []*PendingObject_Dependency {
+	if m != nil {
+		return m.MissingDependencies
+	}
+	return nil
+}
+
+type PendingObject_Dependency struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PendingObject_Dependency) Reset()         { *m = PendingObject_Dependency{} }
+func (m *PendingObject_Dependency) String() string { return proto.CompactTextString(m) }
+func (*PendingObject_Dependency) ProtoMessage()    {}
+func (*PendingObject_Dependency) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{6, 0}
+}
+
+func (m *PendingObject_Dependency) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PendingObject_Dependency.Unmarshal(m, b)
+}
+func (m *PendingObject_Dependency) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PendingObject_Dependency.Marshal(b, m, deterministic)
+}
+func (m *PendingObject_Dependency) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PendingObject_Dependency.Merge(m, src)
+}
+func (m *PendingObject_Dependency) XXX_Size() int {
+	return xxx_messageInfo_PendingObject_Dependency.Size(m)
+}
+func (m *PendingObject_Dependency) XXX_DiscardUnknown() {
+	xxx_messageInfo_PendingObject_Dependency.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PendingObject_Dependency proto.InternalMessageInfo
+
+func (m *PendingObject_Dependency) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *PendingObject_Dependency) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type ListPendingObjectsReply struct {
+	PendingObjects       []*PendingObject `protobuf:"bytes,1,rep,name=pendingObjects,proto3" json:"pendingObjects,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *ListPendingObjectsReply) Reset()         { *m = ListPendingObjectsReply{} }
+func (m *ListPendingObjectsReply) String() string { return proto.CompactTextString(m) }
+func (*ListPendingObjectsReply) ProtoMessage()    {}
+func (*ListPendingObjectsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{7}
+}
+
+func (m *ListPendingObjectsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPendingObjectsReply.Unmarshal(m, b)
+}
+func (m *ListPendingObjectsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPendingObjectsReply.Marshal(b, m, deterministic)
+}
+func (m *ListPendingObjectsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPendingObjectsReply.Merge(m, src)
+}
+func (m *ListPendingObjectsReply) XXX_Size() int {
+	return xxx_messageInfo_ListPendingObjectsReply.Size(m)
+}
+func (m *ListPendingObjectsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPendingObjectsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPendingObjectsReply proto.InternalMessageInfo
+
+func (m *ListPendingObjectsReply) GetPendingObjects() []*PendingObject {
+	if m != nil {
+		return m.PendingObjects
+	}
+	return nil
+}
+
+type GetSnapshotRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetSnapshotRequest) Reset()         { *m = GetSnapshotRequest{} }
+func (m *GetSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*GetSnapshotRequest) ProtoMessage()    {}
+func (*GetSnapshotRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{8}
+}
+
+func (m *GetSnapshotRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetSnapshotRequest.Unmarshal(m, b)
+}
+func (m *GetSnapshotRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetSnapshotRequest.Marshal(b, m, deterministic)
+}
+func (m *GetSnapshotRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetSnapshotRequest.Merge(m, src)
+}
+func (m *GetSnapshotRequest) XXX_Size() int {
+	return xxx_messageInfo_GetSnapshotRequest.Size(m)
+}
+func (m *GetSnapshotRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetSnapshotRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetSnapshotRequest proto.InternalMessageInfo
+
+type GetSnapshotReply struct {
+	Version              string   `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Resources            []byte   `protobuf:"bytes,2,opt,name=resources,proto3" json:"resources,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetSnapshotReply) Reset()         { *m = GetSnapshotReply{} }
+func (m *GetSnapshotReply) String() string { return proto.CompactTextString(m) }
+func (*GetSnapshotReply) ProtoMessage()    {}
+func (*GetSnapshotReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{9}
+}
+
+func (m *GetSnapshotReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetSnapshotReply.Unmarshal(m, b)
+}
+func (m *GetSnapshotReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetSnapshotReply.Marshal(b, m, deterministic)
+}
+func (m *GetSnapshotReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetSnapshotReply.Merge(m, src)
+}
+func (m *GetSnapshotReply) XXX_Size() int {
+	return xxx_messageInfo_GetSnapshotReply.Size(m)
+}
+func (m *GetSnapshotReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetSnapshotReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetSnapshotReply proto.InternalMessageInfo
+
+func (m *GetSnapshotReply) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *GetSnapshotReply) GetResources() []byte {
+	if m != nil {
+		return m.Resources
+	}
+	return nil
+}
+
+type ListNodesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListNodesRequest) Reset()         { *m = ListNodesRequest{} }
+func (m *ListNodesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListNodesRequest) ProtoMessage()    {}
+func (*ListNodesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{10}
+}
+
+func (m *ListNodesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListNodesRequest.Unmarshal(m, b)
+}
+func (m *ListNodesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListNodesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListNodesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListNodesRequest.Merge(m, src)
+}
+func (m *ListNodesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListNodesRequest.Size(m)
+}
+func (m *ListNodesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListNodesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListNodesRequest proto.InternalMessageInfo
+
+type Node struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Cluster              string   `protobuf:"bytes,2,opt,name=cluster,proto3" json:"cluster,omitempty"`
+	Streams              int32    `protobuf:"varint,3,opt,name=streams,proto3" json:"streams,omitempty"`
+	SnapshotVersion      string   `protobuf:"bytes,4,opt,name=snapshotVersion,proto3" json:"snapshotVersion,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Node) Reset()         { *m = Node{} }
+func (m *Node) String() string { return proto.CompactTextString(m) }
+func (*Node) ProtoMessage()    {}
+func (*Node) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{11}
+}
+
+func (m *Node) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Node.Unmarshal(m, b)
+}
+func (m *Node) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Node.Marshal(b, m, deterministic)
+}
+func (m *Node) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Node.Merge(m, src)
+}
+func (m *Node) XXX_Size() int {
+	return xxx_messageInfo_Node.Size(m)
+}
+func (m *Node) XXX_DiscardUnknown() {
+	xxx_messageInfo_Node.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Node proto.InternalMessageInfo
+
+func (m *Node) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Node) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *Node) GetStreams() int32 {
+	if m != nil {
+		return m.Streams
+	}
+	return 0
+}
+
+func (m *Node) GetSnapshotVersion() string {
+	if m != nil {
+		return m.SnapshotVersion
+	}
+	return ""
+}
+
+type ListNodesReply struct {
+	Nodes                []*Node  `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListNodesReply) Reset()         { *m = ListNodesReply{} }
+func (m *ListNodesReply) String() string { return proto.CompactTextString(m) }
+func (*ListNodesReply) ProtoMessage()    {}
+func (*ListNodesReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{12}
+}
+
+func (m *ListNodesReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListNodesReply.Unmarshal(m, b)
+}
+func (m *ListNodesReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListNodesReply.Marshal(b, m, deterministic)
+}
+func (m *ListNodesReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListNodesReply.Merge(m, src)
+}
+func (m *ListNodesReply) XXX_Size() int {
+	return xxx_messageInfo_ListNodesReply.Size(m)
+}
+func (m *ListNodesReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListNodesReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListNodesReply proto.InternalMessageInfo
+
+func (m *ListNodesReply) GetNodes() []*Node {
+	if m != nil {
+		return m.Nodes
+	}
+	return nil
+}
+
+type ListCertificatesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListCertificatesRequest) Reset()         { *m = ListCertificatesRequest{} }
+func (m *ListCertificatesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListCertificatesRequest) ProtoMessage()    {}
+func (*ListCertificatesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{13}
+}
+
+func (m *ListCertificatesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListCertificatesRequest.Unmarshal(m, b)
+}
+func (m *ListCertificatesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListCertificatesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListCertificatesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListCertificatesRequest.Merge(m, src)
+}
+func (m *ListCertificatesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListCertificatesRequest.Size(m)
+}
+func (m *ListCertificatesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListCertificatesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListCertificatesRequest proto.InternalMessageInfo
+
+type Certificate struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Domains              []string `protobuf:"bytes,2,rep,name=domains,proto3" json:"domains,omitempty"`
+	NotBefore            int64    `protobuf:"varint,3,opt,name=notBefore,proto3" json:"notBefore,omitempty"`
+	NotAfter             int64    `protobuf:"varint,4,opt,name=notAfter,proto3" json:"notAfter,omitempty"`
+	RenewAfter           int64    `protobuf:"varint,5,opt,name=renewAfter,proto3" json:"renewAfter,omitempty"`
+	Error                string   `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Certificate) Reset()         { *m = Certificate{} }
+func (m *Certificate) String() string { return proto.CompactTextString(m) }
+func (*Certificate) ProtoMessage()    {}
+func (*Certificate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{14}
+}
+
+func (m *Certificate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Certificate.Unmarshal(m, b)
+}
+func (m *Certificate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Certificate.Marshal(b, m, deterministic)
+}
+func (m *Certificate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Certificate.Merge(m, src)
+}
+func (m *Certificate) XXX_Size() int {
+	return xxx_messageInfo_Certificate.Size(m)
+}
+func (m *Certificate) XXX_DiscardUnknown() {
+	xxx_messageInfo_Certificate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Certificate proto.InternalMessageInfo
+
+func (m *Certificate) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Certificate) GetDomains() []string {
+	if m != nil {
+		return m.Domains
+	}
+	return nil
+}
+
+func (m *Certificate) GetNotBefore() int64 {
+	if m != nil {
+		return m.NotBefore
+	}
+	return 0
+}
+
+func (m *Certificate) GetNotAfter() int64 {
+	if m != nil {
+		return m.NotAfter
+	}
+	return 0
+}
+
+func (m *Certificate) GetRenewAfter() int64 {
+	if m != nil {
+		return m.RenewAfter
+	}
+	return 0
+}
+
+func (m *Certificate) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ListCertificatesReply struct {
+	Certificates         []*Certificate `protobuf:"bytes,1,rep,name=certificates,proto3" json:"certificates,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *ListCertificatesReply) Reset()         { *m = ListCertificatesReply{} }
+func (m *ListCertificatesReply) String() string { return proto.CompactTextString(m) }
+func (*ListCertificatesReply) ProtoMessage()    {}
+func (*ListCertificatesReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{15}
+}
+
+func (m *ListCertificatesReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListCertificatesReply.Unmarshal(m, b)
+}
+func (m *ListCertificatesReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListCertificatesReply.Marshal(b, m, deterministic)
+}
+func (m *ListCertificatesReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListCertificatesReply.Merge(m, src)
+}
+func (m *ListCertificatesReply) XXX_Size() int {
+	return xxx_messageInfo_ListCertificatesReply.Size(m)
+}
+func (m *ListCertificatesReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListCertificatesReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListCertificatesReply proto.InternalMessageInfo
+
+func (m *ListCertificatesReply) GetCertificates() []*Certificate {
+	if m != nil {
+		return m.Certificates
+	}
+	return nil
+}
+
+type TriggerRenewalRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TriggerRenewalRequest) Reset()         { *m = TriggerRenewalRequest{} }
+func (m *TriggerRenewalRequest) String() string { return proto.CompactTextString(m) }
+func (*TriggerRenewalRequest) ProtoMessage()    {}
+func (*TriggerRenewalRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{16}
+}
+
+func (m *TriggerRenewalRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TriggerRenewalRequest.Unmarshal(m, b)
+}
+func (m *TriggerRenewalRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TriggerRenewalRequest.Marshal(b, m, deterministic)
+}
+func (m *TriggerRenewalRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TriggerRenewalRequest.Merge(m, src)
+}
+func (m *TriggerRenewalRequest) XXX_Size() int {
+	return xxx_messageInfo_TriggerRenewalRequest.Size(m)
+}
+func (m *TriggerRenewalRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TriggerRenewalRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TriggerRenewalRequest proto.InternalMessageInfo
+
+type TriggerImportRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TriggerImportRequest) Reset()         { *m = TriggerImportRequest{} }
+func (m *TriggerImportRequest) String() string { return proto.CompactTextString(m) }
+func (*TriggerImportRequest) ProtoMessage()    {}
+func (*TriggerImportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{17}
+}
+
+func (m *TriggerImportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TriggerImportRequest.Unmarshal(m, b)
+}
+func (m *TriggerImportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TriggerImportRequest.Marshal(b, m, deterministic)
+}
+func (m *TriggerImportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TriggerImportRequest.Merge(m, src)
+}
+func (m *TriggerImportRequest) XXX_Size() int {
+	return xxx_messageInfo_TriggerImportRequest.Size(m)
+}
+func (m *TriggerImportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TriggerImportRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TriggerImportRequest proto.InternalMessageInfo
+
+type TriggerReply struct {
+	Result               bool     `protobuf:"varint,1,opt,name=result,proto3" json:"result,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TriggerReply) Reset()         { *m = TriggerReply{} }
+func (m *TriggerReply) String() string { return proto.CompactTextString(m) }
+func (*TriggerReply) ProtoMessage()    {}
+func (*TriggerReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{18}
+}
+
+func (m *TriggerReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TriggerReply.Unmarshal(m, b)
+}
+func (m *TriggerReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TriggerReply.Marshal(b, m, deterministic)
+}
+func (m *TriggerReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TriggerReply.Merge(m, src)
+}
+func (m *TriggerReply) XXX_Size() int {
+	return xxx_messageInfo_TriggerReply.Size(m)
+}
+func (m *TriggerReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_TriggerReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TriggerReply proto.InternalMessageInfo
+
+func (m *TriggerReply) GetResult() bool {
+	if m != nil {
+		return m.Result
+	}
+	return false
+}
+
+type ApplyObjectRequest struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Format               string   `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	ResourceVersion      string   `protobuf:"bytes,3,opt,name=resourceVersion,proto3" json:"resourceVersion,omitempty"`
+	DryRun               bool     `protobuf:"varint,4,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ApplyObjectRequest) Reset()         { *m = ApplyObjectRequest{} }
+func (m *ApplyObjectRequest) String() string { return proto.CompactTextString(m) }
+func (*ApplyObjectRequest) ProtoMessage()    {}
+func (*ApplyObjectRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{19}
+}
+
+func (m *ApplyObjectRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ApplyObjectRequest.Unmarshal(m, b)
+}
+func (m *ApplyObjectRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ApplyObjectRequest.Marshal(b, m, deterministic)
+}
+func (m *ApplyObjectRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ApplyObjectRequest.Merge(m, src)
+}
+func (m *ApplyObjectRequest) XXX_Size() int {
+	return xxx_messageInfo_ApplyObjectRequest.Size(m)
+}
+func (m *ApplyObjectRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ApplyObjectRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ApplyObjectRequest proto.InternalMessageInfo
+
+func (m *ApplyObjectRequest) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *ApplyObjectRequest) GetFormat() string {
+	if m != nil {
+		return m.Format
+	}
+	return ""
+}
+
+func (m *ApplyObjectRequest) GetResourceVersion() string {
+	if m != nil {
+		return m.ResourceVersion
+	}
+	return ""
+}
+
+func (m *ApplyObjectRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+type ApplyObjectReply struct {
+	Object               *Object                     `protobuf:"bytes,1,opt,name=object,proto3" json:"object,omitempty"`
+	Created              bool                        `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	MissingDependencies  []*PendingObject_Dependency `protobuf:"bytes,3,rep,name=missingDependencies,proto3" json:"missingDependencies,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
+}
+
+func (m *ApplyObjectReply) Reset()         { *m = ApplyObjectReply{} }
+func (m *ApplyObjectReply) String() string { return proto.CompactTextString(m) }
+func (*ApplyObjectReply) ProtoMessage()    {}
+func (*ApplyObjectReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{20}
+}
+
+func (m *ApplyObjectReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ApplyObjectReply.Unmarshal(m, b)
+}
+func (m *ApplyObjectReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ApplyObjectReply.Marshal(b, m, deterministic)
+}
+func (m *ApplyObjectReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ApplyObjectReply.Merge(m, src)
+}
+func (m *ApplyObjectReply) XXX_Size() int {
+	return xxx_messageInfo_ApplyObjectReply.Size(m)
+}
+func (m *ApplyObjectReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ApplyObjectReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ApplyObjectReply proto.InternalMessageInfo
+
+func (m *ApplyObjectReply) GetObject() *Object {
+	if m != nil {
+		return m.Object
+	}
+	return nil
+}
+
+func (m *ApplyObjectReply) GetCreated() bool {
+	if m != nil {
+		return m.Created
+	}
+	return false
+}
+
+func (m *ApplyObjectReply) GetMissingDependencies() []*PendingObject_Dependency {
+	if m != nil {
+		return m.MissingDependencies
+	}
+	return nil
+}
+
+type DeleteObjectRequest struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	ResourceVersion      string   `protobuf:"bytes,3,opt,name=resourceVersion,proto3" json:"resourceVersion,omitempty"`
+	DryRun               bool     `protobuf:"varint,4,opt,name=dryRun,proto3" json:"dryRun,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteObjectRequest) Reset()         { *m = DeleteObjectRequest{} }
+func (m *DeleteObjectRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteObjectRequest) ProtoMessage()    {}
+func (*DeleteObjectRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{21}
+}
+
+func (m *DeleteObjectRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteObjectRequest.Unmarshal(m, b)
+}
+func (m *DeleteObjectRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteObjectRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteObjectRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteObjectRequest.Merge(m, src)
+}
+func (m *DeleteObjectRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteObjectRequest.Size(m)
+}
+func (m *DeleteObjectRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteObjectRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteObjectRequest proto.InternalMessageInfo
+
+func (m *DeleteObjectRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *DeleteObjectRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DeleteObjectRequest) GetResourceVersion() string {
+	if m != nil {
+		return m.ResourceVersion
+	}
+	return ""
+}
+
+func (m *DeleteObjectRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+type DeleteObjectReply struct {
+	Filename             string   `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteObjectReply) Reset()         { *m = DeleteObjectReply{} }
+func (m *DeleteObjectReply) String() string { return proto.CompactTextString(m) }
+func (*DeleteObjectReply) ProtoMessage()    {}
+func (*DeleteObjectReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_edc174f991dc0a25, []int{22}
+}
+
+func (m *DeleteObjectReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteObjectReply.Unmarshal(m, b)
+}
+func (m *DeleteObjectReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteObjectReply.Marshal(b, m, deterministic)
+}
+func (m *DeleteObjectReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteObjectReply.Merge(m, src)
+}
+func (m *DeleteObjectReply) XXX_Size() int {
+	return xxx_messageInfo_DeleteObjectReply.Size(m)
+}
+func (m *DeleteObjectReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteObjectReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteObjectReply proto.InternalMessageInfo
+
+func (m *DeleteObjectReply) GetFilename() string {
+	if m != nil {
+		return m.Filename
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Object)(nil), "Object")
+	proto.RegisterType((*ListObjectsRequest)(nil), "ListObjectsRequest")
+	proto.RegisterType((*ListObjectsReply)(nil), "ListObjectsReply")
+	proto.RegisterType((*GetObjectRequest)(nil), "GetObjectRequest")
+	proto.RegisterType((*GetObjectReply)(nil), "GetObjectReply")
+	proto.RegisterType((*ListPendingObjectsRequest)(nil), "ListPendingObjectsRequest")
+	proto.RegisterType((*PendingObject)(nil), "PendingObject")
+	proto.RegisterType((*PendingObject_Dependency)(nil), "PendingObject.Dependency")
+	proto.RegisterType((*ListPendingObjectsReply)(nil), "ListPendingObjectsReply")
+	proto.RegisterType((*GetSnapshotRequest)(nil), "GetSnapshotRequest")
+	proto.RegisterType((*GetSnapshotReply)(nil), "GetSnapshotReply")
+	proto.RegisterType((*ListNodesRequest)(nil), "ListNodesRequest")
+	proto.RegisterType((*Node)(nil), "Node")
+	proto.RegisterType((*ListNodesReply)(nil), "ListNodesReply")
+	proto.RegisterType((*ListCertificatesRequest)(nil), "ListCertificatesRequest")
+	proto.RegisterType((*Certificate)(nil), "Certificate")
+	proto.RegisterType((*ListCertificatesReply)(nil), "ListCertificatesReply")
+	proto.RegisterType((*TriggerRenewalRequest)(nil), "TriggerRenewalRequest")
+	proto.RegisterType((*TriggerImportRequest)(nil), "TriggerImportRequest")
+	proto.RegisterType((*TriggerReply)(nil), "TriggerReply")
+	proto.RegisterType((*ApplyObjectRequest)(nil), "ApplyObjectRequest")
+	proto.RegisterType((*ApplyObjectReply)(nil), "ApplyObjectReply")
+	proto.RegisterType((*DeleteObjectRequest)(nil), "DeleteObjectRequest")
+	proto.RegisterType((*DeleteObjectReply)(nil), "DeleteObjectReply")
+}
+
+func init() { proto.RegisterFile("management.proto", fileDescriptor_edc174f991dc0a25) }
+
+var fileDescriptor_edc174f991dc0a25 = []byte{
+	// 858 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x56, 0xcd, 0x8e, 0x23, 0x35,
+	0x10, 0x4e, 0x4f, 0x7e, 0x66, 0x52, 0x93, 0xc9, 0x26, 0xce, 0x4c, 0xb6, 0xa7, 0x17, 0xc1, 0xe0,
+	0x03, 0x1a, 0x21, 0x61, 0xd8, 0xe5, 0x4f, 0xec, 0x6d, 0x61, 0xc5, 0x6a, 0x61, 0xf9, 0x33, 0x88,
+	0x7b, 0x6f, 0xba, 0x12, 0x1a, 0x12, 0x77, 0xb0, 0x1d, 0x50, 0x4e, 0x48, 0x1c, 0x79, 0x04, 0x5e,
+	0x81, 0x23, 0x8f, 0xc4, 0x8b, 0x20, 0xdb, 0xdd, 0x1d, 0x3b, 0xe9, 0x11, 0xa3, 0x11, 0xb7, 0xae,
+	0xaf, 0xca, 0xf6, 0x57, 0x95, 0xaa, 0xaf, 0x02, 0xa3, 0x55, 0x2a, 0xd2, 0x05, 0xae, 0x50, 0x68,
+	0xb6, 0x96, 0x85, 0x2e, 0xe8, 0x1f, 0x11, 0xf4, 0xbe, 0x7a, 0xf9, 0x23, 0xce, 0x34, 0x21, 0xd0,
+	0xf9, 0x29, 0x17, 0x59, 0x1c, 0x5d, 0x45, 0xd7, 0x7d, 0x6e, 0xbf, 0x0d, 0x26, 0xd2, 0x15, 0xc6,
+	0x47, 0x0e, 0x33, 0xdf, 0x06, 0xcb, 0x52, 0x9d, 0xc6, 0xed, 0xab, 0xe8, 0x7a, 0xc0, 0xed, 0x37,
+	0x49, 0xe0, 0x64, 0x9e, 0x2f, 0xd1, 0xc6, 0x76, 0x6c, 0x6c, 0x6d, 0x93, 0x6b, 0xb8, 0x27, 0x51,
+	0x15, 0x1b, 0x39, 0xc3, 0xef, 0x51, 0xaa, 0xbc, 0x10, 0x71, 0xd7, 0x86, 0xec, 0xc3, 0xf4, 0x4d,
+	0x20, 0x2f, 0x72, 0xa5, 0x1d, 0x1f, 0xc5, 0xf1, 0xe7, 0x0d, 0x2a, 0x4d, 0xce, 0xa1, 0x6b, 0xb8,
+	0xa8, 0x38, 0xba, 0x6a, 0x5f, 0xf7, 0xb9, 0x33, 0xe8, 0xfb, 0x30, 0x0a, 0x62, 0xd7, 0xcb, 0x2d,
+	0x79, 0x1d, 0x8e, 0x0b, 0x67, 0xdb, 0xd8, 0xd3, 0x47, 0xc7, 0xcc, 0xf9, 0x79, 0x85, 0xd3, 0xc7,
+	0x30, 0x7a, 0x86, 0xe5, 0xa9, 0xea, 0x81, 0x5b, 0x26, 0x4e, 0x1f, 0xc2, 0xd0, 0x3b, 0x6b, 0x1e,
+	0x7c, 0x0d, 0x7a, 0xee, 0x62, 0x7b, 0xd6, 0x7b, 0xaf, 0x84, 0xe9, 0x03, 0xb8, 0x34, 0x2c, 0xbf,
+	0x46, 0x91, 0xe5, 0x62, 0x11, 0x26, 0x46, 0xff, 0x8e, 0xe0, 0x2c, 0xf0, 0xfc, 0xe7, 0x7d, 0xe4,
+	0x73, 0x98, 0xac, 0x72, 0xa5, 0x72, 0xb1, 0x78, 0x8a, 0x6b, 0x14, 0x19, 0x8a, 0x59, 0x8e, 0x2a,
+	0x3e, 0xb2, 0xd9, 0x5e, 0xb2, 0xe0, 0x36, 0x56, 0x87, 0x6c, 0x79, 0xd3, 0xa9, 0xe4, 0x3d, 0x80,
+	0x5d, 0xc8, 0xad, 0xab, 0xf0, 0x0d, 0xdc, 0x6f, 0x4a, 0xc9, 0x94, 0xe3, 0x03, 0x18, 0xae, 0x03,
+	0xb8, 0xfc, 0x19, 0x86, 0x21, 0x31, 0xbe, 0x17, 0x45, 0xcf, 0x81, 0x3c, 0x43, 0xfd, 0xad, 0x48,
+	0xd7, 0xea, 0x87, 0xa2, 0xfa, 0x59, 0xe8, 0x67, 0x30, 0x0a, 0x50, 0xf3, 0x42, 0x0c, 0xc7, 0xbf,
+	0x94, 0x3d, 0xe4, 0x78, 0x56, 0x26, 0x79, 0x05, 0xfa, 0x55, 0x3b, 0x29, 0xcb, 0x77, 0xc0, 0x77,
+	0x00, 0x25, 0xae, 0x5b, 0xbe, 0x2c, 0x32, 0xac, 0xcb, 0xaf, 0xa1, 0x63, 0x6c, 0x32, 0x84, 0xa3,
+	0xbc, 0x4a, 0xfb, 0x28, 0xcf, 0xcc, 0x1b, 0xb3, 0xe5, 0x46, 0x69, 0x94, 0x65, 0xde, 0x95, 0x69,
+	0x3c, 0x4a, 0x4b, 0x4c, 0x57, 0xca, 0x36, 0x7f, 0x97, 0x57, 0xa6, 0xe9, 0x71, 0x55, 0x12, 0xad,
+	0x7a, 0xdc, 0x8d, 0xc1, 0x3e, 0x4c, 0xdf, 0x82, 0xa1, 0xc7, 0xc4, 0xe4, 0xf4, 0x00, 0xba, 0xc2,
+	0x58, 0x65, 0xb1, 0xba, 0xcc, 0xf8, 0xb8, 0xc3, 0xe8, 0xa5, 0xab, 0xf6, 0x27, 0x28, 0x75, 0x3e,
+	0xcf, 0x67, 0xa9, 0xde, 0xf1, 0xff, 0x2b, 0x82, 0x53, 0x0f, 0xaf, 0x7f, 0xac, 0xc8, 0x9b, 0xd5,
+	0x18, 0x8e, 0xb3, 0x62, 0x95, 0xe6, 0xc2, 0xf5, 0x48, 0x9f, 0x57, 0xa6, 0xa9, 0x97, 0x28, 0xf4,
+	0xc7, 0x38, 0x2f, 0x24, 0xda, 0x6c, 0xda, 0x7c, 0x07, 0x98, 0x79, 0x16, 0x85, 0x7e, 0x32, 0x37,
+	0x45, 0xe8, 0x58, 0x67, 0x6d, 0x93, 0x57, 0x01, 0x24, 0x0a, 0xfc, 0xd5, 0x79, 0xbb, 0xd6, 0xeb,
+	0x21, 0x66, 0x5e, 0x51, 0xca, 0x42, 0xc6, 0x3d, 0x4b, 0xc4, 0x19, 0xf4, 0x39, 0x5c, 0x1c, 0x26,
+	0x62, 0xd2, 0x7f, 0x07, 0x06, 0x33, 0x0f, 0x2c, 0xab, 0x30, 0x60, 0x5e, 0x24, 0x0f, 0x22, 0xe8,
+	0x7d, 0xb8, 0xf8, 0x4e, 0xe6, 0x8b, 0x05, 0x4a, 0x6e, 0x5e, 0x4d, 0x97, 0x55, 0x45, 0xa6, 0x70,
+	0x5e, 0x3a, 0x9e, 0xaf, 0xd6, 0x85, 0xac, 0x3b, 0xe9, 0x0d, 0x18, 0xd4, 0x07, 0xcc, 0x93, 0x53,
+	0xe8, 0x49, 0x54, 0x9b, 0xa5, 0x1b, 0xb3, 0x13, 0x5e, 0x5a, 0xf4, 0xf7, 0x08, 0xc8, 0x93, 0xf5,
+	0x7a, 0xb9, 0x3d, 0xd0, 0x07, 0x2b, 0x78, 0x91, 0x27, 0x78, 0x53, 0xe8, 0xcd, 0x0b, 0xb9, 0x4a,
+	0x75, 0xd9, 0x23, 0xa5, 0xd5, 0x24, 0x76, 0xed, 0x46, 0xb1, 0x33, 0x37, 0x64, 0x72, 0xcb, 0x37,
+	0xae, 0x53, 0x4e, 0x78, 0x69, 0xd1, 0x3f, 0x23, 0x18, 0x05, 0x24, 0x6e, 0x23, 0x34, 0xb6, 0x69,
+	0x25, 0xa6, 0x1a, 0x33, 0x4b, 0xe8, 0x84, 0x57, 0xe6, 0x4d, 0x92, 0xd1, 0xbe, 0x8b, 0x64, 0xd0,
+	0xdf, 0x60, 0xf2, 0x14, 0x97, 0xa8, 0xf1, 0x4e, 0x0a, 0xfa, 0x3f, 0x54, 0xe7, 0x6d, 0x18, 0x87,
+	0x04, 0x4c, 0x75, 0xfc, 0xed, 0x13, 0x85, 0xdb, 0xe7, 0xd1, 0x3f, 0x1d, 0x80, 0x2f, 0xea, 0xad,
+	0x47, 0x3e, 0x84, 0x53, 0x6f, 0x6d, 0x90, 0x09, 0x3b, 0x5c, 0x38, 0xc9, 0x98, 0xed, 0x6f, 0x16,
+	0xda, 0x22, 0x0f, 0xa1, 0x5f, 0x8b, 0x3f, 0x19, 0xb3, 0xfd, 0x25, 0x92, 0xdc, 0x63, 0xe1, 0x6e,
+	0xa0, 0x2d, 0xf2, 0xc2, 0xad, 0xb3, 0x50, 0x29, 0x49, 0xc2, 0x6e, 0xdc, 0x08, 0x49, 0xcc, 0x6e,
+	0x90, 0x56, 0xda, 0x32, 0xcc, 0x3d, 0x39, 0x24, 0x13, 0x76, 0x28, 0x99, 0xc9, 0x98, 0xed, 0x2b,
+	0xa6, 0x63, 0x5e, 0x2b, 0x0e, 0x19, 0xb3, 0xfa, 0x7b, 0xc7, 0x3c, 0x14, 0x24, 0xda, 0x22, 0x9f,
+	0x3a, 0xb9, 0xf4, 0x87, 0x95, 0xc4, 0x6c, 0x1f, 0xaa, 0x2e, 0x98, 0xb2, 0xc6, 0xc9, 0xa6, 0x2d,
+	0xf2, 0x11, 0x0c, 0xc3, 0x49, 0x25, 0x53, 0xd6, 0x38, 0xba, 0xc9, 0x19, 0xf3, 0x27, 0xd4, 0xa6,
+	0x7b, 0x16, 0xcc, 0x32, 0xb9, 0x60, 0x4d, 0xb3, 0xdd, 0x74, 0xf0, 0xd4, 0x1b, 0x1f, 0x32, 0x61,
+	0x87, 0x13, 0x9d, 0x8c, 0xd9, 0xfe, 0x84, 0xd1, 0x16, 0x79, 0x0c, 0x03, 0xbf, 0xb5, 0xc8, 0x39,
+	0x6b, 0x68, 0xf5, 0x84, 0xb0, 0x83, 0xfe, 0xa3, 0xad, 0x97, 0x3d, 0xfb, 0x6f, 0xea, 0xdd, 0x7f,
+	0x07, 0x00, 0x83, 0xb1, 0x7c, 0xfe, 0x61, 0x09, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ManagementClient is the client API for Management service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ManagementClient interface {
+	ListObjects(ctx context.Context, in *ListObjectsRequest, opts ...grpc.CallOption) (*ListObjectsReply, error)
+	GetObject(ctx context.Context, in *GetObjectRequest, opts ...grpc.CallOption) (*GetObjectReply, error)
+	ListPendingObjects(ctx context.Context, in *ListPendingObjectsRequest, opts ...grpc.CallOption) (*ListPendingObjectsReply, error)
+	GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*GetSnapshotReply, error)
+	ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesReply, error)
+	ListCertificates(ctx context.Context, in *ListCertificatesRequest, opts ...grpc.CallOption) (*ListCertificatesReply, error)
+	TriggerRenewal(ctx context.Context, in *TriggerRenewalRequest, opts ...grpc.CallOption) (*TriggerReply, error)
+	TriggerImport(ctx context.Context, in *TriggerImportRequest, opts ...grpc.CallOption) (*TriggerReply, error)
+	ApplyObject(ctx context.Context, in *ApplyObjectRequest, opts ...grpc.CallOption) (*ApplyObjectReply, error)
+	DeleteObject(ctx context.Context, in *DeleteObjectRequest, opts ...grpc.CallOption) (*DeleteObjectReply, error)
+}
+
+type managementClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewManagementClient(cc *grpc.ClientConn) ManagementClient {
+	return &managementClient{cc}
+}
+
+func (c *managementClient) ListObjects(ctx context.Context, in *ListObjectsRequest, opts ...grpc.CallOption) (*ListObjectsReply, error) {
+	out := new(ListObjectsReply)
+	err := c.cc.Invoke(ctx, "/Management/ListObjects", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) GetObject(ctx context.Context, in *GetObjectRequest, opts ...grpc.CallOption) (*GetObjectReply, error) {
+	out := new(GetObjectReply)
+	err := c.cc.Invoke(ctx, "/Management/GetObject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) ListPendingObjects(ctx context.Context, in *ListPendingObjectsRequest, opts ...grpc.CallOption) (*ListPendingObjectsReply, error) {
+	out := new(ListPendingObjectsReply)
+	err := c.cc.Invoke(ctx, "/Management/ListPendingObjects", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*GetSnapshotReply, error) {
+	out := new(GetSnapshotReply)
+	err := c.cc.Invoke(ctx, "/Management/GetSnapshot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesReply, error) {
+	out := new(ListNodesReply)
+	err := c.cc.Invoke(ctx, "/Management/ListNodes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) ListCertificates(ctx context.Context, in *ListCertificatesRequest, opts ...grpc.CallOption) (*ListCertificatesReply, error) {
+	out := new(ListCertificatesReply)
+	err := c.cc.Invoke(ctx, "/Management/ListCertificates", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) TriggerRenewal(ctx context.Context, in *TriggerRenewalRequest, opts ...grpc.CallOption) (*TriggerReply, error) {
+	out := new(TriggerReply)
+	err := c.cc.Invoke(ctx, "/Management/TriggerRenewal", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) TriggerImport(ctx context.Context, in *TriggerImportRequest, opts ...grpc.CallOption) (*TriggerReply, error) {
+	out := new(TriggerReply)
+	err := c.cc.Invoke(ctx, "/Management/TriggerImport", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) ApplyObject(ctx context.Context, in *ApplyObjectRequest, opts ...grpc.CallOption) (*ApplyObjectReply, error) {
+	out := new(ApplyObjectReply)
+	err := c.cc.Invoke(ctx, "/Management/ApplyObject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) DeleteObject(ctx context.Context, in *DeleteObjectRequest, opts ...grpc.CallOption) (*DeleteObjectReply, error) {
+	out := new(DeleteObjectReply)
+	err := c.cc.Invoke(ctx, "/Management/DeleteObject", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ManagementServer is the server API for Management service.
+type ManagementServer interface {
+	ListObjects(context.Context, *ListObjectsRequest) (*ListObjectsReply, error)
+	GetObject(context.Context, *GetObjectRequest) (*GetObjectReply, error)
+	ListPendingObjects(context.Context, *ListPendingObjectsRequest) (*ListPendingObjectsReply, error)
+	GetSnapshot(context.Context, *GetSnapshotRequest) (*GetSnapshotReply, error)
+	ListNodes(context.Context, *ListNodesRequest) (*ListNodesReply, error)
+	ListCertificates(context.Context, *ListCertificatesRequest) (*ListCertificatesReply, error)
+	TriggerRenewal(context.Context, *TriggerRenewalRequest) (*TriggerReply, error)
+	TriggerImport(context.Context, *TriggerImportRequest) (*TriggerReply, error)
+	ApplyObject(context.Context, *ApplyObjectRequest) (*ApplyObjectReply, error)
+	DeleteObject(context.Context, *DeleteObjectRequest) (*DeleteObjectReply, error)
+}
+
+func RegisterManagementServer(s *grpc.Server, srv ManagementServer) {
+	s.RegisterService(&_Management_serviceDesc, srv)
+}
+
+func _Management_ListObjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListObjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ListObjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/ListObjects",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ListObjects(ctx, req.(*ListObjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_GetObject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetObjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).GetObject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/GetObject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).GetObject(ctx, req.(*GetObjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_ListPendingObjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPendingObjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ListPendingObjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/ListPendingObjects",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ListPendingObjects(ctx, req.(*ListPendingObjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_GetSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).GetSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/GetSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).GetSnapshot(ctx, req.(*GetSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_ListNodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ListNodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/ListNodes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ListNodes(ctx, req.(*ListNodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_ListCertificates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCertificatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ListCertificates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/ListCertificates",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ListCertificates(ctx, req.(*ListCertificatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_TriggerRenewal_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerRenewalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).TriggerRenewal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/TriggerRenewal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).TriggerRenewal(ctx, req.(*TriggerRenewalRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_TriggerImport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerImportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).TriggerImport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/TriggerImport",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).TriggerImport(ctx, req.(*TriggerImportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_ApplyObject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApplyObjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ApplyObject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/ApplyObject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ApplyObject(ctx, req.(*ApplyObjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_DeleteObject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteObjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).DeleteObject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Management/DeleteObject",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).DeleteObject(ctx, req.(*DeleteObjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Management_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "Management",
+	HandlerType: (*ManagementServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListObjects",
+			Handler:    _Management_ListObjects_Handler,
+		},
+		{
+			MethodName: "GetObject",
+			Handler:    _Management_GetObject_Handler,
+		},
+		{
+			MethodName: "ListPendingObjects",
+			Handler:    _Management_ListPendingObjects_Handler,
+		},
+		{
+			MethodName: "GetSnapshot",
+			Handler:    _Management_GetSnapshot_Handler,
+		},
+		{
+			MethodName: "ListNodes",
+			Handler:    _Management_ListNodes_Handler,
+		},
+		{
+			MethodName: "ListCertificates",
+			Handler:    _Management_ListCertificates_Handler,
+		},
+		{
+			MethodName: "TriggerRenewal",
+			Handler:    _Management_TriggerRenewal_Handler,
+		},
+		{
+			MethodName: "TriggerImport",
+			Handler:    _Management_TriggerImport_Handler,
+		},
+		{
+			MethodName: "ApplyObject",
+			Handler:    _Management_ApplyObject_Handler,
+		},
+		{
+			MethodName: "DeleteObject",
+			Handler:    _Management_DeleteObject_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "management.proto",
+}
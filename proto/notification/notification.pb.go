@@ -24,6 +24,8 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
 type NotificationRequest struct {
 	NotificationItem     []*NotificationRequest_NotificationItem `protobuf:"bytes,1,rep,name=notificationItem,proto3" json:"notificationItem,omitempty"`
+	SourceId             string                                  `protobuf:"bytes,2,opt,name=sourceId,proto3" json:"sourceId,omitempty"`
+	Sequence             uint64                                  `protobuf:"varint,3,opt,name=sequence,proto3" json:"sequence,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}                                `json:"-"`
 	XXX_unrecognized     []byte                                  `json:"-"`
 	XXX_sizecache        int32                                   `json:"-"`
@@ -61,6 +63,20 @@ func (m *NotificationRequest) GetNotificationItem() []*NotificationRequest_Notif
 	return nil
 }
 
+func (m *NotificationRequest) GetSourceId() string {
+	if m != nil {
+		return m.SourceId
+	}
+	return ""
+}
+
+func (m *NotificationRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
 type NotificationRequest_NotificationItem struct {
 	Filename             string   `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
 	EventName            string   `protobuf:"bytes,2,opt,name=eventName,proto3" json:"eventName,omitempty"`
@@ -147,28 +163,72 @@ func (m *NotificationReply) GetResult() bool {
 	return false
 }
 
+type ResyncRequest struct {
+	SourceId             string   `protobuf:"bytes,1,opt,name=sourceId,proto3" json:"sourceId,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ResyncRequest) Reset()         { *m = ResyncRequest{} }
+func (m *ResyncRequest) String() string { return proto.CompactTextString(m) }
+func (*ResyncRequest) ProtoMessage()    {}
+func (*ResyncRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_736a457d4a5efa07, []int{2}
+}
+
+func (m *ResyncRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ResyncRequest.Unmarshal(m, b)
+}
+func (m *ResyncRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ResyncRequest.Marshal(b, m, deterministic)
+}
+func (m *ResyncRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ResyncRequest.Merge(m, src)
+}
+func (m *ResyncRequest) XXX_Size() int {
+	return xxx_messageInfo_ResyncRequest.Size(m)
+}
+func (m *ResyncRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ResyncRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ResyncRequest proto.InternalMessageInfo
+
+func (m *ResyncRequest) GetSourceId() string {
+	if m != nil {
+		return m.SourceId
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*NotificationRequest)(nil), "NotificationRequest")
 	proto.RegisterType((*NotificationRequest_NotificationItem)(nil), "NotificationRequest.NotificationItem")
 	proto.RegisterType((*NotificationReply)(nil), "NotificationReply")
+	proto.RegisterType((*ResyncRequest)(nil), "ResyncRequest")
 }
 
 func init() { proto.RegisterFile("notification.proto", fileDescriptor_736a457d4a5efa07) }
 
 var fileDescriptor_736a457d4a5efa07 = []byte{
-	// 189 bytes of a gzipped FileDescriptorProto
+	// 248 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0xca, 0xcb, 0x2f, 0xc9,
-	0x4c, 0xcb, 0x4c, 0x4e, 0x2c, 0xc9, 0xcc, 0xcf, 0xd3, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x57, 0xda,
-	0xc6, 0xc8, 0x25, 0xec, 0x87, 0x24, 0x1c, 0x94, 0x5a, 0x58, 0x9a, 0x5a, 0x5c, 0x22, 0x14, 0xc8,
+	0x4c, 0xcb, 0x4c, 0x4e, 0x2c, 0xc9, 0xcc, 0xcf, 0xd3, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x57, 0x7a,
+	0xc7, 0xc8, 0x25, 0xec, 0x87, 0x24, 0x1c, 0x94, 0x5a, 0x58, 0x9a, 0x5a, 0x5c, 0x22, 0x14, 0xc8,
 	0x25, 0x80, 0xac, 0xda, 0xb3, 0x24, 0x35, 0x57, 0x82, 0x51, 0x81, 0x59, 0x83, 0xdb, 0x48, 0x55,
-	0x0f, 0x8b, 0x7a, 0x14, 0x31, 0x90, 0xe2, 0x20, 0x0c, 0xed, 0x52, 0x3e, 0x5c, 0x02, 0xe8, 0xaa,
-	0x84, 0xa4, 0xb8, 0x38, 0xd2, 0x32, 0x73, 0x52, 0xf3, 0x12, 0x73, 0x53, 0x25, 0x18, 0x15, 0x18,
-	0x35, 0x38, 0x83, 0xe0, 0x7c, 0x21, 0x19, 0x2e, 0xce, 0xd4, 0xb2, 0xd4, 0xbc, 0x12, 0x3f, 0x90,
-	0x24, 0x13, 0x58, 0x12, 0x21, 0xa0, 0xa4, 0xcd, 0x25, 0x88, 0xea, 0x8e, 0x82, 0x9c, 0x4a, 0x21,
-	0x31, 0x2e, 0xb6, 0xa2, 0xd4, 0xe2, 0xd2, 0x9c, 0x12, 0xb0, 0x61, 0x1c, 0x41, 0x50, 0x9e, 0x91,
-	0x1f, 0x17, 0x0f, 0xb2, 0x62, 0x21, 0x3b, 0x2e, 0x81, 0xe0, 0xd4, 0xbc, 0x14, 0x14, 0x31, 0x11,
-	0x6c, 0xfe, 0x92, 0x12, 0xd2, 0xc3, 0xb0, 0x45, 0x89, 0x21, 0x89, 0x0d, 0x1c, 0x78, 0xc6, 0x80,
-	0x00, 0x00, 0x00, 0xff, 0xff, 0xf7, 0x59, 0x25, 0xc3, 0x52, 0x01, 0x00, 0x00,
+	0x0f, 0x8b, 0x7a, 0x3d, 0x3f, 0x34, 0xc5, 0x41, 0x18, 0xda, 0x85, 0xa4, 0xb8, 0x38, 0x8a, 0xf3,
+	0x4b, 0x8b, 0x92, 0x53, 0x3d, 0x53, 0x24, 0x98, 0x14, 0x18, 0x35, 0x38, 0x83, 0xe0, 0x7c, 0xb0,
+	0x1c, 0xc8, 0xa4, 0xbc, 0xe4, 0x54, 0x09, 0x66, 0x05, 0x46, 0x0d, 0x96, 0x20, 0x38, 0x5f, 0xca,
+	0x87, 0x4b, 0xc0, 0x0f, 0x8b, 0x59, 0x69, 0x99, 0x39, 0xa9, 0x79, 0x89, 0xb9, 0xa9, 0x12, 0x8c,
+	0x10, 0xb3, 0x60, 0x7c, 0x21, 0x19, 0x2e, 0xce, 0xd4, 0xb2, 0xd4, 0xbc, 0x12, 0x3f, 0x90, 0x24,
+	0xc4, 0x22, 0x84, 0x80, 0x92, 0x36, 0x97, 0x20, 0xaa, 0xfb, 0x0b, 0x72, 0x2a, 0x85, 0xc4, 0xb8,
+	0xd8, 0x8a, 0x52, 0x8b, 0x4b, 0x73, 0x4a, 0xc0, 0x86, 0x71, 0x04, 0x41, 0x79, 0x4a, 0xda, 0x5c,
+	0xbc, 0x41, 0xa9, 0xc5, 0x95, 0x79, 0xc9, 0xb0, 0x60, 0x41, 0xf6, 0x03, 0x23, 0xaa, 0x1f, 0x8c,
+	0xea, 0xb8, 0x78, 0x90, 0x4d, 0x16, 0xb2, 0xe3, 0x12, 0x08, 0x4e, 0xcd, 0x4b, 0x41, 0x11, 0x13,
+	0xc1, 0x16, 0x78, 0x52, 0x42, 0x7a, 0x18, 0x4e, 0x52, 0x62, 0x10, 0xd2, 0xe3, 0x62, 0x83, 0x58,
+	0x2e, 0xc4, 0xa7, 0x87, 0xe2, 0x0a, 0xec, 0xea, 0x93, 0xd8, 0xc0, 0x31, 0x6a, 0x0c, 0x18, 0x00,
+	0x4f, 0x63, 0xd5, 0x48, 0xe7, 0x01, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -184,6 +244,7 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type NotificationClient interface {
 	SendNotification(ctx context.Context, in *NotificationRequest, opts ...grpc.CallOption) (*NotificationReply, error)
+	Resync(ctx context.Context, in *ResyncRequest, opts ...grpc.CallOption) (*NotificationReply, error)
 }
 
 type notificationClient struct {
@@ -203,9 +264,19 @@ func (c *notificationClient) SendNotification(ctx context.Context, in *Notificat
 	return out, nil
 }
 
+func (c *notificationClient) Resync(ctx context.Context, in *ResyncRequest, opts ...grpc.CallOption) (*NotificationReply, error) {
+	out := new(NotificationReply)
+	err := c.cc.Invoke(ctx, "/Notification/Resync", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NotificationServer is the server API for Notification service.
 type NotificationServer interface {
 	SendNotification(context.Context, *NotificationRequest) (*NotificationReply, error)
+	Resync(context.Context, *ResyncRequest) (*NotificationReply, error)
 }
 
 func RegisterNotificationServer(s *grpc.Server, srv NotificationServer) {
@@ -230,6 +301,24 @@ func _Notification_SendNotification_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Notification_Resync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServer).Resync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Notification/Resync",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServer).Resync(ctx, req.(*ResyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Notification_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "Notification",
 	HandlerType: (*NotificationServer)(nil),
@@ -238,6 +327,10 @@ var _Notification_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SendNotification",
 			Handler:    _Notification_SendNotification_Handler,
 		},
+		{
+			MethodName: "Resync",
+			Handler:    _Notification_Resync_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "notification.proto",